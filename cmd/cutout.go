@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"imagemage/pkg/filehandler"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cutoutOutput    string
+	cutoutTolerance float64
+	cutoutFeather   int
+	cutoutColor     string
+	cutoutChromaKey bool
+)
+
+var cutoutCmd = &cobra.Command{
+	Use:   "cutout [image-path]",
+	Short: "Remove a uniform background locally",
+	Long: `Remove a flat, uniform background and make it transparent. Runs locally - no API call, no cost.
+
+The background color is sampled from the image border and removed with a flood fill from the
+edges, so matching colors inside the subject are kept. Use --chroma-key to remove every
+matching pixel instead, e.g. for the holes in a donut.
+
+Examples:
+  imagemage cutout logo.png
+  imagemage cutout product.jpg --tolerance=20 --feather=2 -o product.png
+  imagemage cutout sticker.png --color="#00ff00" --chroma-key
+  imagemage generate "red apple on a plain white background" -o - | imagemage cutout - > apple.png`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCutout,
+}
+
+func init() {
+	rootCmd.AddCommand(cutoutCmd)
+
+	cutoutCmd.Flags().StringVarP(&cutoutOutput, "output", "o", "", "Output path (default: <image>_cutout.png, - for stdout; default for piped input)")
+	cutoutCmd.Flags().Float64Var(&cutoutTolerance, "tolerance", filehandler.DefaultBackgroundOptions.Tolerance, "Color distance from the background still treated as background, in percent")
+	cutoutCmd.Flags().IntVar(&cutoutFeather, "feather", filehandler.DefaultBackgroundOptions.Feather, "Width in pixels of the soft edge around the subject (0 for hard edges)")
+	cutoutCmd.Flags().StringVar(&cutoutColor, "color", "", "Background color to remove, e.g. #ffffff (default: detected from the border)")
+	cutoutCmd.Flags().BoolVar(&cutoutChromaKey, "chroma-key", false, "Remove every pixel matching the background, not just those connected to the edges")
+}
+
+func runCutout(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	imagePath := args[0]
+	streams := newStdio(cmd)
+
+	opts, err := cutoutOptions()
+	if err != nil {
+		return err
+	}
+
+	// Determine output path (piped input defaults to piped output)
+	outputPath := cutoutOutput
+	if outputPath == "" {
+		if isStdio(imagePath) {
+			outputPath = stdioPath
+		} else {
+			base := strings.TrimSuffix(imagePath, filepath.Ext(imagePath))
+			outputPath = base + "_cutout.png"
+		}
+	}
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(outputPath)
+	if toStdout {
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+	}
+
+	rep.Printf("Loading image: %s\n", displayPath(imagePath))
+
+	imageBase64, err := streams.image("image", imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(imageBase64)
+	if err != nil {
+		return fmt.Errorf("failed to decode image data: %w", err)
+	}
+
+	// Nothing to estimate - this never calls the API
+	if dryRun {
+		rep.Plan(nil)
+		return nil
+	}
+
+	cutout, key, err := filehandler.RemoveBackgroundData(data, opts)
+	if err != nil {
+		if cutoutColor == "" {
+			return fmt.Errorf("failed to remove background: %w (try --color)", err)
+		}
+		return fmt.Errorf("failed to remove background: %w", err)
+	}
+	rep.Printf("Removed background: #%02x%02x%02x\n", key.R, key.G, key.B)
+	warnIfOpaqueFormat(rep, outputPath)
+
+	if toStdout {
+		return streams.writeImageBytes(rep, cutout, "")
+	}
+
+	outputPath = filehandler.EnsureUniqueFilename(outputPathFor(outputPath))
+	outputPath, _, err = saveImageBytes(rep, cutout, outputPath, "")
+	if err != nil {
+		return fmt.Errorf("failed to save cutout: %w", err)
+	}
+
+	rep.Saved(outputPath, "✓ Cutout saved to: %s\n", outputPath)
+
+	return nil
+}
+
+// cutoutOptions returns the background removal options from the cutout flags
+func cutoutOptions() (filehandler.BackgroundOptions, error) {
+	opts := filehandler.BackgroundOptions{
+		Tolerance: cutoutTolerance,
+		Feather:   cutoutFeather,
+		ChromaKey: cutoutChromaKey,
+	}
+	if opts.Tolerance < 0 || opts.Tolerance > 100 {
+		return opts, fmt.Errorf("tolerance must be between 0 and 100, got %g", opts.Tolerance)
+	}
+	if opts.Feather < 0 {
+		return opts, fmt.Errorf("feather cannot be negative, got %d", opts.Feather)
+	}
+	if cutoutColor != "" {
+		c, err := filehandler.ParseHexColor(cutoutColor)
+		if err != nil {
+			return opts, err
+		}
+		opts.Color = c
+	}
+	return opts, nil
+}
+
+// warnIfOpaqueFormat warns when transparency will be lost to the output format
+func warnIfOpaqueFormat(rep *reporter, outputPath string) {
+	opts, _ := imageOutputOptions()
+	if isStdio(outputPath) {
+		outputPath = ""
+	}
+	if opts.Resolve(outputPath).Format == filehandler.FormatJPEG {
+		rep.Warnf("Warning: JPEG has no transparency; the background will be flattened to white")
+	}
+}
@@ -1,17 +1,39 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"image/png"
+	"imagemage/pkg/diagram"
 	"imagemage/pkg/filehandler"
 	"imagemage/pkg/gemini"
+	"imagemage/pkg/textcheck"
 	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	diagramType   string
-	diagramOutput string
+	diagramType        string
+	diagramOutput      string
+	diagramFrom        string
+	diagramCheckLabels bool
+	diagramVerifyText  string
+	diagramVerifyTries int
+	diagramRenderer    string
+	diagramPolish      string
+	diagramListTypes   bool
+)
+
+// Diagram renderers
+const (
+	rendererModel = "model"
+	rendererLocal = "local"
 )
 
 var diagramCmd = &cobra.Command{
@@ -22,51 +44,328 @@ var diagramCmd = &cobra.Command{
 Examples:
   imagemage diagram "CI/CD pipeline with testing stages"
   imagemage diagram "microservices architecture" --type="architecture"
-  imagemage diagram "user authentication flow" --type="flowchart"`,
-	Args: cobra.MinimumNArgs(1),
-	RunE: runDiagram,
+  imagemage diagram "user authentication flow" --type="flowchart"
+  imagemage diagram "payments platform" --type=c4-container
+  imagemage diagram --list-types
+  imagemage diagram --from architecture.mmd --check-labels
+  imagemage diagram "login flow" --verify-text "Login, Verify OTP, Dashboard"
+  imagemage diagram --from login.puml "emphasize the error paths"
+  imagemage diagram --from pipeline.dot --renderer local
+  imagemage diagram --from pipeline.dot --renderer local --polish "hand-drawn whiteboard"
+
+--from reads Mermaid (.mmd), Graphviz DOT (.dot, .gv) or PlantUML (.puml) source and
+lists every node, edge and label in the prompt verbatim. Flowcharts and sequence
+diagrams are supported; PlantUML component, use case and activity diagrams become
+flowcharts. A description is then optional and adds context.
+
+Each --type has its own prompt template, notation conventions and default aspect
+ratio; --list-types shows them all.
+
+--verify-text reads the finished diagram's text back with a vision model, matches it
+against the labels you list and asks for "fix the spelling of X to Y" edits, up to
+--verify-attempts times. --check-labels does the same for every label in the --from
+source, reporting only unless --verify-attempts is set.
+
+--renderer local skips the model and lays the source out itself, writing an exact,
+editable SVG plus a PNG: a layered layout for flowcharts, lanes for sequence diagrams.
+--polish then sends that render to the model to restyle without moving anything.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{optionalArgsAnnotation: "description"},
+	RunE:        runDiagram,
 }
 
 func init() {
 	rootCmd.AddCommand(diagramCmd)
 
-	diagramCmd.Flags().StringVar(&diagramType, "type", "diagram", "Diagram type: flowchart, architecture, sequence, entity-relationship")
+	diagramCmd.Flags().StringVar(&diagramType, "type", diagram.DefaultType, "Diagram type, e.g. flowchart, architecture, c4-container, sequence, erd (see --list-types)")
 	diagramCmd.Flags().StringVarP(&diagramOutput, "output", "o", ".", "Output directory")
+	diagramCmd.Flags().StringVar(&diagramFrom, "from", "", "Diagram source: Mermaid (.mmd), DOT (.dot, .gv) or PlantUML (.puml), - for stdin")
+	diagramCmd.Flags().BoolVar(&diagramCheckLabels, "check-labels", false, "With --from, read the result's text back and report labels that are missing or misspelled")
+	diagramCmd.Flags().StringVar(&diagramVerifyText, "verify-text", "", "Comma-separated labels the diagram must show; misspellings are fixed with follow-up edits")
+	diagramCmd.Flags().IntVar(&diagramVerifyTries, "verify-attempts", defaultVerifyAttempts, "Maximum fix edits for --verify-text (and --check-labels, when set); 0 only reports")
+	diagramCmd.Flags().StringVar(&diagramRenderer, "renderer", rendererModel, "Renderer: model (painted by the model) or local (exact SVG and PNG, needs --from)")
+	diagramCmd.Flags().StringVar(&diagramPolish, "polish", "", "With --renderer local, have the model restyle the render in this style, keeping its layout and labels")
+	diagramCmd.Flags().BoolVar(&diagramListTypes, "list-types", false, "List the supported diagram types and exit")
 }
 
-func runDiagram(cmd *cobra.Command, args []string) error {
-	description := args[0]
+func runDiagram(cmd *cobra.Command, args []string) (err error) {
+	if diagramListTypes {
+		return listDiagramTypes(cmd)
+	}
+
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	description := ""
+	if len(args) > 0 {
+		description = args[0]
+	}
+	kind, err := diagram.LookupType(diagramType)
+	if err != nil {
+		return err
+	}
+	if description == "" && diagramFrom == "" {
+		return fmt.Errorf("describe the diagram or pass --from with its source")
+	}
+	if diagramCheckLabels && diagramFrom == "" {
+		return fmt.Errorf("--check-labels needs --from; without source there are no labels to check")
+	}
+	switch diagramRenderer {
+	case rendererModel:
+		if diagramPolish != "" {
+			return fmt.Errorf("--polish restyles a local render; add --renderer local")
+		}
+	case rendererLocal:
+		if diagramFrom == "" {
+			return fmt.Errorf("--renderer local needs --from; it can only lay out parsed diagram source")
+		}
+		if (diagramCheckLabels || diagramVerifyText != "") && diagramPolish == "" {
+			return fmt.Errorf("--check-labels and --verify-text check model output; the local renderer draws labels exactly")
+		}
+	default:
+		return fmt.Errorf("invalid renderer %q: use %s or %s", diagramRenderer, rendererModel, rendererLocal)
+	}
+	if diagramVerifyTries < 0 {
+		return fmt.Errorf("--verify-attempts must be 0 or more")
+	}
 
 	// Build prompt
-	prompt := fmt.Sprintf("Create a clear, professional %s diagram: %s. ", diagramType, description)
-	prompt += "The diagram should be well-organized, easy to read, with clear labels, appropriate shapes/symbols, "
-	prompt += "connecting lines/arrows, and good visual hierarchy. Use a clean, technical style."
+	var prompt, name string
+	var labels []string
+	var graph *diagram.Graph
+	if diagramFrom != "" {
+		graph, err = loadDiagramSource(newStdio(cmd), diagramFrom)
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("type") {
+			if kind, err = diagram.LookupType(graph.Kind); err != nil {
+				return err
+			}
+		}
+		labels = graph.Labels()
+
+		prompt = fmt.Sprintf("Create a clear, professional %s diagram. ", kind.Title)
+		if description != "" {
+			prompt += fmt.Sprintf("Context: %s. ", description)
+		}
+		prompt += graph.Describe() + " "
+		prompt += kind.Layout + " "
+		prompt += "Use clean, consistent shapes, straight or orthogonal connecting lines with clear arrowheads, and a clean, technical style."
+
+		name = graph.Title
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(diagramFrom), filepath.Ext(diagramFrom))
+		}
+		if name == "" || isStdio(diagramFrom) {
+			name = graph.Kind
+		}
+	} else {
+		prompt = kind.Prompt(description)
+		name = description
+	}
+
+	// --check-labels only reports unless fixes were asked for
+	verifier := &textVerifier{labels: textcheck.ParseLabels(diagramVerifyText), attempts: diagramVerifyTries}
+	if diagramCheckLabels {
+		for _, label := range labels {
+			if !slices.Contains(verifier.labels, label) {
+				verifier.labels = append(verifier.labels, label)
+			}
+		}
+		if diagramVerifyText == "" && !cmd.Flags().Changed("verify-attempts") {
+			verifier.attempts = 0
+		}
+	}
+	if len(verifier.labels) == 0 {
+		verifier = nil
+	}
+
+	if diagramRenderer == rendererLocal {
+		return renderDiagramLocally(rep, kind, graph, verifier, name, description)
+	}
+
+	// An exact --size asks for the closest supported aspect ratio; otherwise the type
+	// picks one, turned sideways for sources that flow left to right
+	aspectRatio := sizeAspectRatio()
+	if aspectRatio == "" {
+		aspectRatio = kind.AspectRatio
+		if graph != nil && (graph.Direction == "LR" || graph.Direction == "RL") && aspectRatio == "3:4" {
+			aspectRatio = "4:3"
+		}
+	}
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(prompt)
+	calls := []plannedCall{newPlannedCall(gemini.ModelName, prompt, "", aspectRatio)}
+	if verifier != nil {
+		verifier.aspectRatio = aspectRatio
+		calls = append(calls, verifyCalls(gemini.ModelName, "", aspectRatio, verifier.attempts, 1)...)
+	}
+	if stop, err := preflight(rep, calls); stop || err != nil {
+		return err
+	}
 
 	// Create Gemini client
-	client, err := gemini.NewClient()
+	client, err := newClient(rep, false)
 	if err != nil {
-		return fmt.Errorf("failed to create Gemini client: %w", err)
+		return err
 	}
 
-	fmt.Printf("Generating %s: %s\n", diagramType, description)
+	if diagramFrom != "" {
+		rep.Printf("Generating %s from %s (%d labels)\n", kind.Name, displayPath(diagramFrom), len(labels))
+	} else {
+		rep.Printf("Generating %s: %s\n", kind.Name, description)
+	}
+
+	rep.SetImageConfig(aspectRatio, "4K")
 
 	// Generate diagram
-	imageData, err := client.GenerateContent(prompt)
+	imageData, err := client.GenerateContentWithOptions(prompt, "", aspectRatio)
 	if err != nil {
 		return fmt.Errorf("failed to generate diagram: %w", err)
 	}
 
+	if verifier != nil {
+		verifier.client = client
+		imageData = verifier.verify(rep, imageData)
+	}
+
 	// Generate filename
-	filename := filehandler.GenerateFilename(description, diagramType, 0)
-	outputPath := filepath.Join(diagramOutput, filename)
-	outputPath = filehandler.EnsureUniqueFilename(outputPath)
+	filename := filehandler.GenerateFilename(name, kind.Name, 0)
+	outputPath := filehandler.EnsureUniqueFilename(outputPathFor(filepath.Join(diagramOutput, filename)))
 
 	// Save diagram
-	if err := filehandler.SaveImage(imageData, outputPath); err != nil {
+	outputPath, _, err = saveImage(rep, imageData, outputPath, "")
+	if err != nil {
 		return fmt.Errorf("failed to save diagram: %w", err)
 	}
 
-	fmt.Printf("✓ Diagram saved to: %s\n", outputPath)
+	rep.Saved(outputPath, "✓ Diagram saved to: %s\n", outputPath)
 
 	return nil
 }
+
+// renderDiagramLocally lays the graph out without the model and saves it as SVG and
+// PNG, then optionally has the model restyle the PNG
+func renderDiagramLocally(rep *reporter, kind diagram.Type, graph *diagram.Graph, verifier *textVerifier, name, description string) error {
+	scene, err := diagram.Layout(graph)
+	if err != nil {
+		return fmt.Errorf("failed to lay out diagram: %w", err)
+	}
+	// PNGs are drawn at twice the layout size so text stays crisp
+	img, err := scene.Image(2)
+	if err != nil {
+		return fmt.Errorf("failed to render diagram: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed to encode diagram: %w", err)
+	}
+
+	var prompt, aspectRatio string
+	var calls []plannedCall
+	if diagramPolish != "" {
+		prompt = fmt.Sprintf("Redraw this %s diagram in a polished %s style. ", kind.Title, diagramPolish)
+		if description != "" {
+			prompt += fmt.Sprintf("Context: %s. ", description)
+		}
+		prompt += "Keep every node, group, connection, arrow direction and label exactly as shown, in the same positions, " +
+			"with identical spelling. Do not add, remove or rename anything; only change the visual styling."
+
+		aspectRatio = sizeAspectRatio()
+		if aspectRatio == "" {
+			aspectRatio = gemini.FindClosestAspectRatio(img.Rect.Dx(), img.Rect.Dy())
+		}
+		rep.SetPrompt(prompt)
+		calls = append(calls, newPlannedCall(gemini.ModelName, prompt, "", aspectRatio, plannedInput{Name: "local render (generated)"}))
+		if verifier != nil {
+			verifier.aspectRatio = aspectRatio
+			calls = append(calls, verifyCalls(gemini.ModelName, "", aspectRatio, verifier.attempts, 1)...)
+		}
+	}
+	if stop, err := preflight(rep, calls); stop || err != nil {
+		return err
+	}
+
+	rep.Printf("Rendering %s from %s locally (%d nodes, %d edges)\n", kind.Name, displayPath(diagramFrom), len(graph.Nodes), len(graph.Edges))
+
+	base := filepath.Join(diagramOutput, filehandler.GenerateFilename(name, kind.Name, 0))
+	svgPath := filehandler.EnsureUniqueFilename(base + ".svg")
+	if err := filehandler.WriteImageFile(svgPath, scene.SVG()); err != nil {
+		return fmt.Errorf("failed to save diagram: %w", err)
+	}
+	rep.Saved(svgPath, "✓ SVG saved to: %s\n", svgPath)
+
+	pngPath, _, err := saveImageBytes(rep, buf.Bytes(), filehandler.EnsureUniqueFilename(outputPathFor(base+".png")), "")
+	if err != nil {
+		return fmt.Errorf("failed to save diagram: %w", err)
+	}
+	rep.Saved(pngPath, "✓ Diagram saved to: %s\n", pngPath)
+
+	if diagramPolish == "" {
+		return nil
+	}
+
+	client, err := newClient(rep, false)
+	if err != nil {
+		return err
+	}
+	rep.Printf("Polishing in %s style\n", diagramPolish)
+	rep.SetImageConfig(aspectRatio, "4K")
+
+	render := base64.StdEncoding.EncodeToString(buf.Bytes())
+	imageData, err := client.GenerateContentWithImages(prompt, []string{render}, aspectRatio)
+	if err != nil {
+		return fmt.Errorf("failed to polish diagram: %w", err)
+	}
+	if verifier != nil {
+		verifier.client = client
+		imageData = verifier.verify(rep, imageData)
+	}
+	polishedPath := filehandler.EnsureUniqueFilename(outputPathFor(base + "_polished.png"))
+	polishedPath, _, err = saveImage(rep, imageData, polishedPath, "")
+	if err != nil {
+		return fmt.Errorf("failed to save polished diagram: %w", err)
+	}
+	rep.Saved(polishedPath, "✓ Polished diagram saved to: %s\n", polishedPath)
+	return nil
+}
+
+// listDiagramTypes prints the diagram type registry
+func listDiagramTypes(cmd *cobra.Command) error {
+	if outputFormat == outputFormatJSON {
+		type entry struct {
+			Name        string   `json:"name"`
+			Aliases     []string `json:"aliases,omitempty"`
+			Summary     string   `json:"summary"`
+			AspectRatio string   `json:"aspectRatio"`
+		}
+		entries := make([]entry, len(diagram.Types))
+		for i, t := range diagram.Types {
+			entries[i] = entry{t.Name, t.Aliases, t.Summary, t.AspectRatio}
+		}
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{"types": entries})
+	}
+
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "TYPE\tASPECT\tALIASES\tDESCRIPTION\n")
+	for _, t := range diagram.Types {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.Name, t.AspectRatio, strings.Join(t.Aliases, ", "), t.Summary)
+	}
+	return tw.Flush()
+}
+
+// loadDiagramSource parses a diagram source file, or stdin for "-"
+func loadDiagramSource(streams *stdio, path string) (*diagram.Graph, error) {
+	if !isStdio(path) {
+		return diagram.Load(path)
+	}
+	text, err := streams.text("diagram source", path)
+	if err != nil {
+		return nil, err
+	}
+	return diagram.Parse(text, "")
+}
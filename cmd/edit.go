@@ -2,9 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"imagemage/pkg/filehandler"
 	"imagemage/pkg/gemini"
-	"imagemage/pkg/metadata"
+	"imagemage/pkg/inpaint"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,6 +19,8 @@ var (
 	editFrugal      bool
 	editForce       bool
 	editStorePrompt bool
+	editMask        string
+	editFeather     int
 )
 
 var editCmd = &cobra.Command{
@@ -40,7 +41,13 @@ Examples:
   imagemage edit scene.png "put these people here" -i person1.png -i person2.png
 
   # Complex composition
-  imagemage edit office.png "add this person and this laptop" -i person.png -i laptop.png`,
+  imagemage edit office.png "add this person and this laptop" -i person.png -i laptop.png
+
+  # Only change the white area of a mask; everything else stays pixel-for-pixel
+  imagemage edit street.png "remove the person" --mask person-mask.png
+
+  # Pipe images through (piped input writes to stdout unless --output is given)
+  imagemage edit - "crop to the subject" < in.png > out.png`,
 	Args: cobra.ExactArgs(2),
 	RunE: runEdit,
 }
@@ -48,61 +55,126 @@ Examples:
 func init() {
 	rootCmd.AddCommand(editCmd)
 
-	editCmd.Flags().StringVarP(&editOutput, "output", "o", "", "Output path for edited image (default: base-image-edited.png)")
-	editCmd.Flags().StringArrayVarP(&editInputs, "input", "i", []string{}, "Additional input images for composition (can be used multiple times)")
+	editCmd.Flags().StringVarP(&editOutput, "output", "o", "", "Output path for edited image (default: base-image-edited.png, - for stdout)")
+	editCmd.Flags().StringArrayVarP(&editInputs, "input", "i", []string{}, "Additional input images for composition (can be used multiple times, - for stdin)")
 	editCmd.Flags().StringVarP(&editAspectRatio, "aspect-ratio", "a", "", "Aspect ratio for output (auto-detected from input if not specified)")
 	editCmd.Flags().StringVarP(&editResolution, "resolution", "r", "", "Image resolution (1K, 2K, 4K). Defaults to 4K for Pro model, 1K for --frugal")
 	editCmd.Flags().BoolVarP(&editFrugal, "frugal", "f", false, "Use the cheaper gemini-2.5-flash-image model")
 	editCmd.Flags().BoolVar(&editForce, "force", false, "Overwrite output file if it exists")
 	editCmd.Flags().BoolVar(&editStorePrompt, "store-prompt", false, "Store instruction in PNG metadata")
+	editCmd.Flags().StringVar(&editMask, "mask", "", "Black-and-white mask: only the white area is edited, the rest keeps the original pixels (- for stdin)")
+	editCmd.Flags().IntVar(&editFeather, "feather", 8, "Pixels over which a --mask edit fades into the original, inside the mask edge (0 for a hard edge)")
 }
 
-func runEdit(cmd *cobra.Command, args []string) error {
+func runEdit(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
 	baseImagePath := args[0]
-	instruction := args[1]
+	streams := newStdio(cmd)
+	instruction, err := streams.text("instruction", args[1])
+	if err != nil {
+		return err
+	}
 
 	// Check if base image exists
-	if _, err := os.Stat(baseImagePath); os.IsNotExist(err) {
-		return fmt.Errorf("base image not found: %s", baseImagePath)
+	if !isStdio(baseImagePath) {
+		if _, err := os.Stat(baseImagePath); os.IsNotExist(err) {
+			return fmt.Errorf("base image not found: %s", baseImagePath)
+		}
 	}
 
-	// Check additional input images
-	for _, inputPath := range editInputs {
+	if editMask == "" && cmd.Flags().Changed("feather") {
+		return fmt.Errorf("--feather blends a --mask edit; add --mask")
+	}
+	if editFeather < 0 {
+		return fmt.Errorf("--feather must be 0 or more")
+	}
+
+	// Check the mask and additional input images
+	for _, inputPath := range append([]string{editMask}, editInputs...) {
+		if inputPath == "" {
+			continue
+		}
+		if isStdio(inputPath) {
+			continue
+		}
 		if _, err := os.Stat(inputPath); os.IsNotExist(err) {
 			return fmt.Errorf("input image not found: %s", inputPath)
 		}
 	}
 
-	// Total images check (base + additional)
+	// Determine output path (piped input defaults to piped output)
+	outputPath := editOutput
+	if outputPath == "" {
+		if isStdio(baseImagePath) {
+			outputPath = stdioPath
+		} else {
+			ext := filepath.Ext(baseImagePath)
+			baseName := strings.TrimSuffix(filepath.Base(baseImagePath), ext)
+			outputPath = filepath.Join(filepath.Dir(baseImagePath), baseName+"-edited"+ext)
+		}
+	}
+	outputPath = outputPathFor(outputPath)
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(outputPath)
+	if toStdout {
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+	}
+
+	// Total images check (base + mask overlay + additional)
 	totalImages := 1 + len(editInputs)
+	if editMask != "" {
+		totalImages++
+	}
 	if totalImages > 14 {
 		return fmt.Errorf("too many input images (%d). Maximum is 14 (base + additional)", totalImages)
 	}
 	if totalImages > 3 {
-		fmt.Printf("⚠️  Using %d images. API works best with 3 or fewer images.\n", totalImages)
-	}
-
-	// Determine output path
-	outputPath := editOutput
-	if outputPath == "" {
-		ext := filepath.Ext(baseImagePath)
-		baseName := strings.TrimSuffix(filepath.Base(baseImagePath), ext)
-		outputPath = filepath.Join(filepath.Dir(baseImagePath), baseName+"-edited"+ext)
+		rep.Warnf("Using %d images. API works best with 3 or fewer images.", totalImages)
 	}
 
 	// Check if output exists
-	if !editForce {
+	if !editForce && !toStdout {
 		if _, err := os.Stat(outputPath); err == nil {
 			return fmt.Errorf("output file already exists: %s (use --force to overwrite)", outputPath)
 		}
 	}
 
+	rep.Printf("Loading base image: %s\n", displayPath(baseImagePath))
+
+	// Load and encode base image
+	baseImageBase64, err := streams.image("base image", baseImagePath)
+	if err != nil {
+		return fmt.Errorf("failed to load base image: %w", err)
+	}
+
+	// A masked edit is composited onto the base image, so the model has to keep its
+	// shape; --size is applied locally afterwards
+	if editMask != "" && editAspectRatio != "" {
+		width, height, err := base64ImageDimensions(baseImageBase64)
+		if err != nil {
+			return fmt.Errorf("failed to read base image: %w", err)
+		}
+		if baseAspectRatio := gemini.FindClosestAspectRatio(width, height); editAspectRatio != baseAspectRatio {
+			return fmt.Errorf("--mask keeps the base image's shape (%s) and can't change it to %s; remove --aspect-ratio or use extend", baseAspectRatio, editAspectRatio)
+		}
+	}
+
+	// An exact --size asks for the closest supported aspect ratio
+	if editAspectRatio == "" && editMask == "" {
+		editAspectRatio = sizeAspectRatio()
+	}
+
 	// Auto-detect aspect ratio from base image if not specified
 	detectedAspectRatio := ""
 	if editAspectRatio == "" {
-		width, height, err := filehandler.GetImageDimensions(baseImagePath)
+		width, height, err := base64ImageDimensions(baseImageBase64)
 		if err != nil {
-			fmt.Printf("⚠️  Could not detect image dimensions: %v\n", err)
+			rep.Warnf("Could not detect image dimensions: %v", err)
 		} else {
 			detectedAspectRatio = gemini.FindClosestAspectRatio(width, height)
 			editAspectRatio = detectedAspectRatio
@@ -122,100 +194,173 @@ func runEdit(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("--frugal mode has fixed 1024px output and does not accept --resolution parameter. Gemini 2.5 Flash always outputs at 1024px. Remove --resolution or --frugal flag")
 		}
 		if totalImages > 1 {
-			fmt.Printf("⚠️  Warning: Multi-image composition with --frugal mode may have limitations. Gemini 2.5 Flash multi-image capabilities are not well documented. For best results with %d images, consider using Gemini 3 Pro (remove --frugal flag).\n\n", totalImages)
+			rep.Warnf("Warning: Multi-image composition with --frugal mode may have limitations. Gemini 2.5 Flash multi-image capabilities are not well documented. For best results with %d images, consider using Gemini 3 Pro (remove --frugal flag).", totalImages)
+			rep.Println()
 		}
 	}
 
-	fmt.Printf("Loading base image: %s\n", filepath.Base(baseImagePath))
+	var allImagesBase64 []string
+	allImagesBase64 = append(allImagesBase64, baseImageBase64)
+	inputs := []plannedInput{imageInput(baseImagePath, baseImageBase64)}
 
-	// Load and encode base image
-	baseImageBase64, err := filehandler.LoadImageAsBase64(baseImagePath)
-	if err != nil {
-		return fmt.Errorf("failed to load base image: %w", err)
+	// A mask is shown to the model as a highlighted copy right after the base image
+	prompt := instruction
+	var editMaskArea *inpaint.Mask
+	if editMask != "" {
+		rep.Printf("Loading mask: %s\n", displayPath(editMask))
+		var overlayBase64 string
+		editMaskArea, overlayBase64, err = loadEditMask(streams, baseImageBase64)
+		if err != nil {
+			return err
+		}
+		allImagesBase64 = append(allImagesBase64, overlayBase64)
+		inputs = append(inputs, imageInput(editMask+" (highlighted on the base image)", overlayBase64))
+		prompt = inpaint.Instruction(instruction)
 	}
 
 	// Load and encode additional images
-	var allImagesBase64 []string
-	allImagesBase64 = append(allImagesBase64, baseImageBase64)
-
 	for i, inputPath := range editInputs {
-		fmt.Printf("Loading input %d: %s\n", i+1, filepath.Base(inputPath))
-		inputBase64, err := filehandler.LoadImageAsBase64(inputPath)
+		rep.Printf("Loading input %d: %s\n", i+1, displayPath(inputPath))
+		inputBase64, err := streams.image("input image", inputPath)
 		if err != nil {
 			return fmt.Errorf("failed to load input image %s: %w", inputPath, err)
 		}
 		allImagesBase64 = append(allImagesBase64, inputBase64)
+		inputs = append(inputs, imageInput(inputPath, inputBase64))
+	}
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(prompt)
+	if stop, err := preflight(rep, []plannedCall{newPlannedCall(modelName(editFrugal), prompt, editResolution, editAspectRatio, inputs...)}); stop || err != nil {
+		return err
 	}
 
 	// Create Gemini client
-	var client *gemini.Client
-	if editFrugal {
-		client, err = gemini.NewFrugalClient()
-		if err != nil {
-			return fmt.Errorf("failed to create Gemini client: %w", err)
-		}
-	} else {
-		client, err = gemini.NewClient()
-		if err != nil {
-			return fmt.Errorf("failed to create Gemini client: %w", err)
-		}
+	client, err := newClient(rep, editFrugal)
+	if err != nil {
+		return err
 	}
 
 	// Display edit info
-	fmt.Printf("\nEditing with %d image(s)\n", totalImages)
-	fmt.Printf("Instruction: %s\n", instruction)
+	rep.Printf("\nEditing with %d image(s)\n", totalImages)
+	rep.Printf("Instruction: %s\n", instruction)
+	if editMaskArea != nil {
+		coverage := editMaskArea.Coverage()
+		rep.SetMetric("maskCoverage", coverage)
+		rep.Printf("Mask: %s (%.0f%% of the image, %dpx feather)\n", displayPath(editMask), coverage*100, editFeather)
+	}
 	if editAspectRatio != "" {
 		if detectedAspectRatio != "" {
-			fmt.Printf("Aspect Ratio: %s (auto-detected from input)\n", editAspectRatio)
+			rep.Printf("Aspect Ratio: %s (auto-detected from input)\n", editAspectRatio)
 		} else {
-			fmt.Printf("Aspect Ratio: %s\n", editAspectRatio)
+			rep.Printf("Aspect Ratio: %s\n", editAspectRatio)
 		}
 	}
 	// Display resolution info
+	resolution := editResolution
 	if editFrugal {
-		fmt.Printf("Resolution: 1024px (fixed)\n")
+		resolution = "1024px"
+		rep.Printf("Resolution: 1024px (fixed)\n")
 	} else {
-		resolution := editResolution
 		if resolution == "" {
 			resolution = "4K"
 		}
-		fmt.Printf("Resolution: %s\n", resolution)
+		rep.Printf("Resolution: %s\n", resolution)
 	}
 	if editFrugal {
-		fmt.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
+		rep.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
 	} else {
-		fmt.Printf("Model: %s\n", gemini.ModelName)
+		rep.Printf("Model: %s\n", gemini.ModelName)
 	}
-	fmt.Println("\nGenerating edited image...")
+	rep.Println("\nGenerating edited image...")
+
+	rep.SetImageConfig(editAspectRatio, resolution)
 
 	// Generate with all images
 	var editedImageData string
 	if editResolution != "" || editAspectRatio != "" {
-		editedImageData, err = client.GenerateContentWithFullOptions(instruction, allImagesBase64, editResolution, editAspectRatio)
+		editedImageData, err = client.GenerateContentWithFullOptions(prompt, allImagesBase64, editResolution, editAspectRatio)
 	} else {
-		editedImageData, err = client.GenerateContentWithImages(instruction, allImagesBase64, "")
+		editedImageData, err = client.GenerateContentWithImages(prompt, allImagesBase64, "")
 	}
 
 	if err != nil {
 		return fmt.Errorf("failed to edit image: %w", err)
 	}
 
-	// Save edited image
-	if err := filehandler.SaveImage(editedImageData, outputPath); err != nil {
-		return fmt.Errorf("failed to save edited image: %w", err)
+	// Put the original back everywhere outside the mask
+	if editMaskArea != nil {
+		editedImageData, err = compositeMaskedEdit(baseImageBase64, editedImageData, editMaskArea.Feather(editFeather))
+		if err != nil {
+			return fmt.Errorf("failed to composite edit: %w", err)
+		}
 	}
 
-	// Store instruction in metadata if requested
-	if editStorePrompt {
-		if err := metadata.AddPromptToPNG(outputPath, instruction); err != nil {
-			fmt.Printf("⚠️  Warning: failed to store prompt in metadata: %v\n", err)
+	if toStdout {
+		storedPrompt := ""
+		if editStorePrompt {
+			storedPrompt = instruction
 		}
+		return streams.writeImage(rep, editedImageData, storedPrompt)
 	}
 
-	fmt.Printf("✓ Saved to: %s\n", outputPath)
+	// Save edited image, storing the instruction in metadata if requested
+	storedPrompt := ""
 	if editStorePrompt {
-		fmt.Printf("  (instruction stored in metadata)\n")
+		storedPrompt = instruction
+	}
+	outputPath, promptStored, err := saveImage(rep, editedImageData, outputPath, storedPrompt)
+	if err != nil {
+		return fmt.Errorf("failed to save edited image: %w", err)
+	}
+
+	rep.Saved(outputPath, "✓ Saved to: %s\n", outputPath)
+	if promptStored {
+		rep.Printf("  (instruction stored in metadata)\n")
 	}
 
 	return nil
 }
+
+// loadEditMask reads --mask at the base image's size and returns it with the base
+// image highlighted where it may change
+func loadEditMask(streams *stdio, baseImageBase64 string) (*inpaint.Mask, string, error) {
+	base, err := decodeImage(baseImageBase64)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read base image: %w", err)
+	}
+	maskBase64, err := streams.image("mask", editMask)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load mask: %w", err)
+	}
+	maskImage, err := decodeImage(maskBase64)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read mask: %w", err)
+	}
+
+	b := base.Bounds()
+	mask, err := inpaint.NewMask(maskImage, b.Dx(), b.Dy())
+	if err != nil {
+		return nil, "", err
+	}
+
+	overlay, err := encodePNGBase64(inpaint.Annotate(base, mask))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode mask overlay: %w", err)
+	}
+	return mask, overlay, nil
+}
+
+// compositeMaskedEdit blends the edited image into the original through the mask and
+// returns the result as base64 PNG data
+func compositeMaskedEdit(originalBase64, editedBase64 string, mask *inpaint.Mask) (string, error) {
+	original, err := decodeImage(originalBase64)
+	if err != nil {
+		return "", err
+	}
+	edited, err := decodeImage(editedBase64)
+	if err != nil {
+		return "", err
+	}
+	return encodePNGBase64(inpaint.Composite(original, edited, mask))
+}
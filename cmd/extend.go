@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"imagemage/pkg/gemini"
+	"imagemage/pkg/inpaint"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	extendTo          string
+	extendAnchor      string
+	extendPrompt      string
+	extendOutput      string
+	extendFeather     int
+	extendResolution  string
+	extendFrugal      bool
+	extendForce       bool
+	extendStorePrompt bool
+)
+
+var extendCmd = &cobra.Command{
+	Use:   "extend [image-path]",
+	Short: "Extend an image to a new aspect ratio (outpainting)",
+	Long: `Extend an image's canvas to a new aspect ratio and let the model fill in the rest.
+
+The image is padded locally to the smallest canvas with the new aspect ratio, the
+model fills the empty area, and the original pixels are put back over the result so
+the source region is preserved exactly. --anchor sets which side the original stays
+against; the canvas grows away from it.
+
+Examples:
+  imagemage extend hero.png --to 16:9
+  imagemage extend portrait.jpg --to 3:2 --anchor left --prompt "more of the garden"
+  imagemage extend product.png --to 9:16 --anchor bottom --feather 16
+  imagemage generate "a lighthouse" -o - | imagemage extend - --to 21:9 > banner.png`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExtend,
+}
+
+func init() {
+	rootCmd.AddCommand(extendCmd)
+
+	extendCmd.Flags().StringVar(&extendTo, "to", "", "Target aspect ratio, e.g. 16:9 (required)")
+	extendCmd.Flags().StringVar(&extendAnchor, "anchor", "center", "Where the original stays: "+strings.Join(inpaint.Anchors, ", "))
+	extendCmd.Flags().StringVarP(&extendPrompt, "prompt", "p", "", "What to put in the new area (default: continue the scene)")
+	extendCmd.Flags().StringVarP(&extendOutput, "output", "o", "", "Output path (default: image_extended.png, - for stdout; default for piped input)")
+	extendCmd.Flags().IntVar(&extendFeather, "feather", 0, "Pixels over which the original fades into the new area, inside its edge (0 keeps every original pixel)")
+	extendCmd.Flags().StringVarP(&extendResolution, "resolution", "r", "", "Image resolution (1K, 2K, 4K). Defaults to 4K for Pro model, 1K for --frugal")
+	extendCmd.Flags().BoolVarP(&extendFrugal, "frugal", "f", false, "Use the cheaper gemini-2.5-flash-image model")
+	extendCmd.Flags().BoolVar(&extendForce, "force", false, "Overwrite output file if it exists")
+	extendCmd.Flags().BoolVar(&extendStorePrompt, "store-prompt", false, "Store the prompt in PNG metadata")
+}
+
+func runExtend(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	imagePath := args[0]
+	streams := newStdio(cmd)
+
+	if extendTo == "" {
+		return fmt.Errorf("--to is required, e.g. --to 16:9 (use %s)", strings.Join(gemini.SupportedAspectRatios, ", "))
+	}
+	if err := gemini.ValidateAspectRatio(extendTo); err != nil {
+		return err
+	}
+	if extendFeather < 0 {
+		return fmt.Errorf("--feather must be 0 or more")
+	}
+	if extendFrugal && extendResolution != "" {
+		return fmt.Errorf("--frugal mode has fixed 1024px output and does not accept --resolution parameter. Gemini 2.5 Flash always outputs at 1024px. Remove --resolution or --frugal flag")
+	}
+
+	// Check if the image exists
+	if !isStdio(imagePath) {
+		if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+			return fmt.Errorf("image not found: %s", imagePath)
+		}
+	}
+
+	// Determine output path (piped input defaults to piped output)
+	outputPath := extendOutput
+	if outputPath == "" {
+		if isStdio(imagePath) {
+			outputPath = stdioPath
+		} else {
+			ext := filepath.Ext(imagePath)
+			outputPath = strings.TrimSuffix(imagePath, ext) + "_extended" + ext
+		}
+	}
+	outputPath = outputPathFor(outputPath)
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(outputPath)
+	if toStdout {
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+	}
+
+	// Check if output exists
+	if !extendForce && !toStdout {
+		if _, err := os.Stat(outputPath); err == nil {
+			return fmt.Errorf("output file already exists: %s (use --force to overwrite)", outputPath)
+		}
+	}
+
+	rep.Printf("Loading image: %s\n", displayPath(imagePath))
+
+	imageBase64, err := streams.image("image", imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+	img, err := decodeImage(imageBase64)
+	if err != nil {
+		return err
+	}
+
+	// Pad the canvas locally; the model only has to fill the gray
+	b := img.Bounds()
+	extension, err := inpaint.Extend(b.Dx(), b.Dy(), extendTo, extendAnchor)
+	if err != nil {
+		return err
+	}
+	padded := extension.Pad(img)
+	paddedBase64, err := encodePNGBase64(padded)
+	if err != nil {
+		return fmt.Errorf("failed to encode padded canvas: %w", err)
+	}
+
+	prompt := inpaint.ExtendInstruction(extendPrompt)
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(prompt)
+	if stop, err := preflight(rep, []plannedCall{newPlannedCall(modelName(extendFrugal), prompt, extendResolution, extendTo, imageInput(displayPath(imagePath)+" (padded)", paddedBase64))}); stop || err != nil {
+		return err
+	}
+
+	client, err := newClient(rep, extendFrugal)
+	if err != nil {
+		return err
+	}
+
+	canvas := extension.Canvas
+	rep.Printf("Canvas: %dx%d → %dx%d (%s, anchored %s)\n", b.Dx(), b.Dy(), canvas.Dx(), canvas.Dy(), extendTo, extendAnchor)
+	if extendPrompt != "" {
+		rep.Printf("New area: %s\n", extendPrompt)
+	}
+	resolution := extendResolution
+	if extendFrugal {
+		resolution = "1024px"
+		rep.Printf("Resolution: 1024px (fixed)\n")
+		rep.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
+	} else {
+		if resolution == "" {
+			resolution = "4K"
+		}
+		rep.Printf("Resolution: %s\n", resolution)
+		rep.Printf("Model: %s\n", gemini.ModelName)
+	}
+	rep.Println("\nExtending image...")
+
+	rep.SetImageConfig(extendTo, resolution)
+
+	filledImageData, err := client.GenerateContentWithFullOptions(prompt, []string{paddedBase64}, extendResolution, extendTo)
+	if err != nil {
+		return fmt.Errorf("failed to extend image: %w", err)
+	}
+	filled, err := decodeImage(filledImageData)
+	if err != nil {
+		return err
+	}
+
+	// Put the original's pixels back over the model's version of them
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, extension.Composite(filled, padded, extendFeather)); err != nil {
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	storedPrompt := ""
+	if extendStorePrompt {
+		storedPrompt = prompt
+	}
+	if toStdout {
+		return streams.writeImageBytes(rep, buf.Bytes(), storedPrompt)
+	}
+
+	outputPath, promptStored, err := saveImageBytes(rep, buf.Bytes(), outputPath, storedPrompt)
+	if err != nil {
+		return fmt.Errorf("failed to save extended image: %w", err)
+	}
+
+	rep.Saved(outputPath, "✓ Extended image saved to: %s\n", outputPath)
+	if promptStored {
+		rep.Printf("  (prompt stored in metadata)\n")
+	}
+
+	return nil
+}
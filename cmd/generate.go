@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"imagemage/pkg/filehandler"
 	"imagemage/pkg/gemini"
-	"imagemage/pkg/metadata"
+	"imagemage/pkg/textcheck"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
@@ -22,6 +22,8 @@ var (
 	generateConfig      string
 	generateForce       bool
 	generateStorePrompt bool
+	generateVerifyText  string
+	generateVerifyTries int
 )
 
 var generateCmd = &cobra.Command{
@@ -38,7 +40,12 @@ Examples:
   imagemage generate "cyberpunk city" --style="neon, futuristic"
   imagemage generate "wide cinematic shot" --aspect-ratio="21:9"
   imagemage generate "phone wallpaper" --aspect-ratio="9:16"
-  imagemage generate "concept art" --frugal`,
+  imagemage generate "concept art" --frugal
+  imagemage generate "poster" --count=10 --resolution=4K --dry-run
+  imagemage generate "Q3 results: revenue up 12%" --slide --verify-text "Q3 Results, Revenue, +12%"
+
+  # Read the prompt from stdin and write the image to stdout
+  cat prompt.txt | imagemage generate - --output=- > out.png`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runGenerate,
 }
@@ -47,9 +54,9 @@ func init() {
 	rootCmd.AddCommand(generateCmd)
 
 	generateCmd.Flags().IntVarP(&generateCount, "count", "c", 1, "Number of images to generate")
-	generateCmd.Flags().StringVarP(&generateOutput, "output", "o", ".", "Output directory for generated images")
+	generateCmd.Flags().StringVarP(&generateOutput, "output", "o", ".", "Output directory for generated images (- writes a single image to stdout)")
 	generateCmd.Flags().StringVarP(&generateStyle, "style", "s", "", "Additional style guidance (e.g., 'watercolor', 'pixel-art')")
-	generateCmd.Flags().BoolVarP(&generatePreview, "preview", "p", false, "Show preview information")
+	generateCmd.Flags().BoolVarP(&generatePreview, "preview", "p", false, "Preview the request and estimated cost without calling the API (same as --dry-run)")
 	generateCmd.Flags().StringVarP(&generateAspectRatio, "aspect-ratio", "a", "", "Aspect ratio (1:1, 16:9, 9:16, 4:3, 3:4, 3:2, 2:3, 21:9, 5:4, 4:5)")
 	generateCmd.Flags().StringVarP(&generateResolution, "resolution", "r", "", "Image resolution (1K, 2K, 4K). Defaults to 4K for Pro model, 1K for --frugal")
 	generateCmd.Flags().BoolVarP(&generateFrugal, "frugal", "f", false, "Use the cheaper gemini-2.5-flash-image model")
@@ -57,19 +64,44 @@ func init() {
 	generateCmd.Flags().StringVar(&generateConfig, "config", "", "Path to config file (JSON) with style, colorScheme, additionalContext")
 	generateCmd.Flags().BoolVar(&generateForce, "force", false, "Overwrite existing files without confirmation")
 	generateCmd.Flags().BoolVar(&generateStorePrompt, "store-prompt", false, "Store prompt in PNG metadata for reproducibility")
+	generateCmd.Flags().StringVar(&generateVerifyText, "verify-text", "", "Comma-separated text the image must show, e.g. slide titles; misspellings are fixed with follow-up edits")
+	generateCmd.Flags().IntVar(&generateVerifyTries, "verify-attempts", defaultVerifyAttempts, "Maximum fix edits per image for --verify-text; 0 only reports")
 }
 
-func runGenerate(cmd *cobra.Command, args []string) error {
-	prompt := args[0]
+func runGenerate(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	streams := newStdio(cmd)
+	prompt, err := streams.text("prompt", args[0])
+	if err != nil {
+		return err
+	}
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(generateOutput)
+	if toStdout {
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+		if generateCount != 1 {
+			return fmt.Errorf("--output=- writes a single image to stdout and cannot be combined with --count=%d", generateCount)
+		}
+	}
 
 	// Load config if --slide or --config is specified
 	var config *gemini.ImageGenConfig
-	var err error
 	if generateSlide || generateConfig != "" {
 		config, err = gemini.FindConfig(generateConfig)
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
+		rep.SetProject(config.GetProject())
+	}
+
+	// An exact --size asks for the closest supported aspect ratio
+	if generateAspectRatio == "" {
+		generateAspectRatio = sizeAspectRatio()
 	}
 
 	// Apply --slide defaults
@@ -109,6 +141,14 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if generateVerifyTries < 0 {
+		return fmt.Errorf("--verify-attempts must be 0 or more")
+	}
+	var verifier *textVerifier
+	if labels := textcheck.ParseLabels(generateVerifyText); len(labels) > 0 {
+		verifier = &textVerifier{labels: labels, attempts: generateVerifyTries, resolution: generateResolution, aspectRatio: generateAspectRatio}
+	}
+
 	// Build full prompt with style and config
 	fullPrompt := prompt
 	if generateStyle != "" {
@@ -120,60 +160,86 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		fullPrompt = config.ApplyToPrompt(fullPrompt)
 	}
 
+	// Estimate cost, enforce --max-cost and stop here for --dry-run/--preview
+	dryRun = dryRun || generatePreview
+	call := newPlannedCall(modelName(generateFrugal), fullPrompt, generateResolution, generateAspectRatio)
+	call.Count = generateCount
+	calls := []plannedCall{call}
+	if verifier != nil {
+		calls = append(calls, verifyCalls(modelName(generateFrugal), generateResolution, generateAspectRatio, verifier.attempts, generateCount)...)
+	}
+	if stop, err := preflight(rep, calls); stop || err != nil {
+		return err
+	}
+
 	// Create Gemini client (frugal or default)
-	var client *gemini.Client
-	if generateFrugal {
-		client, err = gemini.NewFrugalClient()
-		if err != nil {
-			return fmt.Errorf("failed to create Gemini client: %w", err)
-		}
-	} else {
-		client, err = gemini.NewClient()
-		if err != nil {
-			return fmt.Errorf("failed to create Gemini client: %w", err)
-		}
+	client, err := newClient(rep, generateFrugal)
+	if err != nil {
+		return err
+	}
+	if verifier != nil {
+		verifier.client = client
 	}
 
 	// Display generation info
-	fmt.Printf("Generating %d image(s) for: %s\n", generateCount, prompt)
+	rep.Printf("Generating %d image(s) for: %s\n", generateCount, prompt)
 	if config != nil {
-		fmt.Printf("Config: Loaded (theme applied to prompt)\n")
+		rep.Printf("Config: Loaded (theme applied to prompt)\n")
 	}
 	if generateStyle != "" {
-		fmt.Printf("Style: %s\n", generateStyle)
+		rep.Printf("Style: %s\n", generateStyle)
 	}
 	if generateAspectRatio != "" {
-		fmt.Printf("Aspect Ratio: %s\n", generateAspectRatio)
+		rep.Printf("Aspect Ratio: %s\n", generateAspectRatio)
 	}
 	// Display resolution info
+	resolution := generateResolution
 	if generateFrugal {
-		fmt.Printf("Resolution: 1024px (fixed)\n")
+		resolution = "1024px"
+		rep.Printf("Resolution: 1024px (fixed)\n")
 	} else {
-		resolution := generateResolution
 		if resolution == "" {
 			resolution = "4K"
 		}
-		fmt.Printf("Resolution: %s\n", resolution)
+		rep.Printf("Resolution: %s\n", resolution)
 	}
 	if generateFrugal {
-		fmt.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
+		rep.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
 	} else {
-		fmt.Printf("Model: %s\n", gemini.ModelName)
+		rep.Printf("Model: %s\n", gemini.ModelName)
 	}
-	fmt.Println()
+	rep.Println()
+
+	rep.SetPrompt(fullPrompt)
+	rep.SetImageConfig(generateAspectRatio, resolution)
 
 	successCount := 0
 	for i := 1; i <= generateCount; i++ {
 		if generateCount > 1 {
-			fmt.Printf("[%d/%d] Generating image...\n", i, generateCount)
+			rep.Printf("[%d/%d] Generating image...\n", i, generateCount)
 		} else {
-			fmt.Println("Generating image...")
+			rep.Println("Generating image...")
 		}
 
 		// Generate image with resolution support
 		imageData, err := client.GenerateContentWithResolution(fullPrompt, generateResolution, generateAspectRatio)
 		if err != nil {
-			fmt.Printf("Error generating image %d: %v\n", i, err)
+			rep.Errorf("Error generating image %d: %v", i, err)
+			continue
+		}
+		if verifier != nil {
+			imageData = verifier.verify(rep, imageData)
+		}
+
+		if toStdout {
+			storedPrompt := ""
+			if generateStorePrompt {
+				storedPrompt = fullPrompt
+			}
+			if err := streams.writeImage(rep, imageData, storedPrompt); err != nil {
+				return err
+			}
+			successCount++
 			continue
 		}
 
@@ -186,31 +252,27 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 
 		// Create output path
-		outputPath := filepath.Join(generateOutput, filename)
-		outputPath = filehandler.EnsureUniqueFilename(outputPath)
+		outputPath := filehandler.EnsureUniqueFilename(outputPathFor(filepath.Join(generateOutput, filename)))
 
-		// Save image
-		if err := filehandler.SaveImage(imageData, outputPath); err != nil {
-			fmt.Printf("Error saving image %d: %v\n", i, err)
-			continue
-		}
-
-		// Store prompt in metadata if requested
+		// Save image in the requested format, storing the prompt in metadata if requested
+		storedPrompt := ""
 		if generateStorePrompt {
-			if err := metadata.AddPromptToPNG(outputPath, fullPrompt); err != nil {
-				fmt.Printf("⚠️  Warning: failed to store prompt in metadata: %v\n", err)
-				// Don't fail the whole operation just because metadata write failed
-			}
+			storedPrompt = fullPrompt
+		}
+		outputPath, promptStored, err := saveImage(rep, imageData, outputPath, storedPrompt)
+		if err != nil {
+			rep.Errorf("Error saving image %d: %v", i, err)
+			continue
 		}
 
-		fmt.Printf("✓ Saved to: %s\n", outputPath)
-		if generateStorePrompt {
-			fmt.Printf("  (prompt stored in metadata)\n")
+		rep.Saved(outputPath, "✓ Saved to: %s\n", outputPath)
+		if promptStored {
+			rep.Printf("  (prompt stored in metadata)\n")
 		}
 		successCount++
 	}
 
-	fmt.Printf("\nSuccessfully generated %d/%d images\n", successCount, generateCount)
+	rep.Printf("\nSuccessfully generated %d/%d images\n", successCount, generateCount)
 
 	return nil
 }
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"encoding/base64"
 	"fmt"
+	"image"
 	"imagemage/pkg/filehandler"
 	"imagemage/pkg/gemini"
+	"imagemage/pkg/iconset"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -13,12 +17,21 @@ import (
 )
 
 var (
-	iconSizes  string
-	iconType   string
-	iconOutput string
-	iconInput  string
+	iconSizes       string
+	iconType        string
+	iconOutput      string
+	iconInput       string
+	iconBundle      string
+	iconName        string
+	iconTransparent bool
+	iconOptimize    bool
+	iconGlyph       bool
+	iconSheet       bool
 )
 
+// contactSheetSizes are reviewed when no loose --sizes are written
+var contactSheetSizes = []int{16, 32, 48, 64, 128, 256}
+
 var iconCmd = &cobra.Command{
 	Use:   "icon [description]",
 	Short: "Generate app icons, favicons, and UI elements",
@@ -30,7 +43,23 @@ Examples:
   imagemage icon "rocket ship" --sizes="64,128,256" --type="app-icon"
   imagemage icon "hamburger menu" --type="ui-element"
   imagemage icon "make this into a flat icon" -i logo.png
-  imagemage icon "simplify for app icon" -i photo.png --type="app-icon"`,
+  imagemage icon "simplify for app icon" -i photo.png --type="app-icon"
+  imagemage icon "flat icon" -i - --sizes=256 -o - < logo.png > icon.png
+  imagemage icon "coffee cup logo" --bundle=web --name="Bean There"
+  imagemage icon "rocket ship" --bundle=all -o icons/
+  imagemage icon "paper plane" --transparent
+  imagemage icon "paper plane" --sizes="16,32,48" --glyph --contact-sheet
+
+Sizes up to 128px are sharpened, contrast-boosted and have their edges snapped to the
+pixel grid; pass --optimize=false for plain downscaling. --glyph asks the model for a
+second, simplified render that replaces the full icon below 48px.
+
+Bundles are written to <output>/<platform>/:
+  web      favicon.ico (16/32/48), PNG favicons, apple-touch-icon, PWA icons,
+           site.webmanifest and icon-links.html with the HTML link tags
+  ios      AppIcon.appiconset with every iPhone, iPad and App Store size and Contents.json
+  android  res/mipmap-*dpi launcher and round icons, adaptive icon layers, Play Store icon
+  macos    AppIcon.icns`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runIcon,
 }
@@ -40,12 +69,29 @@ func init() {
 
 	iconCmd.Flags().StringVar(&iconSizes, "sizes", "64,128,256", "Comma-separated list of icon sizes")
 	iconCmd.Flags().StringVar(&iconType, "type", "app-icon", "Icon type: app-icon, favicon, ui-element")
-	iconCmd.Flags().StringVarP(&iconOutput, "output", "o", ".", "Output directory for icons")
-	iconCmd.Flags().StringVarP(&iconInput, "input", "i", "", "Input image to convert to icon")
+	iconCmd.Flags().StringVarP(&iconOutput, "output", "o", ".", "Output directory for icons (- writes a single size to stdout)")
+	iconCmd.Flags().StringVarP(&iconInput, "input", "i", "", "Input image to convert to icon (- for stdin)")
+	iconCmd.Flags().StringVar(&iconBundle, "bundle", "", "Platform bundles to write: web, ios, android, macos or all (comma-separated)")
+	iconCmd.Flags().StringVar(&iconName, "name", "", "App name for the web manifest (default: the description)")
+	iconCmd.Flags().BoolVar(&iconTransparent, "transparent", false, "Remove the generated background locally so icons have real transparency")
+	iconCmd.Flags().BoolVar(&iconOptimize, "optimize", true, "Sharpen, boost contrast and clean up edges at small sizes")
+	iconCmd.Flags().BoolVar(&iconGlyph, "glyph", false, "Generate a simplified glyph variant for sizes under 48px (second API call)")
+	iconCmd.Flags().BoolVar(&iconSheet, "contact-sheet", false, "Also write a contact sheet comparing every size")
 }
 
-func runIcon(cmd *cobra.Command, args []string) error {
-	description := args[0]
+func runIcon(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	streams := newStdio(cmd)
+	description, err := streams.text("description", args[0])
+	if err != nil {
+		return err
+	}
+
+	if imageSize != "" {
+		return fmt.Errorf("icon sizes are set with --sizes, not --size")
+	}
 
 	// Parse sizes
 	sizeStrs := strings.Split(iconSizes, ",")
@@ -58,36 +104,101 @@ func runIcon(cmd *cobra.Command, args []string) error {
 		sizes = append(sizes, size)
 	}
 
+	// Parse bundles; loose sizes are only written alongside a bundle when asked for
+	var bundles []string
+	if iconBundle != "" {
+		bundles, err = iconset.ParsePlatforms(iconBundle)
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("sizes") {
+			sizes = nil
+		}
+	}
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(iconOutput)
+	if toStdout {
+		if len(bundles) > 0 {
+			return fmt.Errorf("--bundle writes a directory of files and cannot be combined with --output=-")
+		}
+		if iconSheet {
+			return fmt.Errorf("--contact-sheet writes a file and cannot be combined with --output=-")
+		}
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+		if len(sizes) != 1 {
+			return fmt.Errorf("--output=- writes a single icon to stdout; pass exactly one size with --sizes")
+		}
+	}
+
+	// A glyph is only worth a second API call if something small gets rendered
+	if iconGlyph && len(bundles) == 0 && !iconSheet && !slices.ContainsFunc(sizes, func(size int) bool { return size < iconset.GlyphMaxSize }) {
+		rep.Warnf("Warning: --glyph only applies to sizes under %dpx; skipping the glyph variant", iconset.GlyphMaxSize)
+		iconGlyph = false
+	}
+
 	// Check input image if provided
 	var inputImageBase64 string
 	if iconInput != "" {
-		if _, err := os.Stat(iconInput); os.IsNotExist(err) {
-			return fmt.Errorf("input image not found: %s", iconInput)
+		if !isStdio(iconInput) {
+			if _, err := os.Stat(iconInput); os.IsNotExist(err) {
+				return fmt.Errorf("input image not found: %s", iconInput)
+			}
 		}
-		var err error
-		inputImageBase64, err = filehandler.LoadImageAsBase64(iconInput)
+		inputImageBase64, err = streams.image("input image", iconInput)
 		if err != nil {
 			return fmt.Errorf("failed to load input image: %w", err)
 		}
-		fmt.Printf("Input image: %s\n", iconInput)
+		rep.Printf("Input image: %s\n", displayPath(iconInput))
 	}
 
 	// Create enhanced prompt for icon generation
 	prompt := fmt.Sprintf("Create a clean, professional %s icon: %s. The icon should be simple, recognizable, and work well at small sizes. Use a square 1:1 aspect ratio. Center the icon on a transparent or solid background.", iconType, description)
+	if iconTransparent {
+		// The API can't return alpha; a flat, contrasting background keys out cleanly
+		prompt = fmt.Sprintf("Create a clean, professional %s icon: %s. The icon should be simple, recognizable, and work well at small sizes. Use a square 1:1 aspect ratio. Center the icon on a completely flat, uniform, solid background color that contrasts with the icon, with no gradients, shadows, or texture in the background.", iconType, description)
+	}
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	var inputs []plannedInput
+	if inputImageBase64 != "" {
+		inputs = append(inputs, imageInput(iconInput, inputImageBase64))
+	}
+	calls := []plannedCall{newPlannedCall(gemini.ModelNameFrugal, prompt, "", "1:1", inputs...)}
+	glyphPrompt := fmt.Sprintf("Redraw this icon as a simplified glyph that stays legible at 16 to 32 pixels: %s. Keep the same subject, colors, and background, but use only a few bold shapes with thick strokes and strong contrast. Remove fine detail, thin lines, text, gradients, and shadows. Use a square 1:1 aspect ratio and fill most of the frame.", description)
+	if iconGlyph {
+		calls[0].Label = "base icon"
+		glyph := newPlannedCall(gemini.ModelNameFrugal, glyphPrompt, "", "1:1", plannedInput{Name: "base icon (generated)", Width: 1024, Height: 1024})
+		glyph.Label = "glyph variant"
+		calls = append(calls, glyph)
+	}
+	rep.SetPrompt(prompt)
+	if stop, err := preflight(rep, calls); stop || err != nil {
+		return err
+	}
 
 	// Use frugal model - 1024px is plenty for icons and much cheaper
-	client, err := gemini.NewFrugalClient()
+	client, err := newClient(rep, true)
 	if err != nil {
-		return fmt.Errorf("failed to create Gemini client: %w", err)
+		return err
 	}
 
-	fmt.Printf("Generating icon: %s\n", description)
-	fmt.Printf("Type: %s\n", iconType)
-	fmt.Printf("Sizes: %v\n", sizes)
-	fmt.Printf("Model: %s (1024px base, then downscaled)\n", gemini.ModelNameFrugal)
-	fmt.Println()
+	rep.Printf("Generating icon: %s\n", description)
+	rep.Printf("Type: %s\n", iconType)
+	if len(sizes) > 0 {
+		rep.Printf("Sizes: %v\n", sizes)
+	}
+	if len(bundles) > 0 {
+		rep.Printf("Bundles: %s\n", strings.Join(bundles, ", "))
+	}
+	rep.Printf("Model: %s (1024px base, then downscaled)\n", gemini.ModelNameFrugal)
+	rep.Println()
 
-	fmt.Println("Generating base icon...")
+	rep.SetImageConfig("1:1", "1024px")
+
+	rep.Println("Generating base icon...")
 
 	var imageData string
 	if inputImageBase64 != "" {
@@ -99,23 +210,145 @@ func runIcon(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to generate icon: %w", err)
 	}
 
-	// Resize and save icons at each requested size
+	// The glyph is derived from the opaque base, which the model handles better
+	var glyphData string
+	if iconGlyph {
+		rep.Println("Generating glyph variant for small sizes...")
+		glyphData, err = client.GenerateContentWithImages(glyphPrompt, []string{imageData}, "1:1")
+		if err != nil {
+			// The base icon was already paid for; fall back to downscaling it
+			rep.Warnf("Warning: failed to generate glyph variant, using the base icon at all sizes: %v", err)
+			glyphData = ""
+		}
+	}
+
+	if iconTransparent {
+		imageData = removeIconBackground(rep, imageData)
+		if glyphData != "" {
+			glyphData = removeIconBackground(rep, glyphData)
+		}
+	}
+
+	src, err := decodeImage(imageData)
+	if err != nil {
+		return err
+	}
+	renderOpts := iconset.Options{Optimize: iconOptimize}
+	if glyphData != "" {
+		if renderOpts.Glyph, err = decodeImage(glyphData); err != nil {
+			rep.Warnf("Warning: ignoring glyph variant: %v", err)
+		}
+	}
+
+	if toStdout {
+		encoded, err := filehandler.EncodeImage(renderOpts.Render(src, sizes[0]), filehandler.OutputOptions{Format: filehandler.FormatPNG})
+		if err != nil {
+			return fmt.Errorf("failed to encode icon: %w", err)
+		}
+		return streams.writeImageBytes(rep, encoded, "")
+	}
+
+	// Render and save icons at each requested size
 	successCount := 0
 	for _, size := range sizes {
 		filename := filehandler.GenerateFilename(description, fmt.Sprintf("icon_%dx%d", size, size), 0)
-		outputPath := filepath.Join(iconOutput, filename)
-		outputPath = filehandler.EnsureUniqueFilename(outputPath)
+		outputPath := filehandler.EnsureUniqueFilename(outputPathFor(filepath.Join(iconOutput, filename)))
 
-		if err := filehandler.ResizeAndSaveImage(imageData, size, outputPath); err != nil {
-			fmt.Printf("Error saving %dx%d icon: %v\n", size, size, err)
+		resized, err := filehandler.EncodeImage(renderOpts.Render(src, size), filehandler.OutputOptions{Format: filehandler.FormatPNG})
+		if err == nil {
+			outputPath, _, err = saveImageBytes(rep, resized, outputPath, "")
+		}
+		if err != nil {
+			rep.Errorf("Error saving %dx%d icon: %v", size, size, err)
 			continue
 		}
 
-		fmt.Printf("✓ Saved %dx%d icon to: %s\n", size, size, outputPath)
+		rep.Saved(outputPath, "✓ Saved %dx%d icon to: %s\n", size, size, outputPath)
 		successCount++
 	}
 
-	fmt.Printf("\nSuccessfully generated %d/%d icon sizes\n", successCount, len(sizes))
+	if len(sizes) > 0 {
+		rep.Printf("\nSuccessfully generated %d/%d icon sizes\n", successCount, len(sizes))
+	}
+
+	if iconSheet {
+		writeContactSheet(rep, src, renderOpts, description, sizes)
+	}
+
+	if len(bundles) > 0 {
+		writeIconBundles(rep, src, renderOpts, description, bundles)
+	}
 
 	return nil
 }
+
+// writeContactSheet writes a PNG comparing the icon at every size
+func writeContactSheet(rep *reporter, src image.Image, opts iconset.Options, description string, sizes []int) {
+	if len(sizes) == 0 {
+		sizes = contactSheetSizes
+	}
+	icons := make([]image.Image, 0, len(sizes))
+	for _, size := range sizes {
+		icons = append(icons, opts.Render(src, size))
+	}
+
+	filename := filehandler.GenerateFilename(description, "icon_contact_sheet", 0) + filehandler.ExtensionFor(filehandler.FormatPNG)
+	outputPath := filehandler.EnsureUniqueFilename(filepath.Join(iconOutput, filename))
+	data, err := filehandler.EncodeImage(iconset.ContactSheet(icons), filehandler.OutputOptions{Format: filehandler.FormatPNG})
+	if err == nil {
+		err = filehandler.WriteImageFile(outputPath, data)
+	}
+	if err != nil {
+		rep.Errorf("Error writing contact sheet: %v", err)
+		return
+	}
+	rep.Saved(outputPath, "✓ Saved contact sheet to: %s\n", outputPath)
+}
+
+// removeIconBackground makes the generated icon's background transparent. If that fails
+// the opaque icon is kept, since it was already paid for.
+func removeIconBackground(rep *reporter, imageData string) string {
+	data, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		rep.Warnf("Warning: failed to decode icon for background removal: %v", err)
+		return imageData
+	}
+
+	cutout, key, err := filehandler.RemoveBackgroundData(data, filehandler.DefaultBackgroundOptions)
+	if err != nil {
+		rep.Warnf("Warning: keeping the opaque background: %v", err)
+		return imageData
+	}
+
+	rep.Printf("Removed background: #%02x%02x%02x\n", key.R, key.G, key.B)
+	warnIfOpaqueFormat(rep, iconOutput)
+	return base64.StdEncoding.EncodeToString(cutout)
+}
+
+// writeIconBundles writes the platform bundles for the generated base icon
+func writeIconBundles(rep *reporter, src image.Image, opts iconset.Options, description string, bundles []string) {
+	if imageFormat != "" || imageQuality != 0 || imageMaxBytes != "" {
+		rep.Warnf("Warning: bundles use the formats each platform requires; --format, --quality and --max-bytes are ignored")
+	}
+
+	opts.Name = iconName
+	if opts.Name == "" {
+		opts.Name = description
+	}
+
+	rep.Println()
+	for _, platform := range bundles {
+		bundle, err := iconset.Write(iconOutput, platform, src, opts)
+		if err != nil {
+			rep.Errorf("Error writing %s bundle: %v", platform, err)
+			continue
+		}
+		for _, file := range bundle.Files {
+			rep.AddFile(file)
+		}
+		rep.Printf("✓ %s bundle: %d file(s) in %s\n", platform, len(bundle.Files), bundle.Dir)
+		for _, note := range bundle.Notes {
+			rep.Printf("\n%s\n\n", note)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"imagemage/pkg/filehandler"
+	"imagemage/pkg/gemini"
+	"imagemage/pkg/metadata"
+	"strconv"
+	"strings"
+)
+
+var (
+	imageFormat   string
+	imageQuality  int
+	imageMaxBytes string
+	imageSize     string
+	imageFit      string
+	imageGravity  string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&imageFormat, "format", "", "Image format for saved files: png, jpeg or webp (default: from the output extension, else png)")
+	rootCmd.PersistentFlags().IntVar(&imageQuality, "quality", 0, "JPEG quality 1-100 (default 90; png and webp are lossless)")
+	rootCmd.PersistentFlags().StringVar(&imageMaxBytes, "max-bytes", "", "Size budget per image, e.g. 500KB or 2MB; searches JPEG quality to fit (implies jpeg unless --format is set)")
+	rootCmd.PersistentFlags().StringVar(&imageSize, "size", "", "Exact output size in pixels, e.g. 1200x630 (picks the closest aspect ratio, then resizes locally)")
+	rootCmd.PersistentFlags().StringVar(&imageFit, "fit", filehandler.FitCover, "How --size is applied: cover (crop), contain (pad) or fill (stretch)")
+	rootCmd.PersistentFlags().StringVar(&imageGravity, "gravity", filehandler.GravityCenter, "Crop position for --fit=cover: center or smart (keeps the most detailed region)")
+}
+
+// imageOutputOptions returns the encoding options from --format, --quality, --max-bytes,
+// --size, --fit and --gravity
+func imageOutputOptions() (filehandler.OutputOptions, error) {
+	maxBytes, err := parseByteSize(imageMaxBytes)
+	if err != nil {
+		return filehandler.OutputOptions{}, fmt.Errorf("invalid --max-bytes: %w", err)
+	}
+
+	opts := filehandler.OutputOptions{
+		Format:   imageFormat,
+		Quality:  imageQuality,
+		MaxBytes: maxBytes,
+		Resize:   filehandler.ResizeOptions{Fit: imageFit, Gravity: imageGravity},
+	}
+	if imageSize != "" {
+		opts.Resize.Width, opts.Resize.Height, err = filehandler.ParseSize(imageSize)
+		if err != nil {
+			return filehandler.OutputOptions{}, err
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return filehandler.OutputOptions{}, err
+	}
+	return opts, nil
+}
+
+// validateImageOptions checks the image encoding flags before a command runs
+func validateImageOptions() error {
+	_, err := imageOutputOptions()
+	return err
+}
+
+// sizeAspectRatio returns the supported aspect ratio closest to --size, or "" when no size is set
+func sizeAspectRatio() string {
+	width, height, err := filehandler.ParseSize(imageSize)
+	if imageSize == "" || err != nil {
+		return ""
+	}
+	return gemini.FindClosestAspectRatio(width, height)
+}
+
+// parseByteSize parses sizes such as 250000, 500KB, 1.5MB (1KB = 1024 bytes). Empty means 0.
+func parseByteSize(s string) (int, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := 1.0
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{{"MB", 1 << 20}, {"M", 1 << 20}, {"KB", 1 << 10}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a positive size like 500KB or 2MB")
+	}
+	return int(n * multiplier), nil
+}
+
+// encodeForOutput re-encodes raw image data for the given output path (or stdout) and
+// embeds storedPrompt when the result is a PNG. It returns the data, the resolved
+// options and whether the prompt was stored.
+func encodeForOutput(rep *reporter, data []byte, outputPath, storedPrompt string) ([]byte, filehandler.OutputOptions, bool, error) {
+	opts, err := imageOutputOptions()
+	if err != nil {
+		return nil, opts, false, err
+	}
+	if isStdio(outputPath) {
+		opts = opts.Resolve("")
+	} else {
+		opts = opts.Resolve(outputPath)
+	}
+
+	encoded, err := filehandler.Encode(data, opts)
+	if err != nil {
+		return nil, opts, false, fmt.Errorf("failed to encode %s: %w", opts.Format, err)
+	}
+
+	if storedPrompt == "" {
+		return encoded, opts, false, nil
+	}
+	if opts.Format != filehandler.FormatPNG {
+		rep.Warnf("Warning: prompt not stored: metadata is only supported for png output, not %s", opts.Format)
+		return encoded, opts, false, nil
+	}
+	withPrompt, err := metadata.AddPromptToPNGData(encoded, storedPrompt)
+	if err != nil {
+		rep.Warnf("Warning: failed to store prompt in metadata: %v", err)
+		return encoded, opts, false, nil
+	}
+	return withPrompt, opts, true, nil
+}
+
+// outputPathFor returns path with its extension matching the format it will be saved in
+func outputPathFor(path string) string {
+	if isStdio(path) {
+		return path
+	}
+	opts, _ := imageOutputOptions() // validated before the command runs
+	return filehandler.WithExtension(path, opts.Resolve(path).Format)
+}
+
+// saveImage encodes base64 image data in the requested output format and writes it to
+// outputPath, correcting the extension to match the format. It returns the final path
+// and whether storedPrompt was embedded.
+func saveImage(rep *reporter, imageData, outputPath, storedPrompt string) (string, bool, error) {
+	data, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decode image data: %w", err)
+	}
+	return saveImageBytes(rep, data, outputPath, storedPrompt)
+}
+
+// saveImageBytes is saveImage for raw image bytes
+func saveImageBytes(rep *reporter, data []byte, outputPath, storedPrompt string) (string, bool, error) {
+	encoded, opts, stored, err := encodeForOutput(rep, data, outputPath, storedPrompt)
+	if err != nil {
+		return "", false, err
+	}
+
+	outputPath = filehandler.WithExtension(outputPath, opts.Format)
+	if err := filehandler.WriteImageFile(outputPath, encoded); err != nil {
+		return "", false, err
+	}
+	return outputPath, stored, nil
+}
+
+// decodeImage decodes base64 image data
+func decodeImage(imageData string) (image.Image, error) {
+	data, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image data: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// encodePNGBase64 encodes an image as base64 PNG data, e.g. for a request
+func encodePNGBase64(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
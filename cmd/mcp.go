@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"imagemage/pkg/filehandler"
+	"imagemage/pkg/mcp"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// mcpToolCommands lists the commands exposed as MCP tools
+var mcpToolCommands = []string{"generate", "edit", "icon", "diagram", "pattern", "story", "restore", "cutout", "extend", "upscale", "vary"}
+
+// mcpPreviewSize is the maximum width/height of preview images returned to MCP clients
+const mcpPreviewSize = 256
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Run a Model Context Protocol (MCP) server over stdio",
+	Long: `Run a Model Context Protocol server that speaks JSON-RPC over stdin/stdout.
+
+Every image command (generate, edit, icon, diagram, pattern, story, restore, cutout,
+extend, upscale, vary) is exposed as a tool. Tool arguments mirror the command's flags,
+plus its positional arguments.
+Tool results are the command's JSON output (see --output-format) plus small image previews.
+
+Example MCP client configuration:
+  {
+    "mcpServers": {
+      "imagemage": { "command": "imagemage", "args": ["mcp"] }
+    }
+  }`,
+	Args: cobra.NoArgs,
+	RunE: runMCP,
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	return serveMCP(cmd.InOrStdin(), cmd.OutOrStdout())
+}
+
+// serveMCP runs the MCP server until in is exhausted
+func serveMCP(in io.Reader, out io.Writer) error {
+	tools, err := buildMCPTools()
+	if err != nil {
+		return err
+	}
+
+	server := mcp.NewServer("imagemage", version, tools, callMCPTool)
+	return server.Serve(in, out)
+}
+
+// buildMCPTools derives a tool definition for each exposed command from its cobra flags
+func buildMCPTools() ([]mcp.Tool, error) {
+	tools := make([]mcp.Tool, 0, len(mcpToolCommands))
+	for _, name := range mcpToolCommands {
+		c, err := findSubcommand(name)
+		if err != nil {
+			return nil, err
+		}
+
+		schema := &mcp.Schema{
+			Type:       "object",
+			Properties: map[string]*mcp.Schema{},
+		}
+
+		for _, arg := range positionalArgNames(c) {
+			schema.Properties[arg] = &mcp.Schema{Type: "string", Description: "Positional argument: " + arg}
+			if !optionalArg(c, arg) {
+				schema.Required = append(schema.Required, arg)
+			}
+		}
+
+		for _, f := range mcpFlags(c) {
+			schema.Properties[f.Name] = flagSchema(f)
+		}
+
+		tools = append(tools, mcp.Tool{
+			Name:        c.Name(),
+			Description: strings.TrimSpace(c.Short + "\n\n" + c.Long),
+			InputSchema: schema,
+		})
+	}
+
+	return tools, nil
+}
+
+// mcpFlags returns the flags accepted as tool arguments: the command's own flags plus
+// global flags, except those the server controls itself
+func mcpFlags(c *cobra.Command) []*pflag.Flag {
+	var flags []*pflag.Flag
+	add := func(f *pflag.Flag) {
+		switch f.Name {
+		case "help", "version", "output-format":
+			return
+		}
+		flags = append(flags, f)
+	}
+	c.LocalFlags().VisitAll(add)
+	rootCmd.PersistentFlags().VisitAll(add)
+	return flags
+}
+
+// flagSchema maps a pflag type onto a JSON schema type
+func flagSchema(f *pflag.Flag) *mcp.Schema {
+	s := &mcp.Schema{Description: f.Usage}
+
+	switch f.Value.Type() {
+	case "bool":
+		s.Type = "boolean"
+		if v, err := strconv.ParseBool(f.DefValue); err == nil && v {
+			s.Default = v
+		}
+	case "int", "int64", "uint", "uint64":
+		s.Type = "integer"
+		if v, err := strconv.Atoi(f.DefValue); err == nil && v != 0 {
+			s.Default = v
+		}
+	case "float32", "float64":
+		s.Type = "number"
+		if v, err := strconv.ParseFloat(f.DefValue, 64); err == nil && v != 0 {
+			s.Default = v
+		}
+	case "stringArray", "stringSlice":
+		s.Type = "array"
+		s.Items = &mcp.Schema{Type: "string"}
+	default:
+		s.Type = "string"
+		if f.DefValue != "" {
+			s.Default = f.DefValue
+		}
+	}
+
+	return s
+}
+
+// positionalArgNames extracts the bracketed argument names from a command's Use line
+func positionalArgNames(c *cobra.Command) []string {
+	var names []string
+	for _, field := range strings.Fields(c.Use)[1:] {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			names = append(names, strings.Trim(field, "[]"))
+		}
+	}
+	return names
+}
+
+// optionalArgsAnnotation lists a command's optional positional arguments (comma-separated).
+// Positional arguments are required unless listed here.
+const optionalArgsAnnotation = "imagemage/optional-args"
+
+// optionalArg reports whether a positional argument of c may be omitted
+func optionalArg(c *cobra.Command, name string) bool {
+	return slices.Contains(strings.Split(c.Annotations[optionalArgsAnnotation], ","), name)
+}
+
+// findSubcommand looks up a direct child of the root command by name
+func findSubcommand(name string) (*cobra.Command, error) {
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown command: %s", name)
+}
+
+// callMCPTool runs a command in-process with the given tool arguments
+func callMCPTool(name string, arguments map[string]any) (*mcp.ToolResult, error) {
+	c, err := findSubcommand(name)
+	if err != nil {
+		return nil, err
+	}
+
+	argv, err := mcpArgv(c, arguments)
+	if err != nil {
+		return nil, err
+	}
+	argv = slices.Insert(argv, 1, "--output-format="+outputFormatJSON)
+
+	// Flags are bound to package variables, so clear anything left over from a previous call
+	resetFlags(c.Flags())
+	resetFlags(rootCmd.PersistentFlags())
+
+	var out, log bytes.Buffer
+	rootCmd.SetArgs(argv)
+	// The server's stdin carries JSON-RPC requests, so commands must never read it
+	rootCmd.SetIn(strings.NewReader(""))
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&log)
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetIn(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SilenceUsage = false
+		rootCmd.SilenceErrors = false
+	}()
+
+	runErr := rootCmd.Execute()
+
+	var res commandResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		// The command failed before producing a result (e.g. flag parsing)
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, fmt.Errorf("failed to parse %s output: %w", name, err)
+	}
+
+	for i, f := range res.Files {
+		if abs, err := filepath.Abs(f.Path); err == nil {
+			res.Files[i].Path = abs
+		}
+	}
+	text, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &mcp.ToolResult{
+		Content: []mcp.Content{{Type: "text", Text: string(text)}},
+		IsError: runErr != nil || !res.Success,
+	}
+
+	for _, f := range res.Files {
+		preview, err := filehandler.Thumbnail(f.Path, mcpPreviewSize)
+		if err != nil {
+			continue // previews are best effort
+		}
+		result.Content = append(result.Content, mcp.Content{
+			Type:     "image",
+			Data:     base64.StdEncoding.EncodeToString(preview),
+			MimeType: "image/png",
+		})
+	}
+
+	return result, nil
+}
+
+// mcpArgv converts tool arguments into a command line for the given command
+func mcpArgv(c *cobra.Command, arguments map[string]any) ([]string, error) {
+	argv := []string{c.Name()}
+	var positional []string
+	used := map[string]bool{}
+
+	for _, name := range positionalArgNames(c) {
+		v, ok := arguments[name]
+		if !ok {
+			// Optional arguments are trailing, so nothing after them can be passed either
+			if optionalArg(c, name) {
+				break
+			}
+			return nil, fmt.Errorf("missing required argument: %s", name)
+		}
+		if err := checkMCPArgValue(name, v); err != nil {
+			return nil, err
+		}
+		positional = append(positional, formatArgValue(v))
+		used[name] = true
+	}
+
+	// Sort keys so the generated command line is deterministic
+	keys := make([]string, 0, len(arguments))
+	for k := range arguments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if used[k] {
+			continue
+		}
+		if !slices.ContainsFunc(mcpFlags(c), func(f *pflag.Flag) bool { return f.Name == k }) {
+			return nil, fmt.Errorf("unknown argument: %s", k)
+		}
+
+		if err := checkMCPArgValue(k, arguments[k]); err != nil {
+			return nil, err
+		}
+		if list, ok := arguments[k].([]any); ok {
+			for _, item := range list {
+				argv = append(argv, fmt.Sprintf("--%s=%s", k, formatArgValue(item)))
+			}
+			continue
+		}
+		argv = append(argv, fmt.Sprintf("--%s=%s", k, formatArgValue(arguments[k])))
+	}
+
+	// Positional values go after "--" so a prompt starting with "-" stays literal text
+	argv = append(argv, "--")
+	return append(argv, positional...), nil
+}
+
+// checkMCPArgValue rejects "-": the server's stdin and stdout carry JSON-RPC, so tool
+// arguments can't stream prompts, images, masks, scripts or outputs through them
+func checkMCPArgValue(name string, v any) error {
+	values, ok := v.([]any)
+	if !ok {
+		values = []any{v}
+	}
+	for _, item := range values {
+		if s, ok := item.(string); ok && isStdio(s) {
+			return fmt.Errorf("argument %s: - (stdin/stdout) is not available over MCP; pass the text or a file path", name)
+		}
+	}
+	return nil
+}
+
+// formatArgValue renders a decoded JSON value as a command-line string
+func formatArgValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// resetFlags restores every flag in the set to its default value
+func resetFlags(flags *pflag.FlagSet) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			_ = sv.Replace([]string{})
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGeminiServer returns a test server that answers every request with a small PNG
+func fakeGeminiServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 8), G: uint8(y * 8), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"candidates":[{"content":{"parts":[{"inlineData":{"mimeType":"image/png","data":%q}}]}}]}`, data)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("IMAGEMAGE_BASE_URL", server.URL)
+	t.Setenv("IMAGEMAGE_LEDGER", filepath.Join(t.TempDir(), "ledger.jsonl"))
+
+	return server
+}
+
+// runMCPSession feeds the given requests to the server and returns the decoded responses
+func runMCPSession(t *testing.T, requests ...string) []map[string]any {
+	t.Helper()
+
+	in := strings.NewReader(strings.Join(requests, "\n") + "\n")
+	var out bytes.Buffer
+	if err := serveMCP(in, &out); err != nil {
+		t.Fatalf("serveMCP returned error: %v", err)
+	}
+
+	var responses []map[string]any
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var resp map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", scanner.Text(), err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestMCP_ListsToolsWithFlagSchemas(t *testing.T) {
+	responses := runMCPSession(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+	)
+
+	if len(responses) != 2 {
+		t.Fatalf("expected 2 responses (notification gets none), got %d", len(responses))
+	}
+
+	tools := responses[1]["result"].(map[string]any)["tools"].([]any)
+	byName := map[string]map[string]any{}
+	for _, tool := range tools {
+		m := tool.(map[string]any)
+		byName[m["name"].(string)] = m
+	}
+
+	for _, name := range mcpToolCommands {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("tool %q not listed", name)
+		}
+	}
+
+	props := byName["generate"]["inputSchema"].(map[string]any)["properties"].(map[string]any)
+	if props["count"].(map[string]any)["type"] != "integer" {
+		t.Errorf("expected count to be an integer, got %v", props["count"])
+	}
+	if props["frugal"].(map[string]any)["type"] != "boolean" {
+		t.Errorf("expected frugal to be a boolean, got %v", props["frugal"])
+	}
+	if _, ok := props["prompt"]; !ok {
+		t.Error("expected positional prompt argument in schema")
+	}
+
+	editProps := byName["edit"]["inputSchema"].(map[string]any)["properties"].(map[string]any)
+	if editProps["input"].(map[string]any)["type"] != "array" {
+		t.Errorf("expected edit input to be an array, got %v", editProps["input"])
+	}
+
+	// story's narrative is optional because --script can replace it
+	if required, _ := byName["story"]["inputSchema"].(map[string]any)["required"].([]any); len(required) != 0 {
+		t.Errorf("expected no required story arguments, got %v", required)
+	}
+}
+
+func TestMCP_CallGenerateWritesFileAndPreview(t *testing.T) {
+	fakeGeminiServer(t)
+	dir := t.TempDir()
+
+	call := fmt.Sprintf(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"generate","arguments":{"prompt":"a red fox","output":%q,"frugal":true}}}`, dir)
+	responses := runMCPSession(t, call)
+
+	if len(responses) != 1 {
+		t.Fatalf("expected 1 response, got %d", len(responses))
+	}
+	result := responses[0]["result"].(map[string]any)
+	if isErr, _ := result["isError"].(bool); isErr {
+		t.Fatalf("tool call failed: %v", result["content"])
+	}
+
+	content := result["content"].([]any)
+	text := content[0].(map[string]any)["text"].(string)
+	if !strings.Contains(text, dir) {
+		t.Errorf("expected result to list a file in %s, got:\n%s", dir, text)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected exactly one file in output dir, got %v (%v)", entries, err)
+	}
+
+	if len(content) < 2 || content[1].(map[string]any)["type"] != "image" {
+		t.Fatalf("expected an image preview, got %v", content)
+	}
+}
+
+func TestMCP_UnknownArgumentIsToolError(t *testing.T) {
+	responses := runMCPSession(t,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"generate","arguments":{"prompt":"x","bogus":1}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"nope"}`,
+	)
+
+	result := responses[0]["result"].(map[string]any)
+	if isErr, _ := result["isError"].(bool); !isErr {
+		t.Errorf("expected isError for unknown argument, got %v", result)
+	}
+	if responses[1]["error"] == nil {
+		t.Errorf("expected method-not-found error, got %v", responses[1])
+	}
+}
+
+func TestMCP_ArgvKeepsDashPromptLiteral(t *testing.T) {
+	argv, err := mcpArgv(generateCmd, map[string]any{"prompt": "--- retro poster ---", "frugal": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"generate", "--frugal=true", "--", "--- retro poster ---"}
+	if strings.Join(argv, " ") != strings.Join(want, " ") {
+		t.Errorf("argv = %q, want %q", argv, want)
+	}
+}
+
+func TestMCP_CallWithDashPrompt(t *testing.T) {
+	fakeGeminiServer(t)
+	dir := t.TempDir()
+
+	call := fmt.Sprintf(`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"generate","arguments":{"prompt":"--- retro poster ---","output":%q,"frugal":true}}}`, dir)
+	responses := runMCPSession(t, call)
+
+	result := responses[0]["result"].(map[string]any)
+	if isErr, _ := result["isError"].(bool); isErr {
+		t.Fatalf("tool call failed: %v", result["content"])
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatalf("expected exactly one file in output dir, got %v (%v)", entries, err)
+	}
+}
+
+func TestMCP_StdinArgumentIsRejectedWithoutReadingRequests(t *testing.T) {
+	fakeGeminiServer(t)
+
+	responses := runMCPSession(t,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"generate","arguments":{"prompt":"-","frugal":true}}}`,
+		`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"edit","arguments":{"base-image":"a.png","instruction":"x","input":["-"]}}}`,
+		`{"jsonrpc":"2.0","id":9,"method":"tools/list"}`,
+	)
+
+	if len(responses) != 3 {
+		t.Fatalf("expected 3 responses, got %d: %v", len(responses), responses)
+	}
+	for _, r := range responses[:2] {
+		result := r["result"].(map[string]any)
+		if isErr, _ := result["isError"].(bool); !isErr {
+			t.Errorf("expected isError for a - argument, got %v", result)
+		}
+		text := result["content"].([]any)[0].(map[string]any)["text"].(string)
+		if !strings.Contains(text, "not available over MCP") {
+			t.Errorf("expected a stdin error, got %q", text)
+		}
+	}
+	if responses[2]["id"].(float64) != 9 || responses[2]["result"] == nil {
+		t.Errorf("expected the request after the - call to be answered, got %v", responses[2])
+	}
+}
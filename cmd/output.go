@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"imagemage/pkg/filehandler"
+	"imagemage/pkg/gemini"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Supported values for --output-format
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+)
+
+var outputFormat string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output-format", outputFormatText, "Output format: text or json (json prints a single result object to stdout, progress to stderr)")
+}
+
+// commandResult is the structured result emitted in JSON output mode
+type commandResult struct {
+	Command     string                `json:"command"`
+	Success     bool                  `json:"success"`
+	Files       []fileResult          `json:"files"`
+	Model       string                `json:"model,omitempty"`
+	AspectRatio string                `json:"aspectRatio,omitempty"`
+	Resolution  string                `json:"resolution,omitempty"`
+	Prompt      string                `json:"prompt,omitempty"`
+	Warnings    []string              `json:"warnings,omitempty"`
+	Errors      []string              `json:"errors,omitempty"`
+	DurationMs  int64                 `json:"durationMs"`
+	Usage       *gemini.UsageMetadata `json:"usage,omitempty"`
+	Metrics     map[string]float64    `json:"metrics,omitempty"`
+
+	EstimatedCost float64       `json:"estimatedCost,omitempty"`
+	DryRun        bool          `json:"dryRun,omitempty"`
+	Plan          []plannedCall `json:"plan,omitempty"`
+}
+
+// fileResult describes a file written by a command
+type fileResult struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// reporter routes all command output. In text mode everything goes to stdout as before;
+// in JSON mode progress goes to stderr and a single commandResult is printed at the end.
+type reporter struct {
+	json   bool
+	out    io.Writer
+	log    io.Writer
+	client *gemini.Client
+	start  time.Time
+	result commandResult
+	mu     sync.Mutex // output and result may be updated from several goroutines
+
+	project       string
+	projectLoaded bool
+}
+
+// newReporter creates a reporter for the given command based on --output-format
+func newReporter(cmd *cobra.Command) *reporter {
+	r := &reporter{
+		json:   outputFormat == outputFormatJSON,
+		out:    cmd.OutOrStdout(),
+		log:    cmd.OutOrStdout(),
+		start:  time.Now(),
+		result: commandResult{Command: cmd.Name(), Files: []fileResult{}},
+	}
+	if r.json {
+		r.log = cmd.ErrOrStderr()
+	}
+	return r
+}
+
+// validateOutputFormat checks the --output-format flag
+func validateOutputFormat() error {
+	if outputFormat != outputFormatText && outputFormat != outputFormatJSON {
+		return fmt.Errorf("unsupported output format: %s (use text or json)", outputFormat)
+	}
+	return nil
+}
+
+// Printf writes human-readable progress output
+func (r *reporter) Printf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = fmt.Fprintf(r.log, format, args...)
+}
+
+// Println writes a line of human-readable progress output
+func (r *reporter) Println(args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = fmt.Fprintln(r.log, args...)
+}
+
+// Warnf records a warning and prints it
+func (r *reporter) Warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	r.mu.Lock()
+	r.result.Warnings = append(r.result.Warnings, msg)
+	r.mu.Unlock()
+	r.Printf("⚠️  %s\n", msg)
+}
+
+// Errorf records a non-fatal error (e.g. one failed image in a batch) and prints it
+func (r *reporter) Errorf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	r.mu.Lock()
+	r.result.Errors = append(r.result.Errors, msg)
+	r.mu.Unlock()
+	r.Printf("%s\n", msg)
+}
+
+// Saved records a written file and prints the given confirmation message
+func (r *reporter) Saved(path string, format string, args ...any) {
+	r.AddFile(path)
+	r.Printf(format, args...)
+}
+
+// AddFile records a written file without printing anything
+func (r *reporter) AddFile(path string) {
+	file := fileResult{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		file.Bytes = info.Size()
+	}
+	if w, h, err := filehandler.GetImageDimensions(path); err == nil {
+		file.Width, file.Height = w, h
+	}
+	r.mu.Lock()
+	r.result.Files = append(r.result.Files, file)
+	r.mu.Unlock()
+}
+
+// StreamToStdout reserves stdout for binary image data and moves all status output to stderr
+func (r *reporter) StreamToStdout(cmd *cobra.Command) error {
+	if r.json {
+		return fmt.Errorf("cannot write image data to stdout with --output-format json; write to a file instead")
+	}
+	r.log = cmd.ErrOrStderr()
+	return nil
+}
+
+// Streamed records image data written to stdout
+func (r *reporter) Streamed(size int) {
+	r.mu.Lock()
+	r.result.Files = append(r.result.Files, fileResult{Path: stdioPath, Bytes: int64(size)})
+	r.mu.Unlock()
+	r.Printf("✓ Wrote %d bytes to stdout\n", size)
+}
+
+// SetClient records the client so its model and token usage can be reported
+func (r *reporter) SetClient(client *gemini.Client) {
+	r.client = client
+	r.result.Model = client.Model()
+}
+
+// SetProject sets the project tag from a config the command already loaded
+func (r *reporter) SetProject(project string) {
+	r.project = project
+	r.projectLoaded = true
+}
+
+// Project returns the project tag for usage tracking: --tag, then the command's config,
+// then the project of the default config file
+func (r *reporter) Project() string {
+	if usageTag != "" {
+		return usageTag
+	}
+	if !r.projectLoaded {
+		config, _ := gemini.FindConfig("")
+		r.SetProject(config.GetProject())
+	}
+	return r.project
+}
+
+// SetPrompt records the final prompt sent to the model
+func (r *reporter) SetPrompt(prompt string) {
+	r.result.Prompt = prompt
+}
+
+// SetMetric records a measurement about the output, e.g. a quality score
+func (r *reporter) SetMetric(name string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.result.Metrics == nil {
+		r.result.Metrics = map[string]float64{}
+	}
+	r.result.Metrics[name] = value
+}
+
+// SetImageConfig records the aspect ratio and resolution used for generation
+func (r *reporter) SetImageConfig(aspectRatio, resolution string) {
+	r.result.AspectRatio = aspectRatio
+	r.result.Resolution = resolution
+}
+
+// Finish completes the command. In JSON mode it prints the result object.
+// The command's error, if any, is returned unchanged.
+func (r *reporter) Finish(err error) error {
+	if !r.json {
+		return err
+	}
+
+	if err != nil {
+		r.result.Errors = append(r.result.Errors, err.Error())
+	}
+	r.result.Success = err == nil && (len(r.result.Files) > 0 || len(r.result.Errors) == 0)
+	r.result.DurationMs = time.Since(r.start).Milliseconds()
+	if r.client != nil {
+		usage := r.client.Usage()
+		if usage.TotalTokenCount > 0 {
+			r.result.Usage = &usage
+		}
+	}
+
+	enc := json.NewEncoder(r.out)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(r.result); encErr != nil && err == nil {
+		return fmt.Errorf("failed to write JSON output: %w", encErr)
+	}
+
+	return err
+}
+
+// newClient creates the Gemini client for a command and registers it with the reporter
+func newClient(r *reporter, frugal bool) (*gemini.Client, error) {
+	var client *gemini.Client
+	var err error
+	if frugal {
+		client, err = gemini.NewFrugalClient()
+	} else {
+		client, err = gemini.NewClient()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+
+	client.SetCallHook(recordUsage(r))
+	r.SetClient(client)
+	return client, nil
+}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"image"
 	"imagemage/pkg/filehandler"
 	"imagemage/pkg/gemini"
+	"imagemage/pkg/texture"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -13,8 +19,19 @@ var (
 	patternType   string
 	patternStyle  string
 	patternOutput string
+
+	patternSeamThreshold float64
+	patternFixSeams      bool
+	patternPreview       bool
+	patternPBR           bool
 )
 
+// seamRepairPrompt asks the model to heal the seams after they were shifted to the center
+const seamRepairPrompt = "This image is a repeating texture tile that has been offset by half its size, so its tiling seams now form a visible horizontal and vertical line crossing the center. Repair only those center seams so the pattern flows continuously across them, matching the surrounding detail, colors, and lighting. Do not change anything else, do not add borders, and keep the exact same framing and size."
+
+// previewTileSize caps each tile in the 3x3 preview so 4K patterns stay manageable
+const previewTileSize = 1024
+
 var patternCmd = &cobra.Command{
 	Use:   "pattern [description]",
 	Short: "Create seamless patterns and textures",
@@ -23,7 +40,20 @@ var patternCmd = &cobra.Command{
 Examples:
   imagemage pattern "geometric triangles"
   imagemage pattern "floral" --type="seamless" --style="watercolor"
-  imagemage pattern "hexagons" --style="minimal, modern"`,
+  imagemage pattern "hexagons" --style="minimal, modern"
+  imagemage pattern "mossy cobblestones" --type=texture --seam-threshold=1.2
+  imagemage pattern "weathered oak planks" --type=texture --pbr
+  echo "art deco fans" | imagemage pattern - -o - > tile.png
+
+Every pattern is checked for tileability. The seam score compares the color jump across
+the wrap-around edges with the texture's normal pixel-to-pixel variation: around 1 is
+seamless. Patterns scoring above --seam-threshold are shifted by half, sent back to the
+model to heal the seams now crossing the center, and shifted back (one extra API call).
+A 3x3 tiled preview is written next to the pattern.
+
+--pbr derives material maps locally from the pattern (the albedo), written next to it as
+<name>_height.png, <name>_normal.png (OpenGL, green up), <name>_roughness.png and
+<name>_ao.png, plus a <name>.material.json descriptor listing them.`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runPattern,
 }
@@ -33,11 +63,33 @@ func init() {
 
 	patternCmd.Flags().StringVar(&patternType, "type", "seamless", "Pattern type: seamless, tiled, texture")
 	patternCmd.Flags().StringVarP(&patternStyle, "style", "s", "", "Pattern style")
-	patternCmd.Flags().StringVarP(&patternOutput, "output", "o", ".", "Output directory")
+	patternCmd.Flags().StringVarP(&patternOutput, "output", "o", ".", "Output directory (- for stdout)")
+	patternCmd.Flags().Float64Var(&patternSeamThreshold, "seam-threshold", texture.DefaultSeamThreshold, "Seam score above which the tile is repaired")
+	patternCmd.Flags().BoolVar(&patternFixSeams, "fix-seams", true, "Repair visible seams with an offset-and-inpaint pass (extra API call)")
+	patternCmd.Flags().BoolVar(&patternPreview, "preview", true, "Write a 3x3 tiled preview next to the pattern")
+	patternCmd.Flags().BoolVar(&patternPBR, "pbr", false, "Also derive height, normal, roughness and AO maps and a material descriptor")
 }
 
-func runPattern(cmd *cobra.Command, args []string) error {
-	description := args[0]
+func runPattern(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	streams := newStdio(cmd)
+	description, err := streams.text("description", args[0])
+	if err != nil {
+		return err
+	}
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(patternOutput)
+	if toStdout {
+		if patternPBR {
+			return fmt.Errorf("--pbr writes several files and cannot be combined with --output=-")
+		}
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+	}
 
 	// Build prompt
 	prompt := fmt.Sprintf("Create a %s pattern: %s", patternType, description)
@@ -46,34 +98,214 @@ func runPattern(cmd *cobra.Command, args []string) error {
 	}
 	prompt += ". The pattern should tile seamlessly and be suitable for use as a background or texture."
 
+	if imageSize != "" && (imageFit == "" || imageFit == filehandler.FitCover) {
+		rep.Warnf("Warning: --size with --fit=cover crops the tile, which breaks seamless tiling; use --fit=fill")
+	}
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run. The seam repair
+	// only runs when needed, but budget for it.
+	calls := []plannedCall{newPlannedCall(gemini.ModelName, prompt, "", "")}
+	if patternFixSeams {
+		calls[0].Label = "pattern"
+		repair := newPlannedCall(gemini.ModelName, seamRepairPrompt, "", "", plannedInput{Name: "offset pattern (generated)"})
+		repair.Label = "seam repair (only if the seams don't match)"
+		calls = append(calls, repair)
+	}
+	rep.SetPrompt(prompt)
+	if stop, err := preflight(rep, calls); stop || err != nil {
+		return err
+	}
+
 	// Create Gemini client
-	client, err := gemini.NewClient()
+	client, err := newClient(rep, false)
 	if err != nil {
-		return fmt.Errorf("failed to create Gemini client: %w", err)
+		return err
 	}
 
-	fmt.Printf("Generating %s pattern: %s\n", patternType, description)
+	rep.Printf("Generating %s pattern: %s\n", patternType, description)
 	if patternStyle != "" {
-		fmt.Printf("Style: %s\n", patternStyle)
+		rep.Printf("Style: %s\n", patternStyle)
 	}
 
+	rep.SetImageConfig("", "4K")
+
 	// Generate pattern
 	imageData, err := client.GenerateContent(prompt)
 	if err != nil {
 		return fmt.Errorf("failed to generate pattern: %w", err)
 	}
 
+	imageData, tile := enforceSeamless(rep, client, imageData)
+
+	if toStdout {
+		return streams.writeImage(rep, imageData, "")
+	}
+
 	// Generate filename
 	filename := filehandler.GenerateFilename(description, "pattern", 0)
-	outputPath := filepath.Join(patternOutput, filename)
-	outputPath = filehandler.EnsureUniqueFilename(outputPath)
+	outputPath := filehandler.EnsureUniqueFilename(outputPathFor(filepath.Join(patternOutput, filename)))
 
 	// Save pattern
-	if err := filehandler.SaveImage(imageData, outputPath); err != nil {
+	outputPath, _, err = saveImage(rep, imageData, outputPath, "")
+	if err != nil {
 		return fmt.Errorf("failed to save pattern: %w", err)
 	}
 
-	fmt.Printf("✓ Pattern saved to: %s\n", outputPath)
+	rep.Saved(outputPath, "✓ Pattern saved to: %s\n", outputPath)
+
+	// The preview and maps follow the saved pattern if --size changed its dimensions
+	if opts, err := imageOutputOptions(); err == nil && opts.Resize.Width > 0 && tile != nil {
+		tile = filehandler.Resize(tile, opts.Resize)
+	}
+
+	if patternPreview && tile != nil {
+		writePatternPreview(rep, tile, outputPath)
+	}
+
+	if patternPBR && tile != nil {
+		writeMaterial(rep, tile, description, outputPath)
+	}
 
 	return nil
 }
+
+// enforceSeamless scores the pattern's seams and, if they fail the threshold, repairs
+// them by offsetting the tile by half and asking the model to heal the center. It
+// returns the image to save and its decoded form, or a nil image if it can't be decoded.
+func enforceSeamless(rep *reporter, client *gemini.Client, imageData string) (string, image.Image) {
+	tile, err := decodeImage(imageData)
+	if err != nil {
+		rep.Warnf("Warning: could not check seams: %v", err)
+		return imageData, nil
+	}
+
+	score := texture.SeamScore(tile)
+	rep.SetMetric("seamScore", score)
+	rep.Printf("Seam score: %.2f (%s)\n", score, seamVerdict(score))
+	if score <= patternSeamThreshold || !patternFixSeams {
+		return imageData, tile
+	}
+
+	rep.Println("Repairing seams (offset and inpaint)...")
+	b := tile.Bounds()
+	w, h := b.Dx(), b.Dy()
+	shifted, err := filehandler.EncodeImage(texture.Offset(tile, w/2, h/2), filehandler.OutputOptions{Format: filehandler.FormatPNG})
+	if err != nil {
+		rep.Warnf("Warning: keeping the original pattern: %v", err)
+		return imageData, tile
+	}
+	healedData, err := client.GenerateContentWithImage(seamRepairPrompt, base64.StdEncoding.EncodeToString(shifted))
+	if err != nil {
+		rep.Warnf("Warning: keeping the original pattern, seam repair failed: %v", err)
+		return imageData, tile
+	}
+	healed, err := decodeImage(healedData)
+	if err != nil {
+		rep.Warnf("Warning: keeping the original pattern: %v", err)
+		return imageData, tile
+	}
+
+	// The model may return a different size; the shift back must use the original one
+	healed = filehandler.Resize(healed, filehandler.ResizeOptions{Width: w, Height: h, Fit: filehandler.FitFill})
+	repaired := texture.Offset(healed, -(w / 2), -(h / 2))
+
+	repairedScore := texture.SeamScore(repaired)
+	if repairedScore >= score {
+		rep.Warnf("Warning: seam repair didn't help (score %.2f); keeping the original pattern", repairedScore)
+		return imageData, tile
+	}
+	encoded, err := filehandler.EncodeImage(repaired, filehandler.OutputOptions{Format: filehandler.FormatPNG})
+	if err != nil {
+		rep.Warnf("Warning: keeping the original pattern: %v", err)
+		return imageData, tile
+	}
+
+	rep.SetMetric("seamScore", repairedScore)
+	rep.Printf("Seam score after repair: %.2f (%s)\n", repairedScore, seamVerdict(repairedScore))
+	return base64.StdEncoding.EncodeToString(encoded), repaired
+}
+
+// seamVerdict describes a seam score for humans
+func seamVerdict(score float64) string {
+	if score <= patternSeamThreshold {
+		return "tiles seamlessly"
+	}
+	return "visible seams"
+}
+
+// writePatternPreview writes a 3x3 tiling of the pattern next to it
+func writePatternPreview(rep *reporter, tile image.Image, outputPath string) {
+	b := tile.Bounds()
+	if scale := float64(previewTileSize) / float64(max(b.Dx(), b.Dy())); scale < 1 {
+		w := max(1, int(float64(b.Dx())*scale))
+		h := max(1, int(float64(b.Dy())*scale))
+		tile = filehandler.Resize(tile, filehandler.ResizeOptions{Width: w, Height: h, Fit: filehandler.FitFill})
+	}
+
+	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	previewPath := filehandler.EnsureUniqueFilename(base + "_preview3x3" + filehandler.ExtensionFor(filehandler.FormatPNG))
+	data, err := filehandler.EncodeImage(texture.Tile(tile, 3), filehandler.OutputOptions{Format: filehandler.FormatPNG})
+	if err == nil {
+		err = filehandler.WriteImageFile(previewPath, data)
+	}
+	if err != nil {
+		rep.Errorf("Error writing tiled preview: %v", err)
+		return
+	}
+	rep.Saved(previewPath, "✓ Tiled preview saved to: %s\n", previewPath)
+}
+
+// writeMaterial derives PBR maps from the saved pattern and writes them with a
+// material descriptor, all named after the pattern file
+func writeMaterial(rep *reporter, albedo image.Image, description, outputPath string) {
+	rep.Println("Deriving PBR maps...")
+	maps := texture.DeriveMaps(albedo, texture.DefaultPBROptions)
+
+	dir := filepath.Dir(outputPath)
+	base := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath))
+	material := texture.Material{
+		Name:         description,
+		Width:        albedo.Bounds().Dx(),
+		Height:       albedo.Bounds().Dy(),
+		Maps:         map[string]string{texture.MapAlbedo: filepath.Base(outputPath)},
+		NormalFormat: "opengl",
+		Wrap:         "repeat",
+		Generator:    "imagemage",
+	}
+
+	for _, m := range []struct {
+		name string
+		img  image.Image
+	}{
+		{texture.MapHeight, maps.Height},
+		{texture.MapNormal, maps.Normal},
+		{texture.MapRoughness, maps.Roughness},
+		{texture.MapAO, maps.AO},
+	} {
+		filename := base + "_" + m.name + filehandler.ExtensionFor(filehandler.FormatPNG)
+		path := filepath.Join(dir, filename)
+		data, err := filehandler.EncodeImage(m.img, filehandler.OutputOptions{Format: filehandler.FormatPNG})
+		if err == nil {
+			err = filehandler.WriteImageFile(path, data)
+		}
+		if err != nil {
+			rep.Errorf("Error writing %s map: %v", m.name, err)
+			continue
+		}
+		material.Maps[m.name] = filename
+		rep.Saved(path, "✓ %s map saved to: %s\n", m.name, path)
+	}
+
+	data, err := json.MarshalIndent(material, "", "  ")
+	if err != nil {
+		rep.Errorf("Error encoding material: %v", err)
+		return
+	}
+	path := filepath.Join(dir, base+".material.json")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		rep.Errorf("Error writing material: %v", err)
+		return
+	}
+	rep.AddFile(path)
+	rep.Printf("✓ Material saved to: %s\n", path)
+}
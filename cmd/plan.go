@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"imagemage/pkg/filehandler"
+	"imagemage/pkg/gemini"
+	"strings"
+)
+
+var (
+	dryRun  bool
+	maxCost float64
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Show the exact requests and estimated cost without calling the API")
+	rootCmd.PersistentFlags().Float64Var(&maxCost, "max-cost", 0, "Abort before calling the API if the estimated cost in USD exceeds this budget (0 = no limit)")
+}
+
+// plannedCall describes an API request a command is about to make
+type plannedCall struct {
+	Label         string              `json:"label,omitempty"`
+	Count         int                 `json:"count"`
+	Model         string              `json:"model"`
+	Prompt        string              `json:"prompt"`
+	ImageConfig   *gemini.ImageConfig `json:"imageConfig"`
+	Text          bool                `json:"text,omitempty"` // answered in text; no image is generated
+	Inputs        []plannedInput      `json:"inputs,omitempty"`
+	EstimatedCost float64             `json:"estimatedCost"`
+}
+
+// plannedInput describes an input image attached to a planned request
+type plannedInput struct {
+	Name   string `json:"name"`
+	Bytes  int    `json:"bytes"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// modelName returns the model used for the default or frugal client
+func modelName(frugal bool) string {
+	if frugal {
+		return gemini.ModelNameFrugal
+	}
+	return gemini.ModelName
+}
+
+// newPlannedCall describes a single request with the given inputs
+func newPlannedCall(model, prompt, resolution, aspectRatio string, inputs ...plannedInput) plannedCall {
+	return plannedCall{
+		Count:         1,
+		Model:         model,
+		Prompt:        prompt,
+		ImageConfig:   gemini.NewImageConfig(model, resolution, aspectRatio),
+		Inputs:        inputs,
+		EstimatedCost: gemini.EstimateCost(model, resolution, prompt, len(inputs)),
+	}
+}
+
+// newTextCall describes a single request answered in text, e.g. reading an image's labels
+func newTextCall(model, prompt string, inputs ...plannedInput) plannedCall {
+	return plannedCall{
+		Count:         1,
+		Model:         model,
+		Prompt:        prompt,
+		Text:          true,
+		Inputs:        inputs,
+		EstimatedCost: gemini.EstimateCost(model, "", prompt, len(inputs)),
+	}
+}
+
+// imageInput describes base64 image data attached to a request
+func imageInput(name, imageBase64 string) plannedInput {
+	input := plannedInput{Name: displayPath(name)}
+	data, err := base64.StdEncoding.DecodeString(imageBase64)
+	if err != nil {
+		return input
+	}
+	input.Bytes = len(data)
+	if w, h, err := filehandler.GetImageDimensionsFromData(data); err == nil {
+		input.Width, input.Height = w, h
+	}
+	return input
+}
+
+// planTotal returns the number of images and the estimated total cost of the planned calls
+func planTotal(calls []plannedCall) (int, float64) {
+	images := 0
+	total := 0.0
+	for _, c := range calls {
+		if !c.Text {
+			images += c.Count
+		}
+		total += float64(c.Count) * c.EstimatedCost
+	}
+	return images, total
+}
+
+// preflight estimates the cost of the planned calls, enforces --max-cost and handles --dry-run.
+// It returns true when the command should stop without calling the API.
+func preflight(rep *reporter, calls []plannedCall) (bool, error) {
+	images, total := planTotal(calls)
+	rep.result.EstimatedCost = total
+	if rep.result.Model == "" && len(calls) > 0 {
+		rep.result.Model = calls[0].Model
+	}
+
+	if dryRun {
+		rep.Plan(calls)
+	}
+
+	if maxCost > 0 && total > maxCost {
+		return true, fmt.Errorf("estimated cost $%.4f for %d image(s) exceeds --max-cost $%.4f", total, images, maxCost)
+	}
+
+	return dryRun, nil
+}
+
+// Plan records and prints the requests a dry run would have made
+func (r *reporter) Plan(calls []plannedCall) {
+	r.result.DryRun = true
+	r.result.Plan = calls
+
+	r.Println("Dry run - no API calls will be made")
+	for i, c := range calls {
+		header := fmt.Sprintf("Request %d", i+1)
+		if c.Label != "" {
+			header += " - " + c.Label
+		}
+		if c.Count > 1 {
+			header += fmt.Sprintf(" (x%d)", c.Count)
+		}
+		r.Printf("\n%s:\n", header)
+		r.Printf("  Model:        %s\n", c.Model)
+		if c.ImageConfig != nil {
+			if config, err := json.Marshal(c.ImageConfig); err == nil {
+				r.Printf("  Image config: %s\n", config)
+			}
+		}
+		for _, in := range c.Inputs {
+			if details := describeInput(in); details != "" {
+				r.Printf("  Input:        %s (%s)\n", in.Name, details)
+			} else {
+				r.Printf("  Input:        %s\n", in.Name)
+			}
+		}
+		r.Printf("  Prompt:       %s\n", c.Prompt)
+		if c.Text {
+			r.Printf("  Est. cost:    $%.4f per request\n", c.EstimatedCost)
+		} else {
+			r.Printf("  Est. cost:    $%.4f per image\n", c.EstimatedCost)
+		}
+	}
+
+	images, total := planTotal(calls)
+	r.Printf("\nEstimated total: $%.4f for %d image(s)\n", total, images)
+}
+
+// describeInput formats an input image's dimensions and size
+func describeInput(in plannedInput) string {
+	parts := []string{}
+	if in.Width > 0 {
+		parts = append(parts, fmt.Sprintf("%dx%d", in.Width, in.Height))
+	}
+	// Inputs generated earlier in the run have no size yet
+	if in.Bytes > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f KB", float64(in.Bytes)/1024))
+	}
+	return strings.Join(parts, ", ")
+}
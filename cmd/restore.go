@@ -1,80 +1,528 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"imagemage/pkg/filehandler"
 	"imagemage/pkg/gemini"
+	"imagemage/pkg/journal"
+	"imagemage/pkg/restore"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	restoreOutput string
+	restoreOutput      string
+	restoreMode        string
+	restoreStrength    string
+	restoreEra         string
+	restoreResolution  string
+	restoreFrugal      bool
+	restoreForce       bool
+	restoreStorePrompt bool
+	restoreOutputDir   string
+	restoreRecursive   bool
+	restoreConcurrency int
+	restoreResume      bool
+	restoreCompare     bool
+	restoreIndex       bool
 )
 
+// restoreJournalName is the file in --output-dir that records finished photos for --resume
+const restoreJournalName = ".imagemage-restore.jsonl"
+
 var restoreCmd = &cobra.Command{
 	Use:   "restore [image-path]",
 	Short: "Enhance and repair photos",
 	Long: `Restore old photos, enhance quality, remove noise, and improve overall image quality.
 
+Without --mode, restore does a general all-round cleanup. Modes focus it and can be
+combined: colorize, repair (scratches, tears, stains), denoise, deblur, face and
+upscale. The output keeps the input's aspect ratio.
+
+Pass a directory, a glob or several photos to restore a whole batch. Results go to
+--output-dir with the same folder structure, several photos at a time. Finished
+photos are recorded so an interrupted batch picks up where it stopped with --resume.
+--compare adds side-by-side before/after images and --index an HTML page to review
+them all.
+
 Examples:
   imagemage restore old_photo.png
-  imagemage restore damaged.jpg --output=restored.png`,
-	Args: cobra.ExactArgs(1),
+  imagemage restore damaged.jpg --output=restored.png
+  imagemage restore grandpa.jpg --mode repair,colorize --era "1940s"
+  imagemage restore blurry.jpg --mode deblur,face --strength subtle
+  imagemage restore small.png --mode upscale --resolution 4K
+  imagemage edit - "crop" < in.png | imagemage restore - > restored.png
+  imagemage restore scans/ --recursive --output-dir restored --compare --index
+  imagemage restore "scans/1950s/*.jpg" --mode repair,colorize --concurrency 8
+  imagemage restore scans/ --recursive --output-dir restored --resume`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: runRestore,
 }
 
 func init() {
 	rootCmd.AddCommand(restoreCmd)
 
-	restoreCmd.Flags().StringVarP(&restoreOutput, "output", "o", "", "Output path for restored image")
+	restoreCmd.Flags().StringVarP(&restoreOutput, "output", "o", "", "Output path for restored image (default: image_restored.png, - for stdout; default for piped input)")
+	restoreCmd.Flags().StringVarP(&restoreMode, "mode", "m", "", "Restoration modes, comma-separated: colorize, repair, denoise, deblur, face, upscale (default: general restoration)")
+	restoreCmd.Flags().StringVar(&restoreStrength, "strength", restore.StrengthModerate, "How far to go: subtle, moderate or strong")
+	restoreCmd.Flags().StringVar(&restoreEra, "era", "", "When the photo was taken, e.g. \"1920s\" or \"Victorian\", to guide --mode colorize")
+	restoreCmd.Flags().StringVarP(&restoreResolution, "resolution", "r", "", "Image resolution (1K, 2K, 4K). Defaults to 4K for Pro model, 1K for --frugal")
+	restoreCmd.Flags().BoolVarP(&restoreFrugal, "frugal", "f", false, "Use the cheaper gemini-2.5-flash-image model")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Overwrite output file if it exists")
+	restoreCmd.Flags().BoolVar(&restoreStorePrompt, "store-prompt", false, "Store the restoration prompt in PNG metadata")
+	restoreCmd.Flags().StringVar(&restoreOutputDir, "output-dir", "restored", "Batch: directory for the results, mirroring the input folders")
+	restoreCmd.Flags().BoolVarP(&restoreRecursive, "recursive", "R", false, "Batch: include photos in subdirectories")
+	restoreCmd.Flags().IntVarP(&restoreConcurrency, "concurrency", "j", 4, "Batch: photos restored at the same time")
+	restoreCmd.Flags().BoolVar(&restoreResume, "resume", false, "Batch: skip photos finished by an earlier run")
+	restoreCmd.Flags().BoolVar(&restoreCompare, "compare", false, "Batch: also write a side-by-side before/after image for each photo")
+	restoreCmd.Flags().BoolVar(&restoreIndex, "index", false, "Batch: write index.html in --output-dir to review every photo before and after")
 }
 
-func runRestore(cmd *cobra.Command, args []string) error {
+func runRestore(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	modes, err := restore.ParseModes(restoreMode)
+	if err != nil {
+		return err
+	}
+	if err := restore.ValidateStrength(restoreStrength); err != nil {
+		return err
+	}
+	if restoreEra != "" && !slices.Contains(modes, restore.ModeColorize) {
+		return fmt.Errorf("--era guides colorization; add --mode colorize")
+	}
+	if restoreFrugal {
+		if restoreResolution != "" {
+			return fmt.Errorf("--frugal mode has fixed 1024px output and does not accept --resolution parameter. Gemini 2.5 Flash always outputs at 1024px. Remove --resolution or --frugal flag")
+		}
+		if slices.Contains(modes, restore.ModeUpscale) {
+			return fmt.Errorf("--mode upscale needs 2K or 4K output, but --frugal is fixed at 1024px. Remove --frugal")
+		}
+	}
+
+	if restore.IsBatch(args) {
+		return runRestoreBatch(rep, args, modes)
+	}
+	for _, name := range []string{"output-dir", "recursive", "concurrency", "resume", "compare", "index"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s is for batches; pass a directory, a glob or several photos", name)
+		}
+	}
+
 	imagePath := args[0]
+	streams := newStdio(cmd)
+
+	// Check if the image exists
+	if !isStdio(imagePath) {
+		if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+			return fmt.Errorf("image not found: %s", imagePath)
+		}
+	}
 
-	fmt.Printf("Loading image: %s\n", imagePath)
+	// Determine output path (piped input defaults to piped output)
+	outputPath := restoreOutput
+	if outputPath == "" {
+		if isStdio(imagePath) {
+			outputPath = stdioPath
+		} else {
+			ext := filepath.Ext(imagePath)
+			base := strings.TrimSuffix(imagePath, ext)
+			outputPath = base + "_restored" + ext
+		}
+	}
+	outputPath = outputPathFor(outputPath)
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(outputPath)
+	if toStdout {
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+	}
+
+	// Check if output exists
+	if !restoreForce && !toStdout {
+		if _, err := os.Stat(outputPath); err == nil {
+			return fmt.Errorf("output file already exists: %s (use --force to overwrite)", outputPath)
+		}
+	}
+
+	rep.Printf("Loading image: %s\n", displayPath(imagePath))
 
 	// Load image as base64
-	imageBase64, err := filehandler.LoadImageAsBase64(imagePath)
+	imageBase64, err := streams.image("image", imagePath)
 	if err != nil {
 		return fmt.Errorf("failed to load image: %w", err)
 	}
 
+	// Keep the input's aspect ratio unless an exact --size asks for another
+	aspectRatio := sizeAspectRatio()
+	if aspectRatio == "" {
+		width, height, err := base64ImageDimensions(imageBase64)
+		if err != nil {
+			rep.Warnf("Could not detect image dimensions: %v", err)
+		} else {
+			aspectRatio = gemini.FindClosestAspectRatio(width, height)
+		}
+	}
+
+	resolution := restoreOutputResolution(modes)
+	prompt := restore.Prompt(modes, restoreStrength, restoreEra)
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(prompt)
+	if stop, err := preflight(rep, []plannedCall{newPlannedCall(modelName(restoreFrugal), prompt, resolution, aspectRatio, imageInput(imagePath, imageBase64))}); stop || err != nil {
+		return err
+	}
+
 	// Create Gemini client
-	client, err := gemini.NewClient()
+	client, err := newClient(rep, restoreFrugal)
 	if err != nil {
-		return fmt.Errorf("failed to create Gemini client: %w", err)
+		return err
 	}
 
-	prompt := "Restore and enhance this photo. Remove noise, improve clarity, fix any damage or artifacts, enhance colors naturally, and improve overall quality while preserving the original character of the image."
+	if aspectRatio != "" {
+		rep.Printf("Aspect Ratio: %s (from input)\n", aspectRatio)
+	}
+	shownResolution := printRestoreSettings(rep, modes, resolution)
+	rep.Println("\nRestoring photo...")
 
-	fmt.Println("Restoring and enhancing photo...")
+	rep.SetImageConfig(aspectRatio, shownResolution)
 
 	// Generate restored image
-	restoredImageData, err := client.GenerateContentWithImage(prompt, imageBase64)
+	restoredImageData, err := client.GenerateContentWithFullOptions(prompt, []string{imageBase64}, resolution, aspectRatio)
 	if err != nil {
 		return fmt.Errorf("failed to restore image: %w", err)
 	}
 
-	// Determine output path
-	outputPath := restoreOutput
-	if outputPath == "" {
-		ext := filepath.Ext(imagePath)
-		base := strings.TrimSuffix(imagePath, ext)
-		outputPath = base + "_restored" + ext
+	storedPrompt := ""
+	if restoreStorePrompt {
+		storedPrompt = prompt
+	}
+	if toStdout {
+		return streams.writeImage(rep, restoredImageData, storedPrompt)
 	}
 
-	outputPath = filehandler.EnsureUniqueFilename(outputPath)
-
-	// Save restored image
-	if err := filehandler.SaveImage(restoredImageData, outputPath); err != nil {
+	// Save restored image, storing the prompt in metadata if requested
+	outputPath, promptStored, err := saveImage(rep, restoredImageData, outputPath, storedPrompt)
+	if err != nil {
 		return fmt.Errorf("failed to save restored image: %w", err)
 	}
 
-	fmt.Printf("✓ Restored image saved to: %s\n", outputPath)
+	rep.Saved(outputPath, "✓ Restored image saved to: %s\n", outputPath)
+	if promptStored {
+		rep.Printf("  (prompt stored in metadata)\n")
+	}
+
+	return nil
+}
+
+// restoreOutputResolution returns the --resolution to request; upscaling is pointless
+// below the largest output
+func restoreOutputResolution(modes []string) string {
+	if restoreResolution == "" && slices.Contains(modes, restore.ModeUpscale) {
+		return "4K"
+	}
+	return restoreResolution
+}
+
+// printRestoreSettings shows the modes, era, resolution and model and returns the
+// resolution as shown
+func printRestoreSettings(rep *reporter, modes []string, resolution string) string {
+	if len(modes) > 0 {
+		rep.Printf("Modes: %s (%s)\n", strings.Join(modes, ", "), restoreStrength)
+	} else {
+		rep.Printf("Mode: general restoration (%s)\n", restoreStrength)
+	}
+	if restoreEra != "" {
+		rep.Printf("Era: %s\n", restoreEra)
+	}
+	if restoreFrugal {
+		rep.Printf("Resolution: 1024px (fixed)\n")
+		rep.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
+		return "1024px"
+	}
+	if resolution == "" {
+		resolution = "4K"
+	}
+	rep.Printf("Resolution: %s\n", resolution)
+	rep.Printf("Model: %s\n", gemini.ModelName)
+	return resolution
+}
+
+// restoreTask is a photo a batch still has to restore
+type restoreTask struct {
+	job    restore.Job
+	output string
+}
+
+// runRestoreBatch restores every photo named by files, directories and globs into
+// --output-dir, mirroring their folders
+func runRestoreBatch(rep *reporter, args []string, modes []string) error {
+	if restoreOutput != "" {
+		return fmt.Errorf("--output names a single file; use --output-dir for a batch")
+	}
+	if slices.ContainsFunc(args, isStdio) {
+		return fmt.Errorf("piped input can't be restored in a batch; pass it on its own")
+	}
+	if restoreConcurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+
+	jobs, err := restore.Expand(args, restoreRecursive, restoreOutputDir)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no photos found in %s (use --recursive to include subdirectories)", strings.Join(args, ", "))
+	}
+
+	done, err := journal.Open(filepath.Join(restoreOutputDir, restoreJournalName))
+	if err != nil {
+		return err
+	}
+
+	outputs, err := restoreBatchOutputs(jobs, restoreOutputDir)
+	if err != nil {
+		return err
+	}
+
+	// Work out what's left, refusing to overwrite results unless asked to
+	var tasks []restoreTask
+	var existing []string
+	finished := 0
+	for i, job := range jobs {
+		output := outputs[i]
+		if restoreResume {
+			if entry, ok := done.Done(job.Rel); ok && fileExists(entry.Output) {
+				finished++
+				continue
+			}
+		}
+		// Files the journal doesn't know about weren't made by this batch
+		if !restoreForce && fileExists(output) {
+			existing = append(existing, output)
+		}
+		tasks = append(tasks, restoreTask{job: job, output: output})
+	}
+	if len(existing) > 0 {
+		if restoreResume {
+			return fmt.Errorf("%d files in %s weren't restored by this batch, e.g. %s (use --force to overwrite them)", len(existing), restoreOutputDir, existing[0])
+		}
+		return fmt.Errorf("%d results already exist in %s, e.g. %s (use --resume to skip finished photos or --force to overwrite)", len(existing), restoreOutputDir, existing[0])
+	}
+
+	resolution := restoreOutputResolution(modes)
+	prompt := restore.Prompt(modes, restoreStrength, restoreEra)
+
+	rep.Printf("Found %d photos", len(jobs))
+	if finished > 0 {
+		rep.Printf(", %d already restored", finished)
+	}
+	rep.Printf("\n")
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(prompt)
+	if len(tasks) > 0 {
+		call := newPlannedCall(modelName(restoreFrugal), prompt, resolution, sizeAspectRatio(), plannedInput{Name: "each photo"})
+		call.Count = len(tasks)
+		if stop, err := preflight(rep, []plannedCall{call}); stop || err != nil {
+			return err
+		}
+	}
+
+	if !restoreResume {
+		if err := done.Reset(); err != nil {
+			return err
+		}
+	}
+
+	failures := 0
+	if len(tasks) > 0 {
+		client, err := newClient(rep, restoreFrugal)
+		if err != nil {
+			return err
+		}
+
+		shownResolution := printRestoreSettings(rep, modes, resolution)
+		rep.SetImageConfig(sizeAspectRatio(), shownResolution)
+		rep.Printf("\nRestoring %d photos, %d at a time...\n", len(tasks), min(restoreConcurrency, len(tasks)))
+
+		var wg sync.WaitGroup
+		var completed, failed atomic.Int32
+		queue := make(chan restoreTask)
+		for range min(restoreConcurrency, len(tasks)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for task := range queue {
+					output, err := restorePhoto(rep, client, prompt, resolution, task)
+					if err == nil {
+						err = done.Mark(task.job.Rel, output)
+					}
+					n := completed.Add(1)
+					if err != nil {
+						failed.Add(1)
+						rep.Errorf("[%d/%d] Error restoring %s: %v", n, len(tasks), task.job.Input, err)
+						continue
+					}
+					rep.Printf("[%d/%d] ✓ %s → %s\n", n, len(tasks), task.job.Input, output)
+				}
+			}()
+		}
+		for _, task := range tasks {
+			queue <- task
+		}
+		close(queue)
+		wg.Wait()
+
+		rep.SetMetric("photosRestored", float64(len(tasks)-int(failed.Load())))
+		rep.SetMetric("photosFailed", float64(failed.Load()))
+		rep.Printf("\n✓ Restored %d of %d photos into %s\n", len(tasks)-int(failed.Load()), len(tasks), restoreOutputDir)
+		failures = int(failed.Load())
+	} else {
+		rep.Printf("✓ Nothing left to restore\n")
+	}
+	rep.SetMetric("photosSkipped", float64(finished))
+
+	if restoreIndex {
+		writeRestoreIndex(rep, jobs, done)
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d photos failed; rerun with --resume to retry them", failures, len(tasks))
+	}
+	return nil
+}
+
+// restoreBatchOutputs returns where each photo of a batch is saved, failing when two
+// would be saved to the same file (a.gif and a.png both become a.png)
+func restoreBatchOutputs(jobs []restore.Job, dir string) ([]string, error) {
+	outputs := make([]string, len(jobs))
+	seen := map[string]string{}
+	for i, job := range jobs {
+		outputs[i] = outputPathFor(filepath.Join(dir, filepath.FromSlash(job.Rel)))
+		if other, ok := seen[outputs[i]]; ok {
+			return nil, fmt.Errorf("%s and %s would both be saved as %s; rename one or restore them separately", other, job.Input, outputs[i])
+		}
+		seen[outputs[i]] = job.Input
+	}
+	return outputs, nil
+}
+
+// restorePhoto restores one photo of a batch and returns where it was saved
+func restorePhoto(rep *reporter, client *gemini.Client, prompt, resolution string, task restoreTask) (string, error) {
+	imageBase64, err := filehandler.LoadImageAsBase64(task.job.Input)
+	if err != nil {
+		return "", fmt.Errorf("failed to load image: %w", err)
+	}
+
+	// Each photo keeps its own aspect ratio unless an exact --size asks for another
+	aspectRatio := sizeAspectRatio()
+	if aspectRatio == "" {
+		if width, height, err := base64ImageDimensions(imageBase64); err == nil {
+			aspectRatio = gemini.FindClosestAspectRatio(width, height)
+		}
+	}
+
+	restoredImageData, err := client.GenerateContentWithFullOptions(prompt, []string{imageBase64}, resolution, aspectRatio)
+	if err != nil {
+		return "", err
+	}
+
+	storedPrompt := ""
+	if restoreStorePrompt {
+		storedPrompt = prompt
+	}
+	output, _, err := saveImage(rep, restoredImageData, task.output, storedPrompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to save restored image: %w", err)
+	}
+	rep.AddFile(output)
 
+	if restoreCompare {
+		if err := writeRestoreComparison(rep, imageBase64, restoredImageData, output); err != nil {
+			// The restoration itself is fine; only the review aid is missing
+			rep.Warnf("Warning: failed to write comparison for %s: %v", task.job.Input, err)
+		}
+	}
+	return output, nil
+}
+
+// restoreComparisonPath returns where the before/after image for a result goes
+func restoreComparisonPath(output string) string {
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+	return base + "_compare" + filehandler.ExtensionFor(filehandler.FormatJPEG)
+}
+
+// writeRestoreComparison writes the original and the restoration side by side next
+// to the result
+func writeRestoreComparison(rep *reporter, before, after, output string) error {
+	beforeImg, err := decodeImage(before)
+	if err != nil {
+		return err
+	}
+	afterImg, err := decodeImage(after)
+	if err != nil {
+		return err
+	}
+	data, err := filehandler.EncodeImage(restore.Compare(beforeImg, afterImg), filehandler.OutputOptions{Format: filehandler.FormatJPEG})
+	if err != nil {
+		return err
+	}
+	path := restoreComparisonPath(output)
+	if err := filehandler.WriteImageFile(path, data); err != nil {
+		return err
+	}
+	rep.AddFile(path)
 	return nil
 }
+
+// writeRestoreIndex writes index.html in --output-dir covering every finished photo
+// of the batch, including those restored by earlier runs
+func writeRestoreIndex(rep *reporter, jobs []restore.Job, done *journal.Journal) {
+	var entries []restore.IndexEntry
+	for _, job := range jobs {
+		result, ok := done.Done(job.Rel)
+		if !ok || !fileExists(result.Output) {
+			continue
+		}
+		entry := restore.IndexEntry{
+			Name:   job.Rel,
+			Before: restore.IndexLink(restoreOutputDir, job.Input),
+			After:  restore.IndexLink(restoreOutputDir, result.Output),
+		}
+		if compare := restoreComparisonPath(result.Output); fileExists(compare) {
+			entry.Compare = restore.IndexLink(restoreOutputDir, compare)
+		}
+		entries = append(entries, entry)
+	}
+
+	var page bytes.Buffer
+	path := filepath.Join(restoreOutputDir, "index.html")
+	err := restore.WriteIndex(&page, "Restored photos", entries)
+	if err == nil {
+		err = os.MkdirAll(restoreOutputDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, page.Bytes(), 0644)
+	}
+	if err != nil {
+		rep.Errorf("Error writing index: %v", err)
+		return
+	}
+	rep.Saved(path, "✓ Review page saved to: %s\n", path)
+}
+
+// fileExists reports whether path names an existing file
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
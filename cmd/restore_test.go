@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"imagemage/pkg/restore"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreBatchOutputsRejectsCollisions(t *testing.T) {
+	jobs := []restore.Job{
+		{Input: "scans/a.gif", Rel: "a.gif"},
+		{Input: "scans/a.png", Rel: "a.png"},
+	}
+	if _, err := restoreBatchOutputs(jobs, "restored"); err == nil || !strings.Contains(err.Error(), "scans/a.gif") {
+		t.Errorf("expected a collision between a.gif and a.png, got %v", err)
+	}
+
+	// --format changes extensions, so different photos can collide too
+	defer func(format string) { imageFormat = format }(imageFormat)
+	imageFormat = "jpeg"
+	jobs = []restore.Job{
+		{Input: "scans/b.png", Rel: "b.png"},
+		{Input: "scans/b.jpg", Rel: "b.jpg"},
+	}
+	if _, err := restoreBatchOutputs(jobs, "restored"); err == nil {
+		t.Error("expected a collision between b.png and b.jpg with --format jpeg")
+	}
+
+	imageFormat = ""
+	jobs = []restore.Job{
+		{Input: "scans/a.png", Rel: "a.png"},
+		{Input: "scans/sub/a.png", Rel: "sub/a.png"},
+	}
+	outputs, err := restoreBatchOutputs(jobs, "restored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs[1] != filepath.Join("restored", "sub", "a.png") {
+		t.Errorf("outputs = %v", outputs)
+	}
+}
@@ -27,6 +27,12 @@ Supports multiple Gemini models:
 
 Features include text-to-image creation, image editing, photo restoration, icon generation,
 pattern creation, visual narratives, and technical diagrams.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+		return validateImageOptions()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
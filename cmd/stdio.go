@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"imagemage/pkg/filehandler"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// stdioPath is the path argument meaning stdin (for inputs) or stdout (for outputs)
+const stdioPath = "-"
+
+// isStdio reports whether a path argument refers to stdin/stdout
+func isStdio(path string) bool {
+	return path == stdioPath
+}
+
+// stdio resolves "-" arguments against the command's stdin and stdout.
+// Stdin can only be consumed once per command invocation.
+type stdio struct {
+	in         io.Reader
+	out        io.Writer
+	stdinOwner string
+}
+
+// newStdio creates a stdio helper for the given command
+func newStdio(cmd *cobra.Command) *stdio {
+	return &stdio{in: cmd.InOrStdin(), out: cmd.OutOrStdout()}
+}
+
+// readStdin reads all of stdin on behalf of the named argument
+func (s *stdio) readStdin(owner string) ([]byte, error) {
+	if s.stdinOwner != "" {
+		return nil, fmt.Errorf("cannot read %s from stdin: stdin is already used for %s", owner, s.stdinOwner)
+	}
+	s.stdinOwner = owner
+
+	data, err := io.ReadAll(s.in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from stdin: %w", owner, err)
+	}
+	return data, nil
+}
+
+// text returns a text argument, reading it from stdin when given as "-"
+func (s *stdio) text(owner, arg string) (string, error) {
+	if !isStdio(arg) {
+		return arg, nil
+	}
+
+	data, err := s.readStdin(owner)
+	if err != nil {
+		return "", err
+	}
+
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", fmt.Errorf("%s read from stdin is empty", owner)
+	}
+	return text, nil
+}
+
+// image loads an image argument as base64, reading it from stdin when given as "-"
+func (s *stdio) image(owner, path string) (string, error) {
+	if !isStdio(path) {
+		return filehandler.LoadImageAsBase64(path)
+	}
+
+	data, err := s.readStdin(owner)
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("%s read from stdin is empty", owner)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// write sends raw image bytes to stdout
+func (s *stdio) write(data []byte) error {
+	if _, err := s.out.Write(data); err != nil {
+		return fmt.Errorf("failed to write image to stdout: %w", err)
+	}
+	return nil
+}
+
+// writeImage decodes base64 image data, encodes it in the requested output format and
+// sends it to stdout, optionally embedding a prompt
+func (s *stdio) writeImage(rep *reporter, imageData, storedPrompt string) error {
+	data, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		return fmt.Errorf("failed to decode image data: %w", err)
+	}
+	return s.writeImageBytes(rep, data, storedPrompt)
+}
+
+// writeImageBytes is writeImage for raw image bytes
+func (s *stdio) writeImageBytes(rep *reporter, data []byte, storedPrompt string) error {
+	encoded, _, _, err := encodeForOutput(rep, data, stdioPath, storedPrompt)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(encoded); err != nil {
+		return err
+	}
+	rep.Streamed(len(encoded))
+	return nil
+}
+
+// displayPath returns a human-readable name for a path argument
+func displayPath(path string) string {
+	if isStdio(path) {
+		return "<stdin>"
+	}
+	return filepath.Base(path)
+}
+
+// base64ImageDimensions returns the width and height of base64 encoded image data
+func base64ImageDimensions(imageData string) (int, int, error) {
+	data, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image data: %w", err)
+	}
+	return filehandler.GetImageDimensionsFromData(data)
+}
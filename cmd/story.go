@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"image"
+	"image/png"
 	"imagemage/pkg/filehandler"
 	"imagemage/pkg/gemini"
+	"imagemage/pkg/storyboard"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -13,6 +21,47 @@ var (
 	storyFrames int
 	storyOutput string
 	storyStyle  string
+
+	storyReferences     []string
+	storyReferenceSheet bool
+	storyIndependent    bool
+	storyScript         string
+	storyPackage        string
+	storyDelay          time.Duration
+)
+
+// storyFrame is one frame to generate
+type storyFrame struct {
+	prompt    string
+	caption   string
+	inputPath string // optional per-frame input image
+	input     string // loaded input image, base64
+	delay     time.Duration
+}
+
+// packagedFrame is a generated frame kept for --package
+type packagedFrame struct {
+	number  int
+	image   image.Image
+	caption string
+	delay   time.Duration
+}
+
+// Roles of the images attached to a frame request, in the order they're attached
+const (
+	roleReference  = "character and style reference"
+	roleFrameInput = "input image for this frame"
+	rolePrevious   = "previous frame"
+)
+
+// continuityInputSize caps the longest side of images passed back to the model for
+// continuity; references only need to carry likeness, not every pixel of a 4K frame
+const continuityInputSize = 1024
+
+// Package size limits: animations stay light, comics and PDFs keep more detail
+const (
+	animationFrameSize = 1024
+	packageFrameSize   = 2048
 )
 
 var storyCmd = &cobra.Command{
@@ -23,9 +72,44 @@ var storyCmd = &cobra.Command{
 Examples:
   imagemage story "a seed growing into a tree" --frames=4
   imagemage story "day to night transition in a city" --frames=6 --style="cinematic"
-  imagemage story "character transformation" --frames=3`,
-	Args: cobra.MinimumNArgs(1),
-	RunE: runStory,
+  imagemage story "character transformation" --frames=3
+  imagemage story "a fox's journey through the seasons" --frames=4 --reference-sheet
+  imagemage story "our mascot saves the day" --reference=mascot.png --reference=palette.png
+  imagemage story "abstract color studies" --frames=5 --independent
+  imagemage story --script storyboard.md
+  imagemage story --script storyboard.yaml --reference-sheet
+  imagemage story "a paper boat's voyage" --frames=6 --package=gif,comic
+  imagemage story --script storyboard.md --package=pdf
+
+Each frame after the first is generated with the previous frame as an input, so characters,
+palette and setting carry over. --reference adds your own reference images to every frame;
+--reference-sheet first generates a character and style sheet and uses it the same way.
+--independent generates every frame from text alone.
+
+--script replaces the narrative with a storyboard file (.md or .yaml) declaring the style,
+setting and characters once, then each frame's description, shot, caption and optional
+input image. The frame count comes from the script. A markdown script looks like:
+
+  # The Fox's Year
+  Style: watercolor, soft morning light
+
+  ## Characters
+  - **Fen**: a small red fox with a white-tipped tail and a green scarf
+
+  ## Frame 1
+  Fen wakes up in a snowy den.
+  Shot: wide establishing shot
+  Caption: Winter
+  Input: sketches/den.png
+  Duration: 2s
+
+--package bundles the frames: gif and apng are looping animations (each frame shows for
+--delay, or its script duration), comic lays the frames out as a page with captions,
+and pdf writes a storyboard with one frame and caption per page.`,
+	Args: cobra.MaximumNArgs(1),
+	// The narrative is optional with --script
+	Annotations: map[string]string{optionalArgsAnnotation: "narrative"},
+	RunE:        runStory,
 }
 
 func init() {
@@ -34,73 +118,404 @@ func init() {
 	storyCmd.Flags().IntVarP(&storyFrames, "frames", "f", 3, "Number of frames/scenes to generate")
 	storyCmd.Flags().StringVarP(&storyStyle, "style", "s", "", "Visual style for the story")
 	storyCmd.Flags().StringVarP(&storyOutput, "output", "o", ".", "Output directory")
+	storyCmd.Flags().StringArrayVar(&storyReferences, "reference", []string{}, "Reference image for characters and style, passed to every frame (can be used multiple times, - for stdin)")
+	storyCmd.Flags().BoolVar(&storyReferenceSheet, "reference-sheet", false, "Generate a character and style reference sheet first and pass it to every frame (extra API call)")
+	storyCmd.Flags().BoolVar(&storyIndependent, "independent", false, "Generate each frame from text alone, without the previous frame or references")
+	storyCmd.Flags().StringVar(&storyScript, "script", "", "Storyboard script (.md or .yaml, - for stdin) with per-frame prompts; replaces the narrative")
+	storyCmd.Flags().StringVar(&storyPackage, "package", "", "Also package the frames: gif, apng, comic or pdf (comma-separated)")
+	storyCmd.Flags().DurationVar(&storyDelay, "delay", 1500*time.Millisecond, "How long each frame shows in gif and apng packages")
 }
 
-func runStory(cmd *cobra.Command, args []string) error {
-	narrative := args[0]
+func runStory(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	streams := newStdio(cmd)
+	narrative := ""
+	if len(args) > 0 {
+		narrative = args[0]
+	}
 
-	if storyFrames < 2 {
-		return fmt.Errorf("frames must be at least 2")
+	if storyIndependent && (len(storyReferences) > 0 || storyReferenceSheet) {
+		return fmt.Errorf("--independent generates frames from text alone and cannot be combined with --reference or --reference-sheet")
 	}
-	if storyFrames > 10 {
-		return fmt.Errorf("frames cannot exceed 10")
+
+	var packages []string
+	if storyPackage != "" {
+		packages, err = storyboard.ParsePackages(storyPackage)
+		if err != nil {
+			return err
+		}
+		if storyDelay <= 0 {
+			return fmt.Errorf("--delay must be positive")
+		}
+	}
+
+	// Frames come from the script or are derived from the narrative
+	var frames []storyFrame
+	title, sheetSubject, style := narrative, narrative, storyStyle
+	if storyScript != "" {
+		if narrative != "" {
+			return fmt.Errorf("pass either a narrative or --script, not both")
+		}
+		if cmd.Flags().Changed("frames") {
+			return fmt.Errorf("--frames cannot be combined with --script; the frame count comes from the script")
+		}
+		script, err := loadStoryScript(streams, storyScript)
+		if err != nil {
+			return err
+		}
+		if storyStyle != "" {
+			script.Style = storyStyle
+		}
+		style = script.Style
+		for i, f := range script.Frames {
+			delay, _ := f.Delay() // validated when the script was parsed
+			frames = append(frames, storyFrame{prompt: script.Prompt(i), caption: f.Caption, inputPath: f.Input, delay: delay})
+		}
+		title = script.Title
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(storyScript), filepath.Ext(storyScript))
+		}
+		sheetSubject = script.Summary()
+	} else {
+		if narrative == "" {
+			return fmt.Errorf("requires a narrative or --script")
+		}
+		if storyFrames < 2 {
+			return fmt.Errorf("frames must be at least 2")
+		}
+		if storyFrames > 10 {
+			return fmt.Errorf("frames cannot exceed 10")
+		}
+		frames = narrativeFrames(narrative, storyFrames)
+	}
+
+	// Load per-frame input images
+	frameInputs := make([]plannedInput, len(frames))
+	for i := range frames {
+		if frames[i].inputPath == "" {
+			continue
+		}
+		imageBase64, err := streams.image(fmt.Sprintf("frame %d input", i+1), frames[i].inputPath)
+		if err != nil {
+			return fmt.Errorf("failed to load input image for frame %d: %w", i+1, err)
+		}
+		frames[i].input = shrinkForInput(imageBase64)
+		frameInputs[i] = imageInput(frames[i].inputPath, imageBase64)
+	}
+
+	// Load user-supplied reference images
+	var references []string
+	var referenceInputs []plannedInput
+	for _, path := range storyReferences {
+		imageBase64, err := streams.image("reference image", path)
+		if err != nil {
+			return fmt.Errorf("failed to load reference image %s: %w", displayPath(path), err)
+		}
+		references = append(references, shrinkForInput(imageBase64))
+		referenceInputs = append(referenceInputs, imageInput(path, imageBase64))
+	}
+
+	// An exact --size asks for the closest supported aspect ratio
+	aspectRatio := sizeAspectRatio()
+
+	sheetPrompt := fmt.Sprintf("Create a character and style reference sheet for a visual story: %s. Show each main character from the front, side, and back with consistent proportions, faces, clothing, and colors, plus key props, a sample of the setting, and a color palette swatch, on a plain neutral background. No text other than short labels.", sheetSubject)
+	if style != "" {
+		sheetPrompt += fmt.Sprintf(" Style: %s.", style)
+	}
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(title)
+	var calls []plannedCall
+	if storyReferenceSheet {
+		call := newPlannedCall(gemini.ModelName, sheetPrompt, "", aspectRatio, referenceInputs...)
+		call.Label = "reference sheet"
+		calls = append(calls, call)
+	}
+	for i, frame := range frames {
+		inputs := append([]plannedInput{}, referenceInputs...)
+		if storyReferenceSheet {
+			inputs = append(inputs, plannedInput{Name: "reference sheet (generated)"})
+		}
+		roles := slices.Repeat([]string{roleReference}, len(inputs))
+		if frame.input != "" {
+			inputs = append(inputs, frameInputs[i])
+			roles = append(roles, roleFrameInput)
+		}
+		if i > 0 && !storyIndependent {
+			inputs = append(inputs, plannedInput{Name: fmt.Sprintf("frame %d (generated)", i)})
+			roles = append(roles, rolePrevious)
+		}
+		call := newPlannedCall(gemini.ModelName, continuityPrompt(frame.prompt, roles), "", aspectRatio, inputs...)
+		call.Label = fmt.Sprintf("frame %d", i+1)
+		calls = append(calls, call)
+	}
+	if stop, err := preflight(rep, calls); stop || err != nil {
+		return err
 	}
 
 	// Create Gemini client
-	client, err := gemini.NewClient()
+	client, err := newClient(rep, false)
 	if err != nil {
-		return fmt.Errorf("failed to create Gemini client: %w", err)
+		return err
 	}
 
-	fmt.Printf("Generating story: %s\n", narrative)
-	fmt.Printf("Frames: %d\n", storyFrames)
-	if storyStyle != "" {
-		fmt.Printf("Style: %s\n", storyStyle)
+	rep.Printf("Generating story: %s\n", title)
+	rep.Printf("Frames: %d\n", len(frames))
+	if storyScript != "" {
+		rep.Printf("Script: %s\n", displayPath(storyScript))
 	}
-	fmt.Println()
+	if style != "" {
+		rep.Printf("Style: %s\n", style)
+	}
+	if len(storyReferences) > 0 {
+		rep.Printf("References: %s\n", strings.Join(storyReferences, ", "))
+	}
+	rep.Println()
 
-	successCount := 0
-	for i := 1; i <= storyFrames; i++ {
-		// Create frame-specific prompt
-		prompt := fmt.Sprintf("Frame %d of %d in a visual narrative: %s", i, storyFrames, narrative)
-		switch i {
-		case 1:
-			prompt += " (beginning/opening scene)"
-		case storyFrames:
-			prompt += " (ending/final scene)"
-		default:
-			prompt += fmt.Sprintf(" (progression, scene %d)", i)
+	rep.SetImageConfig(aspectRatio, "4K")
+
+	if storyReferenceSheet {
+		rep.Println("Generating character and style reference sheet...")
+		sheetData, err := client.GenerateContentWithFullOptions(sheetPrompt, references, "", aspectRatio)
+		if err != nil {
+			return fmt.Errorf("failed to generate reference sheet: %w", err)
 		}
 
-		if storyStyle != "" {
-			prompt += fmt.Sprintf(", style: %s", storyStyle)
+		filename := filehandler.GenerateFilename(title, "story_reference_sheet", 0)
+		outputPath := filehandler.EnsureUniqueFilename(outputPathFor(filepath.Join(storyOutput, filename)))
+		outputPath, _, err = saveImage(rep, sheetData, outputPath, "")
+		if err != nil {
+			rep.Errorf("Error saving reference sheet: %v", err)
+		} else {
+			rep.Saved(outputPath, "✓ Saved reference sheet to: %s\n", outputPath)
 		}
+		references = append(references, shrinkForInput(sheetData))
+	}
 
-		fmt.Printf("[%d/%d] Generating frame...\n", i, storyFrames)
+	successCount := 0
+	previous := ""
+	var packaged []packagedFrame
+	for i := 1; i <= len(frames); i++ {
+		frame := frames[i-1]
+
+		// Attached images: references, then the frame's own input, then the previous frame
+		inputs := append([]string{}, references...)
+		roles := slices.Repeat([]string{roleReference}, len(inputs))
+		if frame.input != "" {
+			inputs = append(inputs, frame.input)
+			roles = append(roles, roleFrameInput)
+		}
+		if previous != "" && !storyIndependent {
+			inputs = append(inputs, previous)
+			roles = append(roles, rolePrevious)
+		}
+		prompt := continuityPrompt(frame.prompt, roles)
+
+		rep.Printf("[%d/%d] Generating frame...\n", i, len(frames))
 
 		// Generate image
-		imageData, err := client.GenerateContent(prompt)
+		imageData, err := client.GenerateContentWithFullOptions(prompt, inputs, "", aspectRatio)
 		if err != nil {
-			fmt.Printf("Error generating frame %d: %v\n", i, err)
+			// Carry on from the last good frame
+			rep.Errorf("Error generating frame %d: %v", i, err)
 			continue
 		}
+		previous = shrinkForInput(imageData)
 
 		// Generate filename
-		filename := filehandler.GenerateFilename(narrative, fmt.Sprintf("story_frame_%02d", i), 0)
-		outputPath := filepath.Join(storyOutput, filename)
-		outputPath = filehandler.EnsureUniqueFilename(outputPath)
+		filename := filehandler.GenerateFilename(title, fmt.Sprintf("story_frame_%02d", i), 0)
+		outputPath := filehandler.EnsureUniqueFilename(outputPathFor(filepath.Join(storyOutput, filename)))
 
 		// Save image
-		if err := filehandler.SaveImage(imageData, outputPath); err != nil {
-			fmt.Printf("Error saving frame %d: %v\n", i, err)
+		outputPath, _, err = saveImage(rep, imageData, outputPath, "")
+		if err != nil {
+			rep.Errorf("Error saving frame %d: %v", i, err)
 			continue
 		}
 
-		fmt.Printf("✓ Saved frame %d to: %s\n", i, outputPath)
+		rep.Saved(outputPath, "✓ Saved frame %d to: %s\n", i, outputPath)
+		if frame.caption != "" {
+			rep.Printf("  Caption: %s\n", frame.caption)
+		}
 		successCount++
+
+		if len(packages) > 0 {
+			img, err := decodeImage(imageData)
+			if err != nil {
+				rep.Errorf("Error keeping frame %d for packaging: %v", i, err)
+				continue
+			}
+			delay := frame.delay
+			if delay == 0 {
+				delay = storyDelay
+			}
+			packaged = append(packaged, packagedFrame{number: i, image: storyboard.FitFrames([]image.Image{img}, packageFrameSize)[0], caption: frame.caption, delay: delay})
+		}
 	}
 
-	fmt.Printf("\nSuccessfully generated %d/%d story frames\n", successCount, storyFrames)
+	rep.Printf("\nSuccessfully generated %d/%d story frames\n", successCount, len(frames))
+
+	if len(packages) > 0 {
+		writeStoryPackages(rep, packages, title, len(frames), packaged)
+	}
 
 	return nil
 }
+
+// writeStoryPackages bundles the generated frames into each requested package
+func writeStoryPackages(rep *reporter, packages []string, title string, total int, frames []packagedFrame) {
+	if len(frames) == 0 {
+		rep.Errorf("No frames to package")
+		return
+	}
+
+	images := make([]image.Image, len(frames))
+	delays := make([]time.Duration, len(frames))
+	for i, f := range frames {
+		images[i] = f.image
+		delays[i] = f.delay
+	}
+
+	rep.Println()
+	for _, pkg := range packages {
+		var buf bytes.Buffer
+		var err error
+		var suffix, ext string
+
+		switch pkg {
+		case storyboard.PackageGIF:
+			suffix, ext = "story", ".gif"
+			err = storyboard.EncodeGIF(&buf, storyboard.FitFrames(images, animationFrameSize), delays)
+		case storyboard.PackageAPNG:
+			suffix, ext = "story_animated", ".png"
+			err = storyboard.EncodeAPNG(&buf, storyboard.FitFrames(images, animationFrameSize), delays)
+		case storyboard.PackageComic:
+			suffix, ext = "story_comic", ".png"
+			panels := make([]storyboard.Panel, len(frames))
+			for i, f := range frames {
+				panels[i] = storyboard.Panel{Image: f.image, Caption: f.caption}
+			}
+			var page image.Image
+			page, err = storyboard.Comic(panels, storyboard.ComicOptions{Title: title})
+			if err == nil {
+				err = png.Encode(&buf, page)
+			}
+		case storyboard.PackagePDF:
+			suffix, ext = "storyboard", ".pdf"
+			pages := make([]storyboard.PDFPage, len(frames))
+			for i, f := range frames {
+				pages[i] = storyboard.PDFPage{
+					Image:   f.image,
+					Heading: fmt.Sprintf("%s — Frame %d of %d", title, f.number, total),
+					Caption: f.caption,
+				}
+			}
+			err = storyboard.EncodePDF(&buf, title, pages)
+		}
+		if err != nil {
+			rep.Errorf("Error creating %s package: %v", pkg, err)
+			continue
+		}
+
+		filename := filehandler.GenerateFilename(title, suffix, 0) + ext
+		outputPath := filehandler.EnsureUniqueFilename(filepath.Join(storyOutput, filename))
+		if err := filehandler.WriteImageFile(outputPath, buf.Bytes()); err != nil {
+			rep.Errorf("Error writing %s package: %v", pkg, err)
+			continue
+		}
+		rep.Saved(outputPath, "✓ Saved %s to: %s\n", pkg, outputPath)
+	}
+}
+
+// narrativeFrames derives per-frame prompts from a single narrative
+func narrativeFrames(narrative string, count int) []storyFrame {
+	frames := make([]storyFrame, count)
+	for i := 1; i <= count; i++ {
+		prompt := fmt.Sprintf("Frame %d of %d in a visual narrative: %s", i, count, narrative)
+		switch i {
+		case 1:
+			prompt += " (beginning/opening scene)"
+		case count:
+			prompt += " (ending/final scene)"
+		default:
+			prompt += fmt.Sprintf(" (progression, scene %d)", i)
+		}
+
+		if storyStyle != "" {
+			prompt += fmt.Sprintf(", style: %s", storyStyle)
+		}
+		frames[i-1] = storyFrame{prompt: prompt}
+	}
+	return frames
+}
+
+// loadStoryScript loads a storyboard script from a file or stdin
+func loadStoryScript(streams *stdio, path string) (*storyboard.Script, error) {
+	if !isStdio(path) {
+		return storyboard.Load(path)
+	}
+	text, err := streams.text("script", path)
+	if err != nil {
+		return nil, err
+	}
+	return storyboard.Parse([]byte(text), "")
+}
+
+// continuityPrompt tells the model how to use the images attached to a frame request,
+// given each image's role in attachment order
+func continuityPrompt(prompt string, roles []string) string {
+	if len(roles) == 0 {
+		return prompt
+	}
+
+	// Describe runs of images with the same role together, e.g. "images 1-2: ..."
+	var parts []string
+	for start := 0; start < len(roles); {
+		end := start
+		for end+1 < len(roles) && roles[end+1] == roles[start] {
+			end++
+		}
+		if start == end {
+			parts = append(parts, fmt.Sprintf("image %d: %s", start+1, roles[start]))
+		} else {
+			parts = append(parts, fmt.Sprintf("images %d-%d: %s", start+1, end+1, roles[start]))
+		}
+		start = end + 1
+	}
+	prompt = strings.TrimSuffix(prompt, ".")
+	prompt += fmt.Sprintf(". Attached images (%s).", strings.Join(parts, "; "))
+
+	prompt += " Keep the characters (faces, proportions, clothing), color palette, lighting, and setting consistent with them."
+	if slices.Contains(roles, roleFrameInput) {
+		prompt += " Follow the input image for this frame's composition."
+	}
+	if slices.Contains(roles, rolePrevious) {
+		prompt += " Continue the story from the previous frame with a new composition rather than copying it."
+	}
+	return prompt
+}
+
+// shrinkForInput downscales base64 image data so its longest side is at most
+// continuityInputSize, returning it unchanged if it is already small or can't be decoded
+func shrinkForInput(imageData string) string {
+	img, err := decodeImage(imageData)
+	if err != nil {
+		return imageData
+	}
+	b := img.Bounds()
+	longest := max(b.Dx(), b.Dy())
+	if longest <= continuityInputSize {
+		return imageData
+	}
+
+	w := max(1, b.Dx()*continuityInputSize/longest)
+	h := max(1, b.Dy()*continuityInputSize/longest)
+	resized := filehandler.Resize(img, filehandler.ResizeOptions{Width: w, Height: h, Fit: filehandler.FitFill})
+	data, err := filehandler.EncodeImage(resized, filehandler.OutputOptions{Format: filehandler.FormatPNG})
+	if err != nil {
+		return imageData
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,378 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"imagemage/pkg/filehandler"
+	"imagemage/pkg/gemini"
+	"imagemage/pkg/journal"
+	"imagemage/pkg/upscale"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	upscaleFactor     int
+	upscaleOutput     string
+	upscaleTile       int
+	upscaleOverlap    int
+	upscaleResolution string
+	upscaleFrugal     bool
+	upscaleResume     bool
+	upscaleForce      bool
+)
+
+// upscaleContextSize is the largest side of the whole-image context sent with each tile
+const upscaleContextSize = 1024
+
+// upscaleJournalName is the journal of finished tiles in the work directory
+const upscaleJournalName = "journal.jsonl"
+
+var upscaleCmd = &cobra.Command{
+	Use:   "upscale [image-path]",
+	Short: "Upscale an image 2x or 4x by enhancing it tile by tile",
+	Long: `Upscale an image beyond what a single request can return.
+
+The image is split into overlapping tiles. Each tile is sent with a small copy of the
+whole image for context and comes back redrawn at the model's full resolution. The
+tiles are color-matched to the original and blended across their overlaps locally.
+
+Finished tiles are kept in a work directory next to the output until the image is
+assembled, so an interrupted run continues with --resume instead of paying for
+every tile again.
+
+Examples:
+  imagemage upscale photo.png
+  imagemage upscale poster.png --factor 4 --output poster_print.png
+  imagemage upscale poster.png --factor 4 --resume
+  imagemage upscale sketch.png --frugal`,
+	Args: cobra.ExactArgs(1),
+	RunE: runUpscale,
+}
+
+func init() {
+	rootCmd.AddCommand(upscaleCmd)
+
+	upscaleCmd.Flags().IntVarP(&upscaleFactor, "factor", "x", 2, "Scale factor: 2 or 4")
+	upscaleCmd.Flags().StringVarP(&upscaleOutput, "output", "o", "", "Output path (default: image_x2.png, - for stdout)")
+	upscaleCmd.Flags().IntVar(&upscaleTile, "tile", 0, "Tile size in source pixels (default: the model's output size divided by --factor)")
+	upscaleCmd.Flags().IntVar(&upscaleOverlap, "overlap", 0, "Minimum overlap between tiles in source pixels (default: 1/8 of the tile)")
+	upscaleCmd.Flags().StringVarP(&upscaleResolution, "resolution", "r", "", "Resolution of each tile (1K, 2K, 4K). Defaults to 4K for Pro model, 1K for --frugal")
+	upscaleCmd.Flags().BoolVarP(&upscaleFrugal, "frugal", "f", false, "Use the cheaper gemini-2.5-flash-image model (1024px tiles, so many more of them)")
+	upscaleCmd.Flags().BoolVar(&upscaleResume, "resume", false, "Reuse tiles finished by an interrupted run")
+	upscaleCmd.Flags().BoolVar(&upscaleForce, "force", false, "Overwrite output file if it exists")
+}
+
+func runUpscale(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	imagePath := args[0]
+	streams := newStdio(cmd)
+
+	if !slices.Contains(upscale.Factors, upscaleFactor) {
+		return fmt.Errorf("unsupported factor: %d (use 2 or 4)", upscaleFactor)
+	}
+	if upscaleFrugal && upscaleResolution != "" {
+		return fmt.Errorf("--frugal mode has fixed 1024px output and does not accept --resolution parameter. Gemini 2.5 Flash always outputs at 1024px. Remove --resolution or --frugal flag")
+	}
+	if upscaleTile < 0 || upscaleOverlap < 0 {
+		return fmt.Errorf("--tile and --overlap must be positive")
+	}
+
+	// Check if the image exists
+	if !isStdio(imagePath) {
+		if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+			return fmt.Errorf("image not found: %s", imagePath)
+		}
+	}
+
+	// Determine output path
+	outputPath := upscaleOutput
+	if outputPath == "" {
+		if isStdio(imagePath) {
+			outputPath = stdioPath
+		} else {
+			ext := filepath.Ext(imagePath)
+			outputPath = fmt.Sprintf("%s_x%d%s", strings.TrimSuffix(imagePath, ext), upscaleFactor, ext)
+		}
+	}
+	outputPath = outputPathFor(outputPath)
+
+	// Writing to stdout moves all status output to stderr
+	toStdout := isStdio(outputPath)
+	if toStdout {
+		if upscaleResume {
+			return fmt.Errorf("--resume keeps tiles next to the output file; it can't be used with stdout output")
+		}
+		if err := rep.StreamToStdout(cmd); err != nil {
+			return err
+		}
+	}
+
+	// Check if output exists
+	if !upscaleForce && !toStdout {
+		if _, err := os.Stat(outputPath); err == nil {
+			return fmt.Errorf("output file already exists: %s (use --force to overwrite)", outputPath)
+		}
+	}
+
+	rep.Printf("Loading image: %s\n", displayPath(imagePath))
+
+	imageBase64, err := streams.image("image", imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+	src, err := decodeImage(imageBase64)
+	if err != nil {
+		return err
+	}
+
+	// Each tile comes back at the model's full size, so a tile covers that much of the
+	// output
+	resolution := upscaleResolution
+	if resolution == "" && !upscaleFrugal {
+		resolution = "4K"
+	}
+	tileSize := upscaleTile
+	if tileSize == 0 {
+		tileSize = upscaleTilePixels(resolution) / upscaleFactor
+	}
+	overlap := upscaleOverlap
+	if overlap == 0 {
+		overlap = tileSize / 8
+	}
+	if overlap >= tileSize {
+		return fmt.Errorf("--overlap (%d) must be smaller than the tile (%d)", overlap, tileSize)
+	}
+
+	b := src.Bounds()
+	tiles := upscale.Plan(b.Dx(), b.Dy(), tileSize, overlap)
+	tileAspect := gemini.FindClosestAspectRatio(tiles[0].Source.Dx(), tiles[0].Source.Dy())
+
+	// Finished tiles live in a work directory until the image is assembled
+	var workDir string
+	if toStdout {
+		workDir, err = os.MkdirTemp("", "imagemage-upscale-")
+		if err != nil {
+			return fmt.Errorf("failed to create work directory: %w", err)
+		}
+		defer func() { _ = os.RemoveAll(workDir) }()
+	} else {
+		workDir = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "_tiles"
+	}
+	journalPath := filepath.Join(workDir, upscaleJournalName)
+	done, err := journal.Open(journalPath)
+	if err != nil {
+		return err
+	}
+
+	// Tiles are only reused for the same pixels, model and resolution
+	run := upscale.Fingerprint(src, upscaleFactor, modelName(upscaleFrugal), resolution)
+	pending := 0
+	for _, t := range tiles {
+		if !upscaleResume {
+			pending++
+		} else if _, ok := upscaleFinishedTile(done, t.Key(run)); !ok {
+			pending++
+		}
+	}
+	if upscaleResume && pending == len(tiles) && fileExists(journalPath) {
+		rep.Warnf("Warning: no tiles in %s match this image and settings; making every tile again", workDir)
+	}
+
+	last := tiles[len(tiles)-1]
+	rep.Printf("Upscaling %dx%d → %dx%d (%dx)\n", b.Dx(), b.Dy(), b.Dx()*upscaleFactor, b.Dy()*upscaleFactor, upscaleFactor)
+	if len(tiles) > 1 {
+		rep.Printf("Tiles: %d (%d x %d) of %dpx, overlapping by at least %dpx\n", len(tiles), last.Col+1, last.Row+1, tileSize, overlap)
+	} else {
+		rep.Printf("Tiles: 1 (the whole image fits in one)\n")
+	}
+	if pending < len(tiles) {
+		rep.Printf("Resuming: %d of %d tiles already done\n", len(tiles)-pending, len(tiles))
+	}
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(upscale.Prompt)
+	if pending > 0 {
+		call := newPlannedCall(modelName(upscaleFrugal), upscale.Prompt, upscaleResolution, tileAspect,
+			plannedInput{Name: fmt.Sprintf("tile (%dx%d crop)", tiles[0].Source.Dx(), tiles[0].Source.Dy())},
+			plannedInput{Name: "whole image with the tile outlined (generated)"})
+		call.Label = "tile"
+		call.Count = pending
+		if stop, err := preflight(rep, []plannedCall{call}); stop || err != nil {
+			return err
+		}
+	}
+	if !upscaleResume {
+		if err := done.Reset(); err != nil {
+			return err
+		}
+	}
+
+	var client *gemini.Client
+	if pending > 0 {
+		client, err = newClient(rep, upscaleFrugal)
+		if err != nil {
+			return err
+		}
+		if upscaleFrugal {
+			rep.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
+		} else {
+			rep.Printf("Model: %s\n", gemini.ModelName)
+		}
+	}
+	shownResolution := resolution
+	if upscaleFrugal {
+		shownResolution = "1024px"
+	}
+	rep.SetImageConfig(tileAspect, shownResolution)
+	rep.SetMetric("tiles", float64(len(tiles)))
+	rep.Println()
+
+	// Tiles are blended in plan order as they arrive
+	canvas := upscale.NewCanvas(src, upscaleFactor)
+	start := time.Now()
+	generated := 0
+	for i, t := range tiles {
+		tileImage, fresh, err := upscaleTileImage(client, done, run, workDir, src, t, tileAspect)
+		if err != nil {
+			rep.Printf("[%d/%d] tile %d,%d failed\n", i+1, len(tiles), t.Row+1, t.Col+1)
+			if toStdout {
+				return fmt.Errorf("failed to upscale tile %d,%d: %w", t.Row+1, t.Col+1, err)
+			}
+			return fmt.Errorf("failed to upscale tile %d,%d: %w (finished tiles are kept in %s; rerun with --resume to continue)", t.Row+1, t.Col+1, err, workDir)
+		}
+		canvas.Place(t, tileImage)
+
+		if fresh {
+			generated++
+			remaining := time.Duration(0)
+			if left := pending - generated; left > 0 {
+				remaining = time.Since(start) / time.Duration(generated) * time.Duration(left)
+			}
+			rep.Printf("[%d/%d] ✓ tile %d,%d (about %s left)\n", i+1, len(tiles), t.Row+1, t.Col+1, remaining.Round(time.Second))
+		} else {
+			rep.Printf("[%d/%d] ✓ tile %d,%d (from earlier run)\n", i+1, len(tiles), t.Row+1, t.Col+1)
+		}
+	}
+	rep.SetMetric("tilesGenerated", float64(generated))
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas.Image); err != nil {
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	if toStdout {
+		err = streams.writeImageBytes(rep, buf.Bytes(), "")
+	} else {
+		var savedPath string
+		savedPath, _, err = saveImageBytes(rep, buf.Bytes(), outputPath, "")
+		if err == nil {
+			rep.Saved(savedPath, "\n✓ Upscaled image saved to: %s\n", savedPath)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save upscaled image: %w", err)
+	}
+
+	// The tiles are only needed to resume
+	if err := os.RemoveAll(workDir); err != nil {
+		rep.Warnf("Warning: failed to remove work directory %s: %v", workDir, err)
+	}
+	return nil
+}
+
+// upscaleTilePixels returns the size of the square image the model returns at a resolution
+func upscaleTilePixels(resolution string) int {
+	switch resolution {
+	case "4K":
+		return 4096
+	case "2K":
+		return 2048
+	default:
+		return 1024
+	}
+}
+
+// upscaleTileFile returns where the finished tile with the given key is kept
+func upscaleTileFile(done *journal.Journal, key string) string {
+	entry, ok := done.Done(key)
+	if !ok {
+		return ""
+	}
+	return entry.Output
+}
+
+// upscaleFinishedTile returns a tile finished by an earlier run, if the journal has it
+// and its file still decodes; a missing or damaged tile has to be made again
+func upscaleFinishedTile(done *journal.Journal, key string) (image.Image, bool) {
+	path := upscaleTileFile(done, key)
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	return img, true
+}
+
+// upscaleTileImage returns the upscaled tile, from an earlier run if the journal has
+// it or else from the model, which it records. fresh reports a new request.
+func upscaleTileImage(client *gemini.Client, done *journal.Journal, run, workDir string, src image.Image, t upscale.Tile, aspectRatio string) (img image.Image, fresh bool, err error) {
+	if img, ok := upscaleFinishedTile(done, t.Key(run)); ok {
+		return img, false, nil
+	}
+	if client == nil {
+		// Only possible if a finished tile went bad after it was counted
+		return nil, false, fmt.Errorf("tile from the earlier run can no longer be read")
+	}
+
+	crop := image.NewNRGBA(image.Rect(0, 0, t.Source.Dx(), t.Source.Dy()))
+	draw.Draw(crop, crop.Rect, src, src.Bounds().Min.Add(t.Source.Min), draw.Src)
+	cropBase64, err := encodePNGBase64(crop)
+	if err != nil {
+		return nil, false, err
+	}
+	contextBase64, err := encodePNGBase64(upscale.Context(src, t, upscaleContextSize))
+	if err != nil {
+		return nil, false, err
+	}
+
+	tileData, err := client.GenerateContentWithFullOptions(upscale.Prompt, []string{cropBase64, contextBase64}, upscaleResolution, aspectRatio)
+	if err != nil {
+		return nil, false, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(tileData)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode image data: %w", err)
+	}
+	img, _, err = image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Keep the tile so a failure later on doesn't lose it
+	path := filepath.Join(workDir, fmt.Sprintf("r%dc%d.png", t.Row, t.Col))
+	if err := filehandler.WriteImageFile(path, raw); err != nil {
+		return nil, false, err
+	}
+	if err := done.Mark(t.Key(run), path); err != nil {
+		return nil, false, err
+	}
+	return img, true, nil
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"imagemage/pkg/gemini"
+	"imagemage/pkg/ledger"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	usageTag     string
+	usageBy      string
+	usageSince   string
+	usageProject string
+	usageCSV     string
+	usageLedger  string
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Summarize API usage and estimated spend",
+	Long: `Summarize the local usage ledger by day, model, project and command.
+
+Every successful API call is appended to the ledger with its model, resolution, token
+usage, estimated cost and project tag. The project tag comes from --tag, or from the
+"project" field of the config file.
+
+The ledger lives at imagemage/ledger.jsonl in your user config directory. Set
+IMAGEMAGE_LEDGER to use a different file.
+
+Examples:
+  imagemage usage
+  imagemage usage --by=project --since=2026-01-01
+  imagemage usage --by=day --csv=spend.csv
+  imagemage usage --csv=-              # raw ledger entries as CSV on stdout
+  imagemage generate "hero image" --tag=website-redesign`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.PersistentFlags().StringVar(&usageTag, "tag", "", "Project tag recorded in the usage ledger (overrides the config's project)")
+
+	usageCmd.Flags().StringVar(&usageBy, "by", "", "Group by: day, model, project, command (default: show all)")
+	usageCmd.Flags().StringVar(&usageSince, "since", "", "Only include calls on or after this date (YYYY-MM-DD)")
+	usageCmd.Flags().StringVar(&usageProject, "project", "", "Only include calls for this project tag")
+	usageCmd.Flags().StringVar(&usageCSV, "csv", "", "Export as CSV to this file (- for stdout); raw entries unless --by is set")
+	usageCmd.Flags().StringVar(&usageLedger, "ledger", "", "Ledger file to read (default: $IMAGEMAGE_LEDGER or the user config directory)")
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	path := usageLedger
+	if path == "" {
+		var err error
+		path, err = ledger.DefaultPath()
+		if err != nil {
+			return err
+		}
+	}
+
+	var since time.Time
+	if usageSince != "" {
+		var err error
+		since, err = time.ParseInLocation("2006-01-02", usageSince, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --since date %q (use YYYY-MM-DD)", usageSince)
+		}
+	}
+
+	groupings := ledger.Groupings
+	if usageBy != "" {
+		groupings = []string{usageBy}
+	}
+
+	entries, err := ledger.Load(path)
+	if err != nil {
+		return err
+	}
+	entries = ledger.Filter(entries, since, usageProject)
+
+	summaries := map[string][]ledger.Row{}
+	for _, g := range groupings {
+		rows, err := ledger.Summarize(entries, g)
+		if err != nil {
+			return err
+		}
+		summaries[g] = rows
+	}
+
+	if usageCSV != "" {
+		return exportUsageCSV(cmd, entries, summaries)
+	}
+
+	totalCost := 0.0
+	for _, e := range entries {
+		totalCost += e.EstimatedCost
+	}
+
+	if outputFormat == outputFormatJSON {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{
+			"ledger":        path,
+			"calls":         len(entries),
+			"estimatedCost": totalCost,
+			"groups":        summaries,
+		})
+	}
+
+	out := cmd.OutOrStdout()
+	_, _ = fmt.Fprintf(out, "Ledger: %s\n", path)
+	_, _ = fmt.Fprintf(out, "Total: %d call(s), $%.4f estimated\n", len(entries), totalCost)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	for _, g := range groupings {
+		_, _ = fmt.Fprintf(out, "\nBy %s:\n", g)
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "  %s\tCALLS\tIMAGES\tTOKENS\tCOST\t\n", g)
+		for _, r := range summaries[g] {
+			_, _ = fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t$%.4f\t\n", r.Key, r.Calls, r.Images, r.TotalTokens, r.EstimatedCost)
+		}
+		_ = tw.Flush()
+	}
+
+	return nil
+}
+
+// exportUsageCSV writes the summary for --by, or the raw entries, as CSV
+func exportUsageCSV(cmd *cobra.Command, entries []ledger.Entry, summaries map[string][]ledger.Row) error {
+	w := cmd.OutOrStdout()
+	if !isStdio(usageCSV) {
+		f, err := os.Create(usageCSV)
+		if err != nil {
+			return fmt.Errorf("failed to create CSV file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+
+	var err error
+	if usageBy != "" {
+		err = ledger.WriteRowsCSV(w, usageBy, summaries[usageBy])
+	} else {
+		err = ledger.WriteEntriesCSV(w, entries)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	if !isStdio(usageCSV) {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "✓ Exported usage to: %s\n", usageCSV)
+	}
+	return nil
+}
+
+// recordUsage returns a client call hook that appends each successful call to the ledger
+func recordUsage(r *reporter) func(gemini.CallInfo) {
+	warned := false
+	return func(call gemini.CallInfo) {
+		path, err := ledger.DefaultPath()
+		if err == nil {
+			err = ledger.Append(path, newLedgerEntry(r, call))
+		}
+		if err != nil && !warned {
+			// Losing a ledger line shouldn't fail an image that was already paid for
+			r.Warnf("Warning: failed to record usage: %v", err)
+			warned = true
+		}
+	}
+}
+
+// newLedgerEntry builds the ledger entry for a successful call
+func newLedgerEntry(r *reporter, call gemini.CallInfo) ledger.Entry {
+	entry := ledger.Entry{
+		Time:             time.Now().UTC(),
+		Command:          r.result.Command,
+		Model:            call.Model,
+		Images:           1,
+		InputImages:      call.InputImages,
+		PromptTokens:     call.Usage.PromptTokenCount,
+		CandidatesTokens: call.Usage.CandidatesTokenCount,
+		TotalTokens:      call.Usage.TotalTokenCount,
+		Project:          r.Project(),
+	}
+	if call.TextOnly {
+		entry.Images = 0
+	}
+
+	resolution := ""
+	if call.ImageConfig != nil {
+		resolution = call.ImageConfig.ImageSize
+		entry.AspectRatio = call.ImageConfig.AspectRatio
+	}
+	entry.Resolution = resolution
+	if entry.Resolution == "" && call.Model == gemini.ModelNameFrugal {
+		entry.Resolution = "1024px"
+	}
+
+	// Prefer the billed token counts; fall back to list prices if the API reported none
+	if call.Usage.TotalTokenCount > 0 {
+		entry.EstimatedCost = gemini.CostFromUsage(call.Model, call.Usage)
+	} else {
+		entry.EstimatedCost = gemini.EstimateCost(call.Model, resolution, call.Prompt, call.InputImages)
+	}
+
+	return entry
+}
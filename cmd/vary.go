@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"imagemage/pkg/filehandler"
+	"imagemage/pkg/gemini"
+	"imagemage/pkg/metadata"
+	"imagemage/pkg/variation"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	varyCount       int
+	varyStrength    string
+	varyPrompt      string
+	varyOutput      string
+	varyAspectRatio string
+	varyResolution  string
+	varyFrugal      bool
+)
+
+var varyCmd = &cobra.Command{
+	Use:   "vary [image-path]",
+	Short: "Make variations of an existing image",
+	Long: `Make more images like one you already have.
+
+The image is sent as the reference for every variation. If it was saved with
+--store-prompt, its prompt is reused so the variations stay on brief; --prompt
+supplies one when it wasn't.
+
+Variations are saved next to the image as image_var1.png, image_var2.png, ...,
+continuing after any that already exist. Each one records its parent image (and
+the prompt, when known) in its PNG metadata, so varying a variation keeps the trail.
+
+Strengths:
+  low   Same shot, small changes to details, pose and lighting (default)
+  high  Same subject and style, new composition
+
+Examples:
+  imagemage vary hero.png
+  imagemage vary hero.png --count 8 --strength high
+  imagemage vary logo.png --prompt "a minimalist fox logo" --output options/`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVary,
+}
+
+func init() {
+	rootCmd.AddCommand(varyCmd)
+
+	varyCmd.Flags().IntVarP(&varyCount, "count", "c", 4, "Number of variations to make")
+	varyCmd.Flags().StringVarP(&varyStrength, "strength", "s", "low", "How far variations may stray: "+strings.Join(variation.Strengths, ", "))
+	varyCmd.Flags().StringVarP(&varyPrompt, "prompt", "p", "", "Prompt the image was made from (default: the prompt stored in its metadata)")
+	varyCmd.Flags().StringVarP(&varyOutput, "output", "o", "", "Output directory (default: the image's directory)")
+	varyCmd.Flags().StringVarP(&varyAspectRatio, "aspect-ratio", "a", "", "Aspect ratio for output (auto-detected from input if not specified)")
+	varyCmd.Flags().StringVarP(&varyResolution, "resolution", "r", "", "Image resolution (1K, 2K, 4K). Defaults to 4K for Pro model, 1K for --frugal")
+	varyCmd.Flags().BoolVarP(&varyFrugal, "frugal", "f", false, "Use the cheaper gemini-2.5-flash-image model")
+}
+
+func runVary(cmd *cobra.Command, args []string) (err error) {
+	rep := newReporter(cmd)
+	defer func() { err = rep.Finish(err) }()
+
+	imagePath := args[0]
+
+	// Variations are files linked to their parent, so both ends need a name
+	if isStdio(imagePath) {
+		return fmt.Errorf("vary needs an image file: its name and metadata are what the variations are linked to")
+	}
+	if isStdio(varyOutput) {
+		return fmt.Errorf("vary saves several files; --output is a directory")
+	}
+	if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+		return fmt.Errorf("image not found: %s", imagePath)
+	}
+	if varyCount < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	if varyFrugal && varyResolution != "" {
+		return fmt.Errorf("--frugal mode has fixed 1024px output and does not accept --resolution parameter. Gemini 2.5 Flash always outputs at 1024px. Remove --resolution or --frugal flag")
+	}
+
+	// Reuse the prompt stored by --store-prompt unless one is given
+	prompt := varyPrompt
+	promptSource := "from --prompt"
+	if prompt == "" {
+		if stored, err := metadata.ReadPromptFromPNG(imagePath); err == nil {
+			prompt = stored
+			promptSource = "stored in the image"
+		}
+	}
+	instruction, err := variation.Instruction(varyStrength, prompt)
+	if err != nil {
+		return err
+	}
+
+	outputDir := varyOutput
+	if outputDir == "" {
+		outputDir = filepath.Dir(imagePath)
+	}
+
+	rep.Printf("Loading image: %s\n", imagePath)
+	imageBase64, err := filehandler.LoadImageAsBase64(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+
+	// An exact --size asks for the closest supported aspect ratio
+	if varyAspectRatio == "" {
+		varyAspectRatio = sizeAspectRatio()
+	}
+
+	// Auto-detect aspect ratio from the image if not specified
+	detectedAspectRatio := ""
+	if varyAspectRatio == "" {
+		width, height, err := base64ImageDimensions(imageBase64)
+		if err != nil {
+			rep.Warnf("Could not detect image dimensions: %v", err)
+		} else {
+			detectedAspectRatio = gemini.FindClosestAspectRatio(width, height)
+			varyAspectRatio = detectedAspectRatio
+		}
+	}
+	if varyAspectRatio != "" {
+		if err := gemini.ValidateAspectRatio(varyAspectRatio); err != nil {
+			return err
+		}
+	}
+
+	// Estimate cost, enforce --max-cost and stop here for --dry-run
+	rep.SetPrompt(instruction)
+	call := newPlannedCall(modelName(varyFrugal), instruction, varyResolution, varyAspectRatio, imageInput(imagePath, imageBase64))
+	call.Count = varyCount
+	if stop, err := preflight(rep, []plannedCall{call}); stop || err != nil {
+		return err
+	}
+
+	client, err := newClient(rep, varyFrugal)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Variations point back at their parent relative to where they are saved
+	lineage := metadata.Lineage{Parent: imagePath, Strength: varyStrength}
+	if absParent, err := filepath.Abs(imagePath); err == nil {
+		lineage.Parent = absParent
+		if absDir, err := filepath.Abs(outputDir); err == nil {
+			if rel, err := filepath.Rel(absDir, absParent); err == nil {
+				lineage.Parent = rel
+			}
+		}
+	}
+
+	rep.Printf("\nMaking %d variation(s) of: %s\n", varyCount, imagePath)
+	rep.Printf("Strength: %s\n", varyStrength)
+	if prompt != "" {
+		rep.Printf("Prompt (%s): %s\n", promptSource, prompt)
+	} else {
+		rep.Printf("Prompt: none stored; varying from the image alone (use --prompt to keep it on brief)\n")
+	}
+	if detectedAspectRatio != "" {
+		rep.Printf("Aspect Ratio: %s (auto-detected from input)\n", varyAspectRatio)
+	} else if varyAspectRatio != "" {
+		rep.Printf("Aspect Ratio: %s\n", varyAspectRatio)
+	}
+	resolution := varyResolution
+	if varyFrugal {
+		resolution = "1024px"
+		rep.Printf("Resolution: 1024px (fixed)\n")
+		rep.Printf("Model: %s (frugal)\n", gemini.ModelNameFrugal)
+	} else {
+		if resolution == "" {
+			resolution = "4K"
+		}
+		rep.Printf("Resolution: %s\n", resolution)
+		rep.Printf("Model: %s\n", gemini.ModelName)
+	}
+	rep.Println()
+
+	rep.SetImageConfig(varyAspectRatio, resolution)
+
+	successCount := 0
+	next := 1
+	for i := 1; i <= varyCount; i++ {
+		rep.Printf("[%d/%d] Generating variation...\n", i, varyCount)
+
+		imageData, err := client.GenerateContentWithFullOptions(instruction, []string{imageBase64}, varyResolution, varyAspectRatio)
+		if err != nil {
+			rep.Errorf("Error generating variation %d: %v", i, err)
+			continue
+		}
+
+		// Number after the variations that already exist
+		var outputPath string
+		for ; ; next++ {
+			outputPath = outputPathFor(filepath.Join(outputDir, variation.Name(imagePath, next)))
+			if !fileExists(outputPath) {
+				break
+			}
+		}
+
+		outputPath, err = saveVariation(rep, imageData, outputPath, prompt, lineage)
+		if err != nil {
+			rep.Errorf("Error saving variation %d: %v", i, err)
+			continue
+		}
+		rep.Saved(outputPath, "✓ Saved to: %s\n", outputPath)
+		successCount++
+	}
+
+	rep.SetMetric("variations", float64(successCount))
+	rep.Printf("\nSuccessfully made %d/%d variations\n", successCount, varyCount)
+	if successCount == 0 {
+		return fmt.Errorf("no variations were made")
+	}
+
+	return nil
+}
+
+// saveVariation saves a variation with its parent's prompt and its lineage in the PNG
+// metadata, and returns the final path
+func saveVariation(rep *reporter, imageData, outputPath, prompt string, lineage metadata.Lineage) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image data: %w", err)
+	}
+	encoded, opts, _, err := encodeForOutput(rep, data, outputPath, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.Format != filehandler.FormatPNG {
+		rep.Warnf("Warning: parent not recorded: metadata is only supported for png output, not %s", opts.Format)
+	} else if withLineage, err := metadata.AddLineageToPNGData(encoded, lineage); err != nil {
+		rep.Warnf("Warning: failed to record parent in metadata: %v", err)
+	} else {
+		encoded = withLineage
+	}
+
+	outputPath = filehandler.WithExtension(outputPath, opts.Format)
+	if err := filehandler.WriteImageFile(outputPath, encoded); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"imagemage/pkg/gemini"
+	"imagemage/pkg/textcheck"
+	"strings"
+)
+
+// defaultVerifyAttempts is how many fix edits --verify-text makes by default
+const defaultVerifyAttempts = 2
+
+// textVerifier reads generated images back with a vision model and, while expected
+// labels are missing or misspelled, asks the image model to fix them
+type textVerifier struct {
+	client      *gemini.Client
+	labels      []string
+	attempts    int // fix edits allowed per image; 0 only reports
+	resolution  string
+	aspectRatio string
+
+	missing int // totals across every image verified, for the JSON metrics
+	fixes   int
+}
+
+// verifyCalls plans the worst case for verifying images: every fix attempt used
+func verifyCalls(model, resolution, aspectRatio string, attempts, images int) []plannedCall {
+	check := newTextCall(gemini.ModelNameText, gemini.ReadTextPrompt, plannedInput{Name: "image (generated)"})
+	check.Label = "text check"
+	check.Count = images * (attempts + 1)
+	calls := []plannedCall{check}
+	if attempts > 0 {
+		fix := newPlannedCall(model, textcheck.FixPrompt(nil), resolution, aspectRatio, plannedInput{Name: "image (generated)"})
+		fix.Label = fmt.Sprintf("text fix (up to %d per image)", attempts)
+		fix.Count = images * attempts
+		calls = append(calls, fix)
+	}
+	return calls
+}
+
+// verify checks imageData and returns the image with the fewest mismatches: the
+// original, or a fixed edit of it. Failures only warn; the image is still usable.
+func (v *textVerifier) verify(rep *reporter, imageData string) string {
+	best, current := imageData, imageData
+	var bestMismatches []textcheck.Mismatch
+	fixes := 0
+	for {
+		found, err := v.client.ReadText(current)
+		if err != nil {
+			rep.Warnf("Warning: could not verify text: %v", err)
+			if fixes == 0 {
+				return imageData
+			}
+			break
+		}
+		mismatches := textcheck.Check(v.labels, found)
+		if fixes == 0 || len(mismatches) < len(bestMismatches) {
+			best, bestMismatches = current, mismatches
+		}
+		if len(mismatches) == 0 {
+			break
+		}
+		if fixes == v.attempts {
+			break
+		}
+
+		rep.Printf("  %d of %d labels wrong: %s\n", len(mismatches), len(v.labels), describeMismatches(mismatches))
+		fixes++
+		rep.Printf("Fixing text (attempt %d/%d)...\n", fixes, v.attempts)
+		fixed, err := v.client.GenerateContentWithFullOptions(textcheck.FixPrompt(mismatches), []string{current}, v.resolution, v.aspectRatio)
+		if err != nil {
+			rep.Warnf("Warning: text fix failed: %v", err)
+			break
+		}
+		current = fixed
+	}
+
+	v.missing += len(bestMismatches)
+	v.fixes += fixes
+	rep.SetMetric("labelsMissing", float64(v.missing))
+	rep.SetMetric("textFixes", float64(v.fixes))
+	if len(bestMismatches) == 0 {
+		rep.Printf("✓ All %d labels verified\n", len(v.labels))
+	} else {
+		still := ""
+		if fixes > 0 {
+			still = "still "
+		}
+		rep.Warnf("Warning: %d of %d labels %smissing or misspelled: %s", len(bestMismatches), len(v.labels), still, describeMismatches(bestMismatches))
+	}
+	return best
+}
+
+// describeMismatches lists mismatches as `"Ordres DB" (should be "Orders DB")`
+func describeMismatches(mismatches []textcheck.Mismatch) string {
+	parts := make([]string, len(mismatches))
+	for i, m := range mismatches {
+		if m.Found != "" {
+			parts[i] = fmt.Sprintf("%q (should be %q)", m.Found, m.Expected)
+		} else {
+			parts[i] = fmt.Sprintf("%q (missing)", m.Expected)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
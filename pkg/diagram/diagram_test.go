@@ -0,0 +1,387 @@
+package diagram
+
+import (
+	"imagemage/pkg/gemini"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// edgeList formats edges as "from>to:label" for compact comparisons
+func edgeList(g *Graph) []string {
+	var out []string
+	for _, e := range g.Edges {
+		arrow := ">"
+		if !e.Directed {
+			arrow = "-"
+		}
+		out = append(out, e.From+arrow+e.To+":"+e.Label)
+	}
+	return out
+}
+
+func TestParseMermaidFlowchart(t *testing.T) {
+	src := `---
+title: Checkout
+---
+flowchart LR
+  %% a comment
+  A([Start]) --> B{"Cart empty?"}
+  B -->|yes| C[Show empty cart]
+  B -- no --> D[(Orders DB)] & E
+  subgraph pay [Payment]
+    E((Charge card)) -.-> F
+  end
+  classDef hot fill:#f00
+  C --- F:::hot; F --> A
+`
+	g, err := Parse(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Kind != KindFlowchart || g.Direction != "LR" || g.Title != "Checkout" {
+		t.Errorf("got kind %q, direction %q, title %q", g.Kind, g.Direction, g.Title)
+	}
+
+	want := []string{"A>B:", "B>C:yes", "B>D:no", "B>E:no", "E>F:", "C-F:", "F>A:"}
+	if got := edgeList(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("edges = %v, want %v", got, want)
+	}
+	if !g.Edges[4].Dashed {
+		t.Error("-.-> should be dashed")
+	}
+
+	shapes := map[string]string{"A": ShapeStadium, "B": ShapeDiamond, "C": ShapeBox, "D": ShapeDatabase, "E": ShapeCircle}
+	for id, shape := range shapes {
+		if n, _ := g.Node(id); n.Shape != shape {
+			t.Errorf("node %s shape = %q, want %q", id, n.Shape, shape)
+		}
+	}
+	if n, _ := g.Node("B"); n.Label != "Cart empty?" {
+		t.Errorf("quoted label = %q", n.Label)
+	}
+	if len(g.Groups) != 1 || g.Groups[0].Label != "Payment" || !reflect.DeepEqual(g.Groups[0].Nodes, []string{"F"}) {
+		t.Errorf("groups = %+v", g.Groups)
+	}
+}
+
+func TestParseMermaidSequence(t *testing.T) {
+	src := `sequenceDiagram
+    title Login
+    actor U as User
+    participant API
+    U->>+API: POST /login
+    alt bad password
+      API-->>U: 401
+    else
+      API-->>-U: 200 + token
+    end
+    Note over U,API: TLS everywhere
+`
+	g, err := Parse(src, SyntaxMermaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Kind != KindSequence || g.Title != "Login" {
+		t.Errorf("got kind %q, title %q", g.Kind, g.Title)
+	}
+	if g.Nodes[0].Label != "User" || g.Nodes[0].Shape != ShapeActor {
+		t.Errorf("first participant = %+v", g.Nodes[0])
+	}
+	want := []string{"U>API:POST /login", "API>U:401", "API>U:200 + token"}
+	if got := edgeList(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+	if !g.Edges[1].Dashed || g.Edges[0].Dashed {
+		t.Error("-->> should be dashed and ->> solid")
+	}
+	if len(g.Notes) != 1 || !reflect.DeepEqual(g.Notes[0].Over, []string{"U", "API"}) {
+		t.Errorf("notes = %+v", g.Notes)
+	}
+}
+
+func TestParseDOT(t *testing.T) {
+	src := `// services
+digraph "Platform" {
+  rankdir=LR;
+  label="Platform overview"
+  node [shape=box];
+  web [label="Web app"];
+  subgraph cluster_data {
+    label = "Data tier";
+    db [label="Postgres", shape=cylinder];
+    cache;
+  }
+  web -> api -> db [label="SQL"];
+  api -> cache [style=dashed, label=<reads<br/>writes>];
+  { rank=same; web; api }
+  web -> web2 [dir=none]
+}`
+	g, err := Parse(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Title != "Platform overview" || g.Direction != "LR" {
+		t.Errorf("title %q, direction %q", g.Title, g.Direction)
+	}
+	want := []string{"web>api:SQL", "api>db:SQL", "api>cache:reads writes", "web-web2:"}
+	if got := edgeList(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("edges = %v, want %v", got, want)
+	}
+	if !g.Edges[2].Dashed {
+		t.Error("style=dashed should be dashed")
+	}
+	if n, _ := g.Node("db"); n.Label != "Postgres" || n.Shape != ShapeDatabase {
+		t.Errorf("db = %+v", n)
+	}
+	if n, _ := g.Node("api"); n.Shape != ShapeBox {
+		t.Errorf("api should take the default node shape, got %q", n.Shape)
+	}
+	if len(g.Groups) != 1 || g.Groups[0].Label != "Data tier" || !reflect.DeepEqual(g.Groups[0].Nodes, []string{"db", "cache"}) {
+		t.Errorf("groups = %+v", g.Groups)
+	}
+}
+
+func TestParsePlantUMLSequence(t *testing.T) {
+	src := `@startuml
+' comment
+title Checkout
+actor "Shopper" as S
+participant Shop
+database DB
+S -> Shop : add to cart
+Shop --> S : ok
+Shop <- DB : stock level
+note right of Shop
+  reserves
+  stock
+end note
+@enduml`
+	g, err := Parse(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Kind != KindSequence || g.Title != "Checkout" {
+		t.Errorf("got kind %q, title %q", g.Kind, g.Title)
+	}
+	want := []string{"S>Shop:add to cart", "Shop>S:ok", "DB>Shop:stock level"}
+	if got := edgeList(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+	if n, _ := g.Node("DB"); n.Shape != ShapeDatabase {
+		t.Errorf("DB shape = %q", n.Shape)
+	}
+	if len(g.Notes) != 1 || g.Notes[0].Text != "reserves stock" {
+		t.Errorf("notes = %+v", g.Notes)
+	}
+}
+
+func TestParsePlantUMLComponents(t *testing.T) {
+	src := `@startuml
+left to right direction
+package "Backend" {
+  [API] as api
+  database "Orders" as orders
+}
+[Web] --> api : HTTPS
+api ..> orders
+@enduml`
+	g, err := Parse(src, SyntaxPlantUML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Kind != KindFlowchart || g.Direction != "LR" {
+		t.Errorf("got kind %q, direction %q", g.Kind, g.Direction)
+	}
+	want := []string{"Web>api:HTTPS", "api>orders:"}
+	if got := edgeList(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("edges = %v, want %v", got, want)
+	}
+	if len(g.Groups) != 1 || !reflect.DeepEqual(g.Groups[0].Nodes, []string{"api", "orders"}) {
+		t.Errorf("groups = %+v", g.Groups)
+	}
+}
+
+func TestParsePlantUMLActivity(t *testing.T) {
+	src := `@startuml
+start
+:Read order;
+if (In stock?) then (yes)
+  :Ship;
+else (no)
+  :Backorder;
+endif
+:Notify customer;
+stop
+@enduml`
+	g, err := Parse(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := make([]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		labels[i] = n.Label
+	}
+	if want := []string{"", "Read order", "In stock?", "Ship", "Backorder", "Notify customer", ""}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("nodes = %q, want %q", labels, want)
+	}
+	want := []string{"n1>n2:", "n2>n3:", "n3>n4:yes", "n3>n5:no", "n4>n6:", "n5>n6:", "n6>n7:"}
+	if got := edgeList(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("edges = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"unknown syntax":    "just some prose",
+		"unclosed node":     "flowchart TD\n  A[Start --> B",
+		"unclosed subgraph": "flowchart TD\n  subgraph X\n  A --> B",
+		"unclosed cluster":  "digraph { a -> b ",
+		"unbalanced endif":  "@startuml\nstart\n:a;\nendif\n@enduml",
+	} {
+		if _, err := Parse(src, ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	g, err := Parse("flowchart TD\n  A[Login] -->|ok| B[Home]\n  A -.- C[Help]\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := g.Describe()
+	for _, want := range []string{`Draw exactly these 3 nodes`, `1. "Login" - rectangle`, `"Login" → "Home", labeled "ok"`, `"Login" — "Help" (dashed, no arrowhead)`, "top to bottom"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("description missing %q:\n%s", want, desc)
+		}
+	}
+	if got := g.Labels(); !reflect.DeepEqual(got, []string{"Login", "Home", "Help", "ok"}) {
+		t.Errorf("labels = %q", got)
+	}
+}
+
+// textItem finds the drawn text line with the given content
+func textItem(t *testing.T, s *Scene, text string) item {
+	t.Helper()
+	for _, it := range s.items {
+		if it.text == text {
+			return it
+		}
+	}
+	t.Fatalf("scene has no text %q", text)
+	return item{}
+}
+
+func TestLayoutFlowchart(t *testing.T) {
+	g, err := Parse("flowchart TD\n  A[Login] --> B[Home]\n  A --> C[Help]\n  B --> D[Logout]\n  D --> A\n  C --> D\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Layout(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, home, help, logout := textItem(t, s, "Login"), textItem(t, s, "Home"), textItem(t, s, "Help"), textItem(t, s, "Logout")
+	if !(login.y < home.y && home.y == help.y && help.y < logout.y) {
+		t.Errorf("layers out of order: Login %v, Home %v, Help %v, Logout %v", login.y, home.y, help.y, logout.y)
+	}
+	if home.x == help.x {
+		t.Error("nodes in one layer should not overlap")
+	}
+
+	g.Direction = "LR"
+	if s, err = Layout(g); err != nil {
+		t.Fatal(err)
+	}
+	if login, logout := textItem(t, s, "Login"), textItem(t, s, "Logout"); login.x >= logout.x {
+		t.Errorf("LR should flow left to right: Login %v, Logout %v", login.x, logout.x)
+	}
+
+	svg := string(s.SVG())
+	for _, want := range []string{"<svg", ">Login</text>", ">Logout</text>"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG missing %q", want)
+		}
+	}
+	img, err := s.Image(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Rect.Dx() != int(s.Width*2) || img.Rect.Dy() != int(s.Height*2) {
+		t.Errorf("image is %v for a %vx%v scene at 2x", img.Rect.Size(), s.Width, s.Height)
+	}
+}
+
+func TestOrderRemovesCrossings(t *testing.T) {
+	// a→y and b→x cross until the bottom layer is reordered
+	layers := [][]int{{0, 1}, {2, 3}}
+	up := [][]int{nil, nil, {1}, {0}}
+	below := [][]int{{3}, {2}, nil, nil}
+	pos := make([]int, 4)
+	for _, layer := range layers {
+		for i, v := range layer {
+			pos[v] = i
+		}
+	}
+	if c := crossings(layers, below, pos); c != 1 {
+		t.Fatalf("expected 1 crossing before ordering, got %d", c)
+	}
+	order(layers, up, below, pos)
+	if c := crossings(layers, below, pos); c != 0 {
+		t.Errorf("expected no crossings after ordering, got %d: %v", c, layers)
+	}
+}
+
+func TestLayoutSequence(t *testing.T) {
+	g, err := Parse("sequenceDiagram\n  participant A as Client\n  participant B as Server\n  A->>B: request\n  Note over B: thinks\n  B-->>A: response\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Layout(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := textItem(t, s, "Client"), textItem(t, s, "Server")
+	if client.x >= server.x {
+		t.Errorf("participants out of order: Client %v, Server %v", client.x, server.x)
+	}
+	request, note, response := textItem(t, s, "request"), textItem(t, s, "thinks"), textItem(t, s, "response")
+	if !(client.y < request.y && request.y < note.y && note.y < response.y) {
+		t.Errorf("messages out of order: request %v, note %v, response %v", request.y, note.y, response.y)
+	}
+}
+
+func TestLookupType(t *testing.T) {
+	for name, want := range map[string]string{"ERD": "erd", "entity-relationship": "erd", "c4": "c4-context", " Mind-Map ": "mindmap", "sequence": "sequence"} {
+		got, err := LookupType(name)
+		if err != nil || got.Name != want {
+			t.Errorf("LookupType(%q) = %q, %v; want %q", name, got.Name, err, want)
+		}
+	}
+	if _, err := LookupType("infographic"); err == nil {
+		t.Error("unknown types should be rejected")
+	}
+
+	seen := map[string]bool{}
+	for _, typ := range Types {
+		for _, name := range append([]string{typ.Name}, typ.Aliases...) {
+			if seen[name] {
+				t.Errorf("%q is registered twice", name)
+			}
+			seen[name] = true
+		}
+		if err := gemini.ValidateAspectRatio(typ.AspectRatio); err != nil || typ.AspectRatio == "" {
+			t.Errorf("%s: bad aspect ratio %q", typ.Name, typ.AspectRatio)
+		}
+		if p := typ.Prompt("a test subject"); !strings.Contains(p, "a test subject") || strings.Contains(p, "%!") {
+			t.Errorf("%s: bad prompt %q", typ.Name, p)
+		}
+	}
+	// Graphs from --from pick their type by kind
+	for _, kind := range []string{KindFlowchart, KindSequence} {
+		if _, err := LookupType(kind); err != nil {
+			t.Errorf("kind %q has no type", kind)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// dotShapes maps Graphviz node shapes to diagram shapes
+var dotShapes = map[string]string{
+	"box":          ShapeBox,
+	"rect":         ShapeBox,
+	"rectangle":    ShapeBox,
+	"square":       ShapeBox,
+	"record":       ShapeBox,
+	"Mrecord":      ShapeRounded,
+	"ellipse":      ShapeEllipse,
+	"oval":         ShapeEllipse,
+	"circle":       ShapeCircle,
+	"doublecircle": ShapeCircle,
+	"point":        ShapeCircle,
+	"diamond":      ShapeDiamond,
+	"hexagon":      ShapeHexagon,
+	"cylinder":     ShapeDatabase,
+}
+
+// dotToken is a DOT lexeme. Quoted strings keep their quotes so they can't be
+// mistaken for keywords or punctuation.
+type dotToken struct {
+	text   string
+	quoted bool
+}
+
+// parseDOT parses Graphviz DOT: nodes, edges (including chains), attributes for
+// labels, shapes and styles, and clusters as groups
+func parseDOT(src string) (*Graph, error) {
+	toks, err := dotTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &dotParser{toks: toks, b: newGraphBuilder(KindFlowchart), defaultShape: ShapeEllipse}
+	p.b.g.Direction = "TB"
+
+	if p.keyword("strict") {
+		p.pos++
+	}
+	switch {
+	case p.keyword("digraph"):
+		p.directed = true
+	case p.keyword("graph"):
+	default:
+		return nil, fmt.Errorf("expected graph or digraph at %q", p.peek())
+	}
+	p.pos++
+	if p.peek() != "{" {
+		p.pos++ // graph name
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if _, err := p.statements(-1); err != nil {
+		return nil, err
+	}
+	return p.b.g, nil
+}
+
+type dotParser struct {
+	toks         []dotToken
+	pos          int
+	b            *graphBuilder
+	directed     bool
+	defaultShape string
+	edgeDashed   bool
+}
+
+// peek returns the current token's text, or "" at the end
+func (p *dotParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos].text
+}
+
+// keyword reports whether the current token is the unquoted keyword kw
+func (p *dotParser) keyword(kw string) bool {
+	return p.pos < len(p.toks) && !p.toks[p.pos].quoted && strings.EqualFold(p.toks[p.pos].text, kw)
+}
+
+// punct reports whether the current token is the punctuation s
+func (p *dotParser) punct(s string) bool {
+	return p.pos < len(p.toks) && !p.toks[p.pos].quoted && p.toks[p.pos].text == s
+}
+
+func (p *dotParser) expect(s string) error {
+	if !p.punct(s) {
+		return fmt.Errorf("expected %q at %q", s, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// id consumes an ID token
+func (p *dotParser) id() (string, error) {
+	if p.pos >= len(p.toks) {
+		return "", fmt.Errorf("unexpected end of input")
+	}
+	t := p.toks[p.pos]
+	if !t.quoted && (strings.ContainsAny(t.text, "{}[];,=:") || t.text == "->" || t.text == "--") {
+		return "", fmt.Errorf("expected an ID at %q", t.text)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// statements parses statements up to the closing brace and returns the IDs of the
+// nodes they mention. group is the index of the enclosing subgraph's group, or -1 at
+// the top level.
+func (p *dotParser) statements(group int) ([]string, error) {
+	var mentioned []string
+	for {
+		if p.pos >= len(p.toks) {
+			return nil, fmt.Errorf("missing closing brace")
+		}
+		if p.punct("}") {
+			p.pos++
+			return mentioned, nil
+		}
+		if p.punct(";") {
+			p.pos++
+			continue
+		}
+
+		switch {
+		case p.keyword("graph") || p.keyword("node") || p.keyword("edge"):
+			kind := strings.ToLower(p.peek())
+			p.pos++
+			attrs, err := p.attributes()
+			if err != nil {
+				return nil, err
+			}
+			switch kind {
+			case "graph":
+				p.graphAttributes(attrs, group)
+			case "node":
+				if shape, ok := dotShapes[attrs["shape"]]; ok {
+					p.defaultShape = shape
+				}
+			case "edge":
+				p.edgeDashed = dotDashed(attrs["style"])
+			}
+			continue
+		case p.pos+1 < len(p.toks) && p.toks[p.pos+1].text == "=" && !p.toks[p.pos+1].quoted:
+			key, _ := p.id()
+			p.pos++
+			value, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			p.graphAttributes(map[string]string{key: value}, group)
+			continue
+		}
+
+		ids, err := p.edgeStatement()
+		if err != nil {
+			return nil, err
+		}
+		mentioned = append(mentioned, ids...)
+	}
+}
+
+// graphAttributes applies graph-level attributes: a subgraph's label names its group,
+// the top level's sets the title and direction
+func (p *dotParser) graphAttributes(attrs map[string]string, group int) {
+	if group >= 0 {
+		if label, ok := attrs["label"]; ok {
+			p.b.g.Groups[group].Label = cleanLabel(label)
+		}
+		return
+	}
+	if label, ok := attrs["label"]; ok {
+		p.b.g.Title = cleanLabel(label)
+	}
+	if dir, ok := attrs["rankdir"]; ok {
+		p.b.g.Direction = strings.ToUpper(dir)
+	}
+}
+
+// edgeStatement parses a node, subgraph or chain of them joined by edge operators,
+// with optional attributes
+func (p *dotParser) edgeStatement() ([]string, error) {
+	var chain [][]string
+	var mentioned []string
+	for {
+		ids, err := p.endpoint()
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, ids)
+		mentioned = append(mentioned, ids...)
+		if !p.punct("->") && !p.punct("--") {
+			break
+		}
+		p.pos++
+	}
+
+	attrs := map[string]string{}
+	if p.punct("[") {
+		var err error
+		if attrs, err = p.attributes(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(chain) == 1 {
+		// A node statement: its attributes describe the node
+		for _, id := range chain[0] {
+			p.b.node(id, cleanLabel(attrs["label"]), dotShape(attrs))
+		}
+		return mentioned, nil
+	}
+
+	directed := p.directed && attrs["dir"] != "none"
+	dashed := p.edgeDashed
+	if style, ok := attrs["style"]; ok {
+		dashed = dotDashed(style)
+	}
+	for i := 1; i < len(chain); i++ {
+		for _, from := range chain[i-1] {
+			for _, to := range chain[i] {
+				p.b.g.Edges = append(p.b.g.Edges, Edge{From: from, To: to, Label: cleanLabel(attrs["label"]), Directed: directed, Dashed: dashed})
+			}
+		}
+	}
+	return mentioned, nil
+}
+
+// endpoint parses a node ID (dropping any port) or a subgraph
+func (p *dotParser) endpoint() ([]string, error) {
+	if p.keyword("subgraph") || p.punct("{") {
+		return p.subgraph()
+	}
+	id, err := p.id()
+	if err != nil {
+		return nil, err
+	}
+	for p.punct(":") {
+		p.pos++
+		if _, err := p.id(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := p.b.index[id]; !ok {
+		p.b.node(id, "", p.defaultShape)
+	}
+	return []string{id}, nil
+}
+
+// subgraph parses a subgraph; clusters and labeled subgraphs become groups
+func (p *dotParser) subgraph() ([]string, error) {
+	name := ""
+	if p.keyword("subgraph") {
+		p.pos++
+		if !p.punct("{") {
+			name, _ = p.id()
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	groupIndex := len(p.b.g.Groups)
+	p.b.g.Groups = append(p.b.g.Groups, Group{})
+	before := len(p.b.g.Nodes)
+	ids, err := p.statements(groupIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	grp := &p.b.g.Groups[groupIndex]
+	if grp.Label == "" && strings.HasPrefix(name, "cluster") {
+		grp.Label = cleanLabel(strings.TrimLeft(strings.TrimPrefix(name, "cluster"), "_-"))
+		if grp.Label == "" {
+			grp.Label = name
+		}
+	}
+	for _, n := range p.b.g.Nodes[before:] {
+		grp.Nodes = append(grp.Nodes, n.ID)
+	}
+	if grp.Label == "" {
+		// Unlabeled subgraphs like {rank=same; a; b} only steer the layout
+		p.b.g.Groups = append(p.b.g.Groups[:groupIndex], p.b.g.Groups[groupIndex+1:]...)
+	}
+	return ids, nil
+}
+
+// attributes parses one or more [key=value, ...] lists
+func (p *dotParser) attributes() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.punct("[") {
+		p.pos++
+		for !p.punct("]") {
+			if p.pos >= len(p.toks) {
+				return nil, fmt.Errorf("missing closing bracket")
+			}
+			if p.punct(",") || p.punct(";") {
+				p.pos++
+				continue
+			}
+			key, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			value := "true"
+			if p.punct("=") {
+				p.pos++
+				if value, err = p.id(); err != nil {
+					return nil, err
+				}
+			}
+			attrs[key] = value
+		}
+		p.pos++
+	}
+	return attrs, nil
+}
+
+// dotShape maps a node's shape and style attributes to a diagram shape, or "" if unset
+func dotShape(attrs map[string]string) string {
+	shape := dotShapes[attrs["shape"]]
+	if shape == ShapeBox && strings.Contains(attrs["style"], "rounded") {
+		return ShapeRounded
+	}
+	return shape
+}
+
+// dotDashed reports whether an edge style draws a broken line
+func dotDashed(style string) bool {
+	return strings.Contains(style, "dashed") || strings.Contains(style, "dotted")
+}
+
+// dotTokens splits DOT source into tokens, dropping comments
+func dotTokens(src string) ([]dotToken, error) {
+	var toks []dotToken
+	r := []rune(src)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '/' && i+1 < len(r) && r[i+1] == '/', c == '#' && (i == 0 || r[i-1] == '\n'):
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			end := i + 2
+			for end+1 < len(r) && (r[end] != '*' || r[end+1] != '/') {
+				end++
+			}
+			if end+1 >= len(r) {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i = end + 2
+		case c == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(r) && r[i] != '"'; i++ {
+				if r[i] == '\\' && i+1 < len(r) && r[i+1] == '"' {
+					i++
+				} else if r[i] == '\\' && i+1 < len(r) && r[i+1] == '\n' {
+					i++ // line continuation
+					continue
+				}
+				b.WriteRune(r[i])
+			}
+			if i >= len(r) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			toks = append(toks, dotToken{text: b.String(), quoted: true})
+		case c == '<':
+			// HTML-like label: keep the text, drop the tags
+			depth, start := 0, i
+			for ; i < len(r); i++ {
+				if r[i] == '<' {
+					depth++
+				} else if r[i] == '>' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if i >= len(r) {
+				return nil, fmt.Errorf("unterminated HTML label")
+			}
+			i++
+			toks = append(toks, dotToken{text: stripTags(string(r[start+1 : i-1])), quoted: true})
+		case c == '-' && i+1 < len(r) && (r[i+1] == '>' || r[i+1] == '-'):
+			toks = append(toks, dotToken{text: string(r[i : i+2])})
+			i += 2
+		case strings.ContainsRune("{}[];,=:", c):
+			toks = append(toks, dotToken{text: string(c)})
+			i++
+		default:
+			start := i
+			for i < len(r) && (unicode.IsLetter(r[i]) || unicode.IsDigit(r[i]) || r[i] == '_' || r[i] == '.' ||
+				(r[i] == '-' && i == start)) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			toks = append(toks, dotToken{text: string(r[start:i])})
+		}
+	}
+	return toks, nil
+}
+
+// stripTags removes markup from an HTML-like label, treating <br/> as a space
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, c := range s {
+		switch {
+		case c == '<':
+			inTag = true
+			b.WriteRune(' ')
+		case c == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(c)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
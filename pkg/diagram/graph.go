@@ -0,0 +1,225 @@
+package diagram
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Graph kinds parsed from diagram sources
+const (
+	KindFlowchart = "flowchart"
+	KindSequence  = "sequence"
+)
+
+// Node shapes, named after what they look like rather than any one syntax
+const (
+	ShapeBox      = "box"
+	ShapeRounded  = "rounded"
+	ShapeStadium  = "stadium"
+	ShapeEllipse  = "ellipse"
+	ShapeCircle   = "circle"
+	ShapeDiamond  = "diamond"
+	ShapeHexagon  = "hexagon"
+	ShapeDatabase = "database"
+	ShapeActor    = "actor" // sequence participants drawn as stick figures
+)
+
+// Graph is a diagram parsed from Mermaid, DOT or PlantUML. For sequence diagrams the
+// nodes are the participants, left to right, and the edges are the messages, top to
+// bottom.
+type Graph struct {
+	Kind      string
+	Title     string
+	Direction string // flowchart direction: TB, BT, LR or RL
+	Nodes     []Node
+	Edges     []Edge
+	Groups    []Group
+	Notes     []Note
+}
+
+// Node is a flowchart node or sequence participant
+type Node struct {
+	ID    string
+	Label string
+	Shape string
+}
+
+// Edge is a flowchart connection or sequence message
+type Edge struct {
+	From     string
+	To       string
+	Label    string
+	Directed bool
+	Dashed   bool
+}
+
+// Group is a labeled box around nodes, e.g. a Mermaid subgraph or DOT cluster
+type Group struct {
+	Label string
+	Nodes []string
+}
+
+// Note is free text attached to sequence participants
+type Note struct {
+	Over  []string
+	Text  string
+	After int // number of messages before the note
+}
+
+// Load parses a diagram source file, picking the syntax from its extension:
+// .mmd/.mermaid, .dot/.gv or .puml/.plantuml/.pu
+func Load(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read diagram source: %w", err)
+	}
+	return Parse(string(data), SyntaxFor(path))
+}
+
+// Diagram source syntaxes
+const (
+	SyntaxMermaid  = "mermaid"
+	SyntaxDOT      = "dot"
+	SyntaxPlantUML = "plantuml"
+)
+
+// SyntaxFor returns the syntax implied by a file name, or "" to detect it from the content
+func SyntaxFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmd", ".mermaid":
+		return SyntaxMermaid
+	case ".dot", ".gv":
+		return SyntaxDOT
+	case ".puml", ".plantuml", ".pu":
+		return SyntaxPlantUML
+	}
+	return ""
+}
+
+// Parse parses diagram source in the given syntax. An empty syntax is detected from
+// the content.
+func Parse(src, syntax string) (*Graph, error) {
+	if syntax == "" {
+		syntax = detectSyntax(src)
+	}
+
+	var g *Graph
+	var err error
+	switch syntax {
+	case SyntaxMermaid:
+		g, err = parseMermaid(src)
+	case SyntaxDOT:
+		g, err = parseDOT(src)
+	case SyntaxPlantUML:
+		g, err = parsePlantUML(src)
+	default:
+		return nil, fmt.Errorf("unrecognized diagram source (use Mermaid, DOT or PlantUML)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", syntax, err)
+	}
+	if len(g.Nodes) == 0 {
+		return nil, fmt.Errorf("failed to parse %s: no nodes found", syntax)
+	}
+	return g, nil
+}
+
+var dotHeader = regexp.MustCompile(`(?m)^\s*(strict\s+)?(di)?graph\b[^{]*\{`)
+
+// detectSyntax guesses the syntax of diagram source
+func detectSyntax(src string) string {
+	switch {
+	case strings.Contains(src, "@startuml"):
+		return SyntaxPlantUML
+	case dotHeader.MatchString(src):
+		return SyntaxDOT
+	case mermaidHeader.MatchString(src):
+		return SyntaxMermaid
+	}
+	return ""
+}
+
+// graphBuilder collects nodes in order of first appearance
+type graphBuilder struct {
+	g     *Graph
+	index map[string]int
+}
+
+func newGraphBuilder(kind string) *graphBuilder {
+	return &graphBuilder{g: &Graph{Kind: kind}, index: map[string]int{}}
+}
+
+// node declares a node, or updates the label and shape of an existing one when given
+func (b *graphBuilder) node(id, label, shape string) {
+	if i, ok := b.index[id]; ok {
+		if label != "" {
+			b.g.Nodes[i].Label = label
+		}
+		if shape != "" {
+			b.g.Nodes[i].Shape = shape
+		}
+		return
+	}
+	if label == "" {
+		label = id
+	}
+	if shape == "" {
+		shape = ShapeBox
+	}
+	b.index[id] = len(b.g.Nodes)
+	b.g.Nodes = append(b.g.Nodes, Node{ID: id, Label: label, Shape: shape})
+}
+
+// Node returns the node with the given ID
+func (g *Graph) Node(id string) (Node, bool) {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return Node{}, false
+}
+
+// Labels returns every piece of text the diagram should show, in order and without
+// duplicates
+func (g *Graph) Labels() []string {
+	var labels []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" && !seen[s] {
+			seen[s] = true
+			labels = append(labels, s)
+		}
+	}
+
+	add(g.Title)
+	for _, grp := range g.Groups {
+		add(grp.Label)
+	}
+	for _, n := range g.Nodes {
+		add(n.Label)
+	}
+	for _, e := range g.Edges {
+		add(e.Label)
+	}
+	for _, n := range g.Notes {
+		add(n.Text)
+	}
+	return labels
+}
+
+// cleanLabel turns source label markup into plain text: quotes are dropped and line
+// breaks become spaces
+func cleanLabel(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	s = lineBreak.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var lineBreak = regexp.MustCompile(`(?i)<br\s*/?>|\\[nlr]`)
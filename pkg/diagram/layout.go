@@ -0,0 +1,825 @@
+package diagram
+
+import (
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Layout metrics, in pixels at 1x
+const (
+	fontSize    = 14.0
+	titleSize   = 20.0
+	lineHeight  = 18.0
+	baseline    = lineHeight/2 + fontSize*0.35 // from the top of a line to its baseline
+	padX        = 14.0
+	padY        = 10.0
+	wrapWidth   = 180.0
+	rankGap     = 56.0
+	nodeGap     = 36.0
+	dummyGap    = 16.0
+	margin      = 24.0
+	lineWidth   = 1.5
+	arrowLength = 10.0
+	cylinderRy  = 8.0
+)
+
+// Layout places the graph deterministically: a layered (Sugiyama) layout for
+// flowcharts and participant lanes for sequence diagrams
+func Layout(g *Graph) (*Scene, error) {
+	m, err := newMeasurer()
+	if err != nil {
+		return nil, err
+	}
+	s := &Scene{}
+	if g.Kind == KindSequence {
+		layoutSequence(s, g, m)
+	} else {
+		layoutFlowchart(s, g, m)
+	}
+	if g.Title != "" {
+		minX, minY, maxX, _ := s.extent(m)
+		s.text(g.Title, (minX+maxX)/2, minY-20, titleSize, true, true, colorInk)
+	}
+	s.fit(m)
+	return s, nil
+}
+
+// measurer sizes text with the same fonts the PNG is drawn with
+type measurer struct {
+	regular, bold font.Face
+}
+
+func newMeasurer() (*measurer, error) {
+	regular, err := loadFace(goregular.TTF, fontSize)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := loadFace(gobold.TTF, fontSize)
+	if err != nil {
+		return nil, err
+	}
+	return &measurer{regular: regular, bold: bold}, nil
+}
+
+// width returns the width of text at the given size
+func (m *measurer) width(text string, size float64, bold bool) float64 {
+	face := m.regular
+	if bold {
+		face = m.bold
+	}
+	return float64(font.MeasureString(face, text)) / 64 * size / fontSize
+}
+
+// wrap breaks text into lines no wider than max, never splitting words
+func (m *measurer) wrap(text string, max float64) []string {
+	var lines []string
+	for _, word := range strings.Fields(text) {
+		if n := len(lines); n > 0 && m.width(lines[n-1]+" "+word, fontSize, false) <= max {
+			lines[n-1] += " " + word
+		} else {
+			lines = append(lines, word)
+		}
+	}
+	return lines
+}
+
+// widest returns the width of the widest line
+func (m *measurer) widest(lines []string) float64 {
+	w := 0.0
+	for _, line := range lines {
+		w = math.Max(w, m.width(line, fontSize, false))
+	}
+	return w
+}
+
+// box is a node with its label wrapped and its size and center worked out
+type box struct {
+	shape string
+	lines []string
+	w, h  float64
+	x, y  float64
+}
+
+// newBox sizes a node so its label fits inside its shape
+func newBox(m *measurer, n Node) *box {
+	b := &box{shape: n.Shape, lines: m.wrap(n.Label, wrapWidth)}
+	tw, th := m.widest(b.lines), float64(len(b.lines))*lineHeight
+	switch n.Shape {
+	case ShapeStadium:
+		b.h = math.Max(th+2*padY, 40)
+		b.w = math.Max(tw+2*padX+b.h/2, 80)
+	case ShapeHexagon:
+		b.h = math.Max(th+2*padY, 40)
+		b.w = tw + 2*padX + b.h*0.6
+	case ShapeEllipse:
+		b.w, b.h = (tw+2*padX)*1.25, math.Max((th+2*padY)*1.3, 44)
+	case ShapeCircle:
+		b.w = math.Max(math.Max(tw+2*padX, th+2*padY), 48)
+		if len(b.lines) == 0 {
+			b.w = 20
+		}
+		b.h = b.w
+	case ShapeDiamond:
+		b.w, b.h = math.Max(tw*1.6+2*padX, 64), math.Max(th*1.6+2*padY, 56)
+	case ShapeDatabase:
+		b.w, b.h = math.Max(tw+2*padX, 80), th+2*padY+2*cylinderRy
+	case ShapeActor:
+		b.w, b.h = math.Max(tw, 40), 48+th
+	default:
+		b.w, b.h = math.Max(tw+2*padX, 80), math.Max(th+2*padY, 40)
+	}
+	return b
+}
+
+// drawBox adds a node's shape and label to the scene
+func (s *Scene) drawBox(b *box) {
+	left, top, right, bottom := b.x-b.w/2, b.y-b.h/2, b.x+b.w/2, b.y+b.h/2
+	labelY := b.y
+	var p pathBuilder
+	switch b.shape {
+	case ShapeRounded:
+		p.roundRect(left, top, b.w, b.h, 8)
+	case ShapeStadium:
+		p.roundRect(left, top, b.w, b.h, b.h/2)
+	case ShapeEllipse:
+		p.ellipse(b.x, b.y, b.w/2, b.h/2)
+	case ShapeCircle:
+		p.ellipse(b.x, b.y, b.w/2, b.w/2)
+		if len(b.lines) == 0 {
+			// Unlabeled circles are start and stop markers
+			s.shape(p, colorInk, colorInk, false)
+			return
+		}
+	case ShapeDiamond:
+		p.polygon(point{b.x, top}, point{right, b.y}, point{b.x, bottom}, point{left, b.y})
+	case ShapeHexagon:
+		inset := b.h * 0.3
+		p.polygon(point{left + inset, top}, point{right - inset, top}, point{right, b.y},
+			point{right - inset, bottom}, point{left + inset, bottom}, point{left, b.y})
+	case ShapeDatabase:
+		kx, ky := b.w/2*kappa, cylinderRy*kappa
+		p.move(left, top+cylinderRy)
+		p.line(left, bottom-cylinderRy)
+		p.cubic(left, bottom-cylinderRy+ky, b.x-kx, bottom, b.x, bottom)
+		p.cubic(b.x+kx, bottom, right, bottom-cylinderRy+ky, right, bottom-cylinderRy)
+		p.line(right, top+cylinderRy)
+		p.close()
+		s.shape(p, colorNodeFill, colorInk, false)
+		p = nil
+		p.ellipse(b.x, top+cylinderRy, b.w/2, cylinderRy)
+		labelY += cylinderRy
+	case ShapeActor:
+		p.ellipse(b.x, top+8, 8, 8)
+		s.shape(p, colorNodeFill, colorInk, false)
+		p = nil
+		p.polyline([]point{{b.x, top + 16}, {b.x, top + 32}})
+		p.polyline([]point{{b.x - 12, top + 22}, {b.x + 12, top + 22}})
+		p.polyline([]point{{b.x - 10, top + 44}, {b.x, top + 32}, {b.x + 10, top + 44}})
+		s.shape(p, color.NRGBA{}, colorInk, false)
+		s.textBlock(b.lines, b.x, top+48+float64(len(b.lines))*lineHeight/2, colorInk)
+		return
+	default:
+		p.polygon(point{left, top}, point{right, top}, point{right, bottom}, point{left, bottom})
+	}
+	s.shape(p, colorNodeFill, colorInk, false)
+	s.textBlock(b.lines, b.x, labelY, colorInk)
+}
+
+// shape adds a path with the given fill and stroke; a zero color skips either
+func (s *Scene) shape(p pathBuilder, fill, stroke color.NRGBA, dashed bool) {
+	s.items = append(s.items, item{path: p, fill: fill, stroke: stroke, width: lineWidth, dashed: dashed})
+}
+
+// text adds one line of text with its baseline at y
+func (s *Scene) text(text string, x, y, size float64, bold, center bool, c color.NRGBA) {
+	s.items = append(s.items, item{text: text, x: x, y: y, size: size, bold: bold, center: center, fill: c})
+}
+
+// textBlock adds centered lines of text, vertically centered on cy
+func (s *Scene) textBlock(lines []string, cx, cy float64, c color.NRGBA) {
+	top := cy - float64(len(lines))*lineHeight/2
+	for i, line := range lines {
+		s.text(line, cx, top+float64(i)*lineHeight+baseline, fontSize, false, true, c)
+	}
+}
+
+// edgeLabel adds a connection label on a translucent background so lines behind it
+// don't cross the text
+func (s *Scene) edgeLabel(m *measurer, lines []string, cx, cy float64) {
+	if len(lines) == 0 {
+		return
+	}
+	w, h := m.widest(lines)+8, float64(len(lines))*lineHeight+4
+	var p pathBuilder
+	p.roundRect(cx-w/2, cy-h/2, w, h, 3)
+	s.shape(p, colorLabelFill, color.NRGBA{}, false)
+	s.textBlock(lines, cx, cy, colorMuted)
+}
+
+// connector adds a line through pts, ending in a filled arrowhead when directed
+func (s *Scene) connector(pts []point, directed, dashed bool) {
+	if len(pts) < 2 {
+		return
+	}
+	pts = append([]point(nil), pts...)
+	var head pathBuilder
+	tip, from := pts[len(pts)-1], pts[len(pts)-2]
+	if length := math.Hypot(tip.x-from.x, tip.y-from.y); directed && length > 0 {
+		// Stop the line at the arrowhead's base so its end doesn't poke through the tip
+		dx, dy := (tip.x-from.x)/length, (tip.y-from.y)/length
+		base := point{tip.x - dx*arrowLength, tip.y - dy*arrowLength}
+		head.polygon(tip, point{base.x - dy*arrowLength/2, base.y + dx*arrowLength/2}, point{base.x + dy*arrowLength/2, base.y - dx*arrowLength/2})
+		pts[len(pts)-1] = base
+	}
+	var p pathBuilder
+	p.polyline(pts)
+	s.shape(p, color.NRGBA{}, colorInk, dashed)
+	if len(head) > 0 {
+		s.shape(head, colorInk, color.NRGBA{}, false)
+	}
+}
+
+// extent returns the bounding box of everything drawn so far
+func (s *Scene) extent(m *measurer) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	add := func(x, y float64) {
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	for _, it := range s.items {
+		if it.text != "" {
+			w := m.width(it.text, it.size, it.bold)
+			x := it.x
+			if it.center {
+				x -= w / 2
+			}
+			add(x, it.y-it.size)
+			add(x+w, it.y+it.size*0.3)
+			continue
+		}
+		for _, op := range it.path {
+			for _, p := range op.pts {
+				add(p.x-it.width, p.y-it.width)
+				add(p.x+it.width, p.y+it.width)
+			}
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 0, 0
+	}
+	return minX, minY, maxX, maxY
+}
+
+// fit moves the drawing to start at the margin and sizes the scene around it
+func (s *Scene) fit(m *measurer) {
+	minX, minY, maxX, maxY := s.extent(m)
+	dx, dy := margin-minX, margin-minY
+	for i := range s.items {
+		it := &s.items[i]
+		it.x, it.y = it.x+dx, it.y+dy
+		path := make([]pathOp, len(it.path))
+		for j, op := range it.path {
+			pts := make([]point, len(op.pts))
+			for k, p := range op.pts {
+				pts[k] = point{p.x + dx, p.y + dy}
+			}
+			path[j] = pathOp{op.op, pts}
+		}
+		it.path = path
+	}
+	s.Width = math.Ceil(maxX - minX + 2*margin)
+	s.Height = math.Ceil(maxY - minY + 2*margin)
+}
+
+// route is an edge through the layer graph, from its upper to its lower end, with a
+// dummy vertex for every layer it crosses
+type route struct {
+	edge     Edge
+	chain    []int
+	reversed bool
+}
+
+// layoutFlowchart runs the classic layered layout: break cycles, assign layers, add
+// dummy vertices to long edges, order each layer to reduce crossings, then assign
+// coordinates. Everything is laid out top to bottom and rotated at the end.
+func layoutFlowchart(s *Scene, g *Graph, m *measurer) {
+	n := len(g.Nodes)
+	boxes := make([]*box, n)
+	index := make(map[string]int, n)
+	for i, node := range g.Nodes {
+		boxes[i] = newBox(m, node)
+		index[node.ID] = i
+	}
+	horizontal := g.Direction == "LR" || g.Direction == "RL"
+	across := func(b *box) float64 {
+		if horizontal {
+			return b.h
+		}
+		return b.w
+	}
+	along := func(b *box) float64 {
+		if horizontal {
+			return b.w
+		}
+		return b.h
+	}
+
+	// Split off self-loops; they don't take part in layering
+	var routes []*route
+	var loops []Edge
+	out := make([][]*route, n)
+	for _, e := range g.Edges {
+		u, v := index[e.From], index[e.To]
+		if u == v {
+			loops = append(loops, e)
+			continue
+		}
+		r := &route{edge: e, chain: []int{u, v}}
+		routes = append(routes, r)
+		out[u] = append(out[u], r)
+	}
+
+	// Break cycles by reversing the edges DFS finds pointing back up the stack
+	state := make([]int, n)
+	var visit func(u int)
+	visit = func(u int) {
+		state[u] = 1
+		for _, r := range out[u] {
+			switch v := r.chain[1]; state[v] {
+			case 0:
+				visit(v)
+			case 1:
+				r.reversed = true
+				r.chain = []int{v, u}
+			}
+		}
+		state[u] = 2
+	}
+	for u := range g.Nodes {
+		if state[u] == 0 {
+			visit(u)
+		}
+	}
+
+	// Longest-path layering in topological order, then pull sources down next to
+	// their first successor so they don't all pile up in the top layer
+	rank := make([]int, n)
+	indegree := make([]int, n)
+	down := make([][]int, n)
+	for _, r := range routes {
+		down[r.chain[0]] = append(down[r.chain[0]], r.chain[1])
+		indegree[r.chain[1]]++
+	}
+	var queue, topo []int
+	for u := 0; u < n; u++ {
+		if indegree[u] == 0 {
+			queue = append(queue, u)
+		}
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		topo = append(topo, u)
+		for _, v := range down[u] {
+			rank[v] = max(rank[v], rank[u]+1)
+			if indegree[v]--; indegree[v] == 0 {
+				queue = append(queue, v)
+			}
+		}
+	}
+	hasIn := make([]bool, n)
+	for _, r := range routes {
+		hasIn[r.chain[1]] = true
+	}
+	for i := len(topo) - 1; i >= 0; i-- {
+		if u := topo[i]; !hasIn[u] && len(down[u]) > 0 {
+			lowest := math.MaxInt
+			for _, v := range down[u] {
+				lowest = min(lowest, rank[v])
+			}
+			rank[u] = lowest - 1
+		}
+	}
+
+	// Dummy vertices on edges spanning several layers. Vertices past n are dummies.
+	size := make([]float64, n)
+	for i, b := range boxes {
+		size[i] = across(b)
+	}
+	for _, r := range routes {
+		u, v := r.chain[0], r.chain[1]
+		chain := []int{u}
+		for l := rank[u] + 1; l < rank[v]; l++ {
+			chain = append(chain, len(rank))
+			rank = append(rank, l)
+			size = append(size, 0)
+		}
+		r.chain = append(chain, v)
+	}
+	total := len(rank)
+	up := make([][]int, total)
+	below := make([][]int, total)
+	for _, r := range routes {
+		for i := 1; i < len(r.chain); i++ {
+			below[r.chain[i-1]] = append(below[r.chain[i-1]], r.chain[i])
+			up[r.chain[i]] = append(up[r.chain[i]], r.chain[i-1])
+		}
+	}
+
+	depth := 0
+	for _, l := range rank {
+		depth = max(depth, l+1)
+	}
+	layers := make([][]int, depth)
+	for v, l := range rank {
+		layers[l] = append(layers[l], v)
+	}
+	pos := make([]int, total)
+	order(layers, up, below, pos)
+
+	x := placeAcross(layers, up, below, size)
+
+	// Layer bands along the flow, widened for the labels of edges crossing a gap
+	thick := make([]float64, depth)
+	for v := 0; v < n; v++ {
+		thick[rank[v]] = math.Max(thick[rank[v]], along(boxes[v]))
+	}
+	labels := make([][]string, len(routes))
+	gap := make([]float64, depth)
+	for i := range gap {
+		gap[i] = rankGap
+	}
+	for i, r := range routes {
+		labels[i] = m.wrap(r.edge.Label, wrapWidth*0.75)
+		if len(labels[i]) == 0 {
+			continue
+		}
+		extent := float64(len(labels[i]))*lineHeight + 24
+		if horizontal {
+			extent = m.widest(labels[i]) + 32
+		}
+		l := rank[r.chain[(len(r.chain)-1)/2]]
+		gap[l] = math.Max(gap[l], extent)
+	}
+	center := make([]float64, depth)
+	for l := 1; l < depth; l++ {
+		center[l] = center[l-1] + thick[l-1]/2 + gap[l-1] + thick[l]/2
+	}
+
+	place := func(a, l float64) point {
+		switch g.Direction {
+		case "BT":
+			return point{a, -l}
+		case "LR":
+			return point{l, a}
+		case "RL":
+			return point{-l, a}
+		}
+		return point{a, l}
+	}
+	for v, b := range boxes {
+		p := place(x[v], center[rank[v]])
+		b.x, b.y = p.x, p.y
+	}
+
+	// Groups go behind everything, sized around their members
+	for _, grp := range g.Groups {
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, id := range grp.Nodes {
+			b := boxes[index[id]]
+			minX, minY = math.Min(minX, b.x-b.w/2), math.Min(minY, b.y-b.h/2)
+			maxX, maxY = math.Max(maxX, b.x+b.w/2), math.Max(maxY, b.y+b.h/2)
+		}
+		if math.IsInf(minX, 1) {
+			continue
+		}
+		top := 14.0
+		if grp.Label != "" {
+			top += lineHeight
+			maxX = math.Max(maxX, minX+m.width(grp.Label, fontSize, true)-14)
+		}
+		var p pathBuilder
+		p.roundRect(minX-14, minY-top, maxX-minX+28, maxY-minY+top+14, 6)
+		s.shape(p, colorGroupFill, colorGroupLine, true)
+		if grp.Label != "" {
+			s.text(grp.Label, minX-6, minY-top+baseline+2, fontSize, true, false, colorMuted)
+		}
+	}
+
+	// Spread edges leaving or entering the flat side of a box so they don't all meet
+	// at one point
+	ports := make(map[[2]int]float64)
+	spread := func(v int, ends []int, side int) {
+		if v >= n || len(ends) < 2 || (boxes[v].shape != ShapeBox && boxes[v].shape != ShapeRounded) {
+			return
+		}
+		sorted := append([]int(nil), ends...)
+		sort.SliceStable(sorted, func(i, j int) bool { return x[sorted[i]] < x[sorted[j]] })
+		step := math.Min(24, size[v]*0.6/float64(len(sorted)-1))
+		for i, w := range sorted {
+			ports[[2]int{v, side * (w + 1)}] = (float64(i) - float64(len(sorted)-1)/2) * step
+		}
+	}
+	for v := 0; v < n; v++ {
+		spread(v, below[v], 1)
+		spread(v, up[v], -1)
+	}
+
+	type labelAt struct {
+		lines []string
+		at    point
+	}
+	var pending []labelAt
+	for i, r := range routes {
+		u, v := r.chain[0], r.chain[len(r.chain)-1]
+		pts := []point{place(x[u]+ports[[2]int{u, r.chain[1] + 1}], center[rank[u]]+along(boxes[u])/2)}
+		for _, d := range r.chain[1 : len(r.chain)-1] {
+			l := rank[d]
+			pts = append(pts, place(x[d], center[l]-thick[l]/2), place(x[d], center[l]+thick[l]/2))
+		}
+		prev := r.chain[len(r.chain)-2]
+		pts = append(pts, place(x[v]+ports[[2]int{v, -(prev + 1)}], center[rank[v]]-along(boxes[v])/2))
+
+		if len(labels[i]) > 0 {
+			mid := (len(r.chain) - 1) / 2
+			a, b := pts[2*mid], pts[2*mid+1]
+			pending = append(pending, labelAt{labels[i], point{(a.x + b.x) / 2, (a.y + b.y) / 2}})
+		}
+		if r.reversed {
+			for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+				pts[i], pts[j] = pts[j], pts[i]
+			}
+		}
+		s.connector(pts, r.edge.Directed, r.edge.Dashed)
+	}
+
+	for _, e := range loops {
+		b := boxes[index[e.From]]
+		right := b.x + b.w/2
+		s.connector([]point{{right, b.y - 8}, {right + 20, b.y - 8}, {right + 20, b.y + 8}, {right, b.y + 8}}, e.Directed, e.Dashed)
+		if lines := m.wrap(e.Label, wrapWidth*0.75); len(lines) > 0 {
+			pending = append(pending, labelAt{lines, point{right + 28 + m.widest(lines)/2, b.y}})
+		}
+	}
+
+	for _, b := range boxes {
+		s.drawBox(b)
+	}
+	for _, l := range pending {
+		s.edgeLabel(m, l.lines, l.at.x, l.at.y)
+	}
+}
+
+// order arranges each layer with barycenter sweeps, alternating down and up, and
+// keeps the arrangement with the fewest crossings. pos receives each vertex's index
+// within its layer.
+func order(layers [][]int, up, below [][]int, pos []int) {
+	index := func() {
+		for _, layer := range layers {
+			for i, v := range layer {
+				pos[v] = i
+			}
+		}
+	}
+	index()
+	best := cloneLayers(layers)
+	fewest := crossings(layers, below, pos)
+
+	for sweep := 0; sweep < 24 && fewest > 0; sweep++ {
+		if sweep%2 == 0 {
+			for l := 1; l < len(layers); l++ {
+				sortLayer(layers[l], up, pos)
+			}
+		} else {
+			for l := len(layers) - 2; l >= 0; l-- {
+				sortLayer(layers[l], below, pos)
+			}
+		}
+		if c := crossings(layers, below, pos); c < fewest {
+			best, fewest = cloneLayers(layers), c
+		}
+	}
+	copy(layers, best)
+	index()
+}
+
+// sortLayer orders a layer by the mean position of each vertex's neighbors in the
+// adjacent layer. Vertices without neighbors keep their place.
+func sortLayer(layer []int, neighbors [][]int, pos []int) {
+	weight := make(map[int]float64, len(layer))
+	for _, v := range layer {
+		weight[v] = float64(pos[v])
+		if len(neighbors[v]) > 0 {
+			sum := 0.0
+			for _, w := range neighbors[v] {
+				sum += float64(pos[w])
+			}
+			weight[v] = sum / float64(len(neighbors[v]))
+		}
+	}
+	sort.SliceStable(layer, func(i, j int) bool { return weight[layer[i]] < weight[layer[j]] })
+	for i, v := range layer {
+		pos[v] = i
+	}
+}
+
+// crossings counts pairs of edges that cross between adjacent layers
+func crossings(layers [][]int, below [][]int, pos []int) int {
+	count := 0
+	for _, layer := range layers {
+		var pairs [][2]int
+		for _, v := range layer {
+			for _, w := range below[v] {
+				pairs = append(pairs, [2]int{pos[v], pos[w]})
+			}
+		}
+		for i := range pairs {
+			for j := i + 1; j < len(pairs); j++ {
+				a, b := pairs[i], pairs[j]
+				if (a[0] < b[0] && a[1] > b[1]) || (a[0] > b[0] && a[1] < b[1]) {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+func cloneLayers(layers [][]int) [][]int {
+	out := make([][]int, len(layers))
+	for i, layer := range layers {
+		out[i] = append([]int(nil), layer...)
+	}
+	return out
+}
+
+// placeAcross assigns each vertex a coordinate across the flow. Vertices are pulled
+// toward the mean of their neighbors, then pushed apart to keep their layer order
+// and spacing.
+func placeAcross(layers [][]int, up, below [][]int, size []float64) []float64 {
+	x := make([]float64, len(size))
+	sep := func(a, b int) float64 {
+		gap := nodeGap
+		if size[a] == 0 || size[b] == 0 {
+			gap = dummyGap
+		}
+		return size[a]/2 + size[b]/2 + gap
+	}
+	for _, layer := range layers {
+		for i, v := range layer {
+			if i > 0 {
+				x[v] = x[layer[i-1]] + sep(layer[i-1], v)
+			}
+		}
+	}
+
+	for pass := 0; pass < 12; pass++ {
+		for k := range layers {
+			l := k
+			if pass%2 == 1 {
+				l = len(layers) - 1 - k
+			}
+			layer := layers[l]
+			want := make([]float64, len(layer))
+			for i, v := range layer {
+				want[i] = x[v]
+				if nbrs := append(append([]int(nil), up[v]...), below[v]...); len(nbrs) > 0 {
+					sum := 0.0
+					for _, w := range nbrs {
+						sum += x[w]
+					}
+					want[i] = sum / float64(len(nbrs))
+				}
+			}
+			// Each sweep alone keeps the spacing; so does their average
+			left := make([]float64, len(layer))
+			right := make([]float64, len(layer))
+			for i := range layer {
+				left[i] = want[i]
+				if i > 0 {
+					left[i] = math.Max(want[i], left[i-1]+sep(layer[i-1], layer[i]))
+				}
+			}
+			for i := len(layer) - 1; i >= 0; i-- {
+				right[i] = want[i]
+				if i < len(layer)-1 {
+					right[i] = math.Min(want[i], right[i+1]-sep(layer[i], layer[i+1]))
+				}
+			}
+			for i, v := range layer {
+				x[v] = (left[i] + right[i]) / 2
+			}
+		}
+	}
+	return x
+}
+
+// layoutSequence puts participants in lanes left to right and messages top to bottom,
+// with notes between the messages they followed in the source
+func layoutSequence(s *Scene, g *Graph, m *measurer) {
+	n := len(g.Nodes)
+	boxes := make([]*box, n)
+	index := make(map[string]int, n)
+	headH := 0.0
+	for i, node := range g.Nodes {
+		if node.Shape != ShapeActor && node.Shape != ShapeDatabase {
+			node.Shape = ShapeBox
+		}
+		boxes[i] = newBox(m, node)
+		index[node.ID] = i
+		headH = math.Max(headH, boxes[i].h)
+	}
+
+	// Lanes are wide enough for the participants and every message label between them
+	gap := make([]float64, n)
+	for i := 0; i+1 < n; i++ {
+		gap[i] = boxes[i].w/2 + boxes[i+1].w/2 + 32
+	}
+	messages := make([][]string, len(g.Edges))
+	for i, e := range g.Edges {
+		a, b := index[e.From], index[e.To]
+		if a > b {
+			a, b = b, a
+		}
+		messages[i] = m.wrap(e.Label, wrapWidth*1.5)
+		need := m.widest(messages[i]) + 32
+		if a == b {
+			if a+1 < n {
+				gap[a] = math.Max(gap[a], need+40)
+			}
+			continue
+		}
+		for k := a; k < b; k++ {
+			gap[k] = math.Max(gap[k], need/float64(b-a))
+		}
+	}
+	for i, b := range boxes {
+		if i > 0 {
+			b.x = boxes[i-1].x + gap[i-1]
+		}
+		b.y = headH - b.h/2
+	}
+
+	front := &Scene{}
+	y := headH + 24
+	note := func(nt Note) {
+		lines := m.wrap(nt.Text, wrapWidth)
+		w, h := m.widest(lines)+2*padX, float64(len(lines))*lineHeight+2*padY
+		minX, maxX := math.Inf(1), math.Inf(-1)
+		for _, id := range nt.Over {
+			minX, maxX = math.Min(minX, boxes[index[id]].x), math.Max(maxX, boxes[index[id]].x)
+		}
+		if math.IsInf(minX, 1) {
+			minX, maxX = boxes[0].x, boxes[n-1].x
+		}
+		w = math.Max(w, maxX-minX+40)
+		var p pathBuilder
+		p.polygon(point{(minX+maxX)/2 - w/2, y}, point{(minX+maxX)/2 + w/2, y}, point{(minX+maxX)/2 + w/2, y + h}, point{(minX+maxX)/2 - w/2, y + h})
+		front.shape(p, colorNoteFill, colorGroupLine, false)
+		front.textBlock(lines, (minX+maxX)/2, y+h/2, colorInk)
+		y += h + 16
+	}
+
+	next := 0
+	for i, e := range g.Edges {
+		for ; next < len(g.Notes) && g.Notes[next].After <= i; next++ {
+			note(g.Notes[next])
+		}
+		a, b := boxes[index[e.From]], boxes[index[e.To]]
+		labelH := float64(len(messages[i])) * lineHeight
+		if a == b {
+			loopH := math.Max(labelH, 16)
+			front.connector([]point{{a.x, y}, {a.x + 28, y}, {a.x + 28, y + loopH}, {a.x, y + loopH}}, e.Directed, e.Dashed)
+			for j, line := range messages[i] {
+				front.text(line, a.x+36, y+float64(j)*lineHeight+baseline, fontSize, false, false, colorInk)
+			}
+			y += loopH + 24
+			continue
+		}
+		front.textBlock(messages[i], (a.x+b.x)/2, y+labelH/2, colorInk)
+		y += labelH + 4
+		front.connector([]point{{a.x, y}, {b.x, y}}, e.Directed, e.Dashed)
+		y += 22
+	}
+	for ; next < len(g.Notes); next++ {
+		note(g.Notes[next])
+	}
+
+	for _, b := range boxes {
+		var p pathBuilder
+		p.polyline([]point{{b.x, headH}, {b.x, y}})
+		s.shape(p, color.NRGBA{}, colorMuted, true)
+	}
+	for _, b := range boxes {
+		s.drawBox(b)
+	}
+	s.items = append(s.items, front.items...)
+}
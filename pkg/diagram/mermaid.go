@@ -0,0 +1,323 @@
+package diagram
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var mermaidHeader = regexp.MustCompile(`(?m)^\s*(flowchart|graph|sequenceDiagram)\b`)
+
+// parseMermaid parses the flowchart and sequence diagram subsets of Mermaid
+func parseMermaid(src string) (*Graph, error) {
+	title, lines := mermaidLines(src)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty diagram")
+	}
+
+	header := strings.Fields(lines[0])
+	var g *Graph
+	var err error
+	switch header[0] {
+	case "flowchart", "graph":
+		direction := "TB"
+		if len(header) > 1 {
+			direction = strings.ToUpper(header[1])
+			if direction == "TD" {
+				direction = "TB"
+			}
+		}
+		g, err = parseMermaidFlowchart(lines[1:])
+		if g != nil {
+			g.Direction = direction
+		}
+	case "sequenceDiagram":
+		g, err = parseMermaidSequence(lines[1:])
+	default:
+		return nil, fmt.Errorf("unsupported diagram type %q (flowchart, graph and sequenceDiagram are supported)", header[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if g.Title == "" {
+		g.Title = title
+	}
+	return g, nil
+}
+
+// mermaidLines returns the non-empty lines of a Mermaid source without comments and
+// directives, along with the title from its front matter
+func mermaidLines(src string) (string, []string) {
+	var title string
+	var lines []string
+	frontMatter := false
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "---" && len(lines) == 0 {
+			frontMatter = !frontMatter
+			continue
+		}
+		if frontMatter {
+			if rest, ok := strings.CutPrefix(line, "title:"); ok {
+				title = cleanLabel(rest)
+			}
+			continue
+		}
+		if i := strings.Index(line, "%%"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return title, lines
+}
+
+// mermaidShapes maps node delimiters to shapes, longest openers first
+var mermaidShapes = []struct {
+	open, close, shape string
+}{
+	{"(((", ")))", ShapeCircle},
+	{"([", "])", ShapeStadium},
+	{"[[", "]]", ShapeBox},
+	{"[(", ")]", ShapeDatabase},
+	{"((", "))", ShapeCircle},
+	{"{{", "}}", ShapeHexagon},
+	{"[/", "/]", ShapeBox},
+	{"[\\", "\\]", ShapeBox},
+	{"[", "]", ShapeBox},
+	{"(", ")", ShapeRounded},
+	{"{", "}", ShapeDiamond},
+	{">", "]", ShapeBox},
+}
+
+var (
+	mermaidID       = regexp.MustCompile(`^[\p{L}\p{N}_]+`)
+	mermaidClass    = regexp.MustCompile(`^:::[\w-]+`)
+	mermaidLinkText = regexp.MustCompile(`^(<)?(--|==|-\.)\s+(.+?)\s+(-{2,}|={2,}|\.-+)(>|x|o)?`)
+	mermaidLink     = regexp.MustCompile(`^(<)?(-{2,}|={2,}|-\.+-|~~~)(>|x|o)?(?:\|([^|]*)\|)?`)
+	mermaidSubgraph = regexp.MustCompile(`^subgraph\s+(.+)$`)
+)
+
+// mermaidIgnored lists flowchart statements that only affect styling
+var mermaidIgnored = []string{"classDef ", "class ", "style ", "linkStyle ", "click ", "direction "}
+
+// parseMermaidFlowchart parses flowchart statements
+func parseMermaidFlowchart(lines []string) (*Graph, error) {
+	b := newGraphBuilder(KindFlowchart)
+	var groups []int // open subgraphs, innermost last
+
+	for _, line := range lines {
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" || hasAnyPrefix(stmt, mermaidIgnored) {
+				continue
+			}
+			if stmt == "end" {
+				if len(groups) == 0 {
+					return nil, fmt.Errorf("\"end\" without a subgraph")
+				}
+				groups = groups[:len(groups)-1]
+				continue
+			}
+			if m := mermaidSubgraph.FindStringSubmatch(stmt); m != nil {
+				b.g.Groups = append(b.g.Groups, Group{Label: mermaidSubgraphLabel(m[1])})
+				groups = append(groups, len(b.g.Groups)-1)
+				continue
+			}
+
+			before := len(b.g.Nodes)
+			if err := mermaidStatement(b, stmt); err != nil {
+				return nil, err
+			}
+			// Nodes belong to the subgraph they first appear in
+			if len(groups) > 0 {
+				grp := &b.g.Groups[groups[len(groups)-1]]
+				for _, n := range b.g.Nodes[before:] {
+					grp.Nodes = append(grp.Nodes, n.ID)
+				}
+			}
+		}
+	}
+	if len(groups) > 0 {
+		return nil, fmt.Errorf("subgraph %q is missing its \"end\"", b.g.Groups[groups[len(groups)-1]].Label)
+	}
+	return b.g, nil
+}
+
+// mermaidSubgraphLabel returns the label of "id [Label]", "\"Label\"" or "Label"
+func mermaidSubgraphLabel(s string) string {
+	if i := strings.Index(s, "["); i > 0 && strings.HasSuffix(s, "]") {
+		return cleanLabel(s[i+1 : len(s)-1])
+	}
+	return cleanLabel(s)
+}
+
+// mermaidStatement parses a node declaration or a chain of links such as
+// "A[Start] --> B{Ok?} -->|yes| C & D"
+func mermaidStatement(b *graphBuilder, stmt string) error {
+	rest := stmt
+	from, err := mermaidNodes(b, &rest)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return nil
+		}
+
+		var label, head string
+		var dashed, invisible bool
+		if m := mermaidLinkText.FindStringSubmatch(rest); m != nil {
+			label, head, dashed = m[3], m[5], strings.Contains(m[2], ".")
+			rest = rest[len(m[0]):]
+		} else if m := mermaidLink.FindStringSubmatch(rest); m != nil {
+			label, head, dashed, invisible = m[4], m[3], strings.Contains(m[2], "."), m[2] == "~~~"
+			rest = rest[len(m[0]):]
+		} else {
+			return fmt.Errorf("unexpected %q in %q", rest, stmt)
+		}
+
+		rest = strings.TrimSpace(rest)
+		to, err := mermaidNodes(b, &rest)
+		if err != nil {
+			return err
+		}
+		for _, f := range from {
+			for _, t := range to {
+				if invisible {
+					continue // ~~~ only nudges the layout
+				}
+				b.g.Edges = append(b.g.Edges, Edge{From: f, To: t, Label: cleanLabel(label), Directed: head != "", Dashed: dashed})
+			}
+		}
+		from = to
+	}
+}
+
+// mermaidNodes parses one or more nodes joined by "&" from the start of s
+func mermaidNodes(b *graphBuilder, s *string) ([]string, error) {
+	var ids []string
+	for {
+		id, err := mermaidNode(b, s)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+
+		rest := strings.TrimSpace(*s)
+		if !strings.HasPrefix(rest, "&") {
+			return ids, nil
+		}
+		*s = strings.TrimSpace(rest[1:])
+	}
+}
+
+// mermaidNode parses a node reference such as A, A[Label] or A{"Label"} from the start of s
+func mermaidNode(b *graphBuilder, s *string) (string, error) {
+	id := mermaidID.FindString(*s)
+	if id == "" {
+		return "", fmt.Errorf("expected a node at %q", *s)
+	}
+	rest := (*s)[len(id):]
+
+	label, shape := "", ""
+	for _, sh := range mermaidShapes {
+		if !strings.HasPrefix(rest, sh.open) {
+			continue
+		}
+		body := rest[len(sh.open):]
+		end := -1
+		if strings.HasPrefix(body, `"`) {
+			if q := strings.Index(body[1:], `"`); q >= 0 && strings.HasPrefix(body[q+2:], sh.close) {
+				end = q + 2
+			}
+		} else {
+			end = strings.Index(body, sh.close)
+		}
+		if end < 0 {
+			return "", fmt.Errorf("unclosed %q in node %s", sh.open, id)
+		}
+		label, shape = cleanLabel(body[:end]), sh.shape
+		rest = body[end+len(sh.close):]
+		break
+	}
+	rest = mermaidClass.ReplaceAllString(rest, "")
+
+	b.node(id, label, shape)
+	*s = rest
+	return id, nil
+}
+
+var (
+	mermaidParticipant = regexp.MustCompile(`^(participant|actor)\s+(\S+)(?:\s+as\s+(.+))?$`)
+	mermaidNote        = regexp.MustCompile(`(?i)^note\s+(?:left of|right of|over)\s+([^:]+):\s*(.*)$`)
+	mermaidMessage     = regexp.MustCompile(`^([^\s:<>+-]+)\s*(<<-->>|<<->>|-->>|->>|-->|->|--x|-x|--\)|-\))\s*[+-]?\s*([^\s:]+)\s*:\s*(.*)$`)
+)
+
+// mermaidBlocks lists sequence statements that only group or decorate messages
+var mermaidBlocks = []string{"loop", "alt", "else", "opt", "par", "and", "critical", "break", "rect", "end",
+	"activate", "deactivate", "autonumber", "box"}
+
+// parseMermaidSequence parses participants, messages and notes
+func parseMermaidSequence(lines []string) (*Graph, error) {
+	b := newGraphBuilder(KindSequence)
+
+	for _, line := range lines {
+		if rest, ok := strings.CutPrefix(line, "title"); ok {
+			b.g.Title = cleanLabel(strings.TrimPrefix(strings.TrimSpace(rest), ":"))
+			continue
+		}
+		if m := mermaidParticipant.FindStringSubmatch(line); m != nil {
+			shape := ShapeBox
+			if m[1] == "actor" {
+				shape = ShapeActor
+			}
+			b.node(m[2], cleanLabel(m[3]), shape)
+			continue
+		}
+		if m := mermaidNote.FindStringSubmatch(line); m != nil {
+			var over []string
+			for _, id := range strings.Split(m[1], ",") {
+				id = strings.TrimSpace(id)
+				b.node(id, "", "")
+				over = append(over, id)
+			}
+			b.g.Notes = append(b.g.Notes, Note{Over: over, Text: cleanLabel(m[2]), After: len(b.g.Edges)})
+			continue
+		}
+		if m := mermaidMessage.FindStringSubmatch(line); m != nil {
+			b.node(m[1], "", "")
+			b.node(m[3], "", "")
+			b.g.Edges = append(b.g.Edges, Edge{
+				From:     m[1],
+				To:       m[3],
+				Label:    cleanLabel(m[4]),
+				Directed: m[2] != "->" && m[2] != "-->",
+				Dashed:   strings.Contains(m[2], "--"),
+			})
+			continue
+		}
+		if slices.Contains(mermaidBlocks, strings.Fields(line)[0]) {
+			continue
+		}
+		return nil, fmt.Errorf("unsupported sequence statement %q", line)
+	}
+	return b.g, nil
+}
+
+// hasAnyPrefix reports whether s starts with any of the prefixes
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,453 @@
+package diagram
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// parsePlantUML parses PlantUML sequence diagrams, component/use case diagrams and
+// activity diagrams (new syntax). The kind is detected from the statements used.
+func parsePlantUML(src string) (*Graph, error) {
+	title, direction, lines := plantUMLLines(src)
+
+	var g *Graph
+	var err error
+	switch {
+	case slices.ContainsFunc(lines, isActivityLine):
+		g, err = parsePlantUMLActivity(lines)
+	case slices.ContainsFunc(lines, isComponentLine):
+		g, err = parsePlantUMLComponents(lines)
+	default:
+		g, err = parsePlantUMLSequence(lines)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.Title = title
+	if g.Kind == KindFlowchart {
+		g.Direction = direction
+	}
+	return g, nil
+}
+
+// plantUMLIgnored lists statements that only affect styling or numbering
+var plantUMLIgnored = []string{"skinparam", "hide", "show", "autonumber", "scale", "!", "header", "footer",
+	"caption", "legend", "endlegend", "center footer", "newpage", "allowmixing", "set "}
+
+// plantUMLLines returns the statements between @startuml and @enduml without
+// comments, along with the title and the layout direction
+func plantUMLLines(src string) (string, string, []string) {
+	title, direction := "", "TB"
+	var lines []string
+	inComment := false
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if inComment {
+			if strings.Contains(line, "'/") {
+				inComment = false
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "/'"):
+			inComment = !strings.Contains(line[2:], "'/")
+			continue
+		case line == "", strings.HasPrefix(line, "'"), strings.HasPrefix(line, "@start"), strings.HasPrefix(line, "@end"),
+			hasAnyPrefix(line, plantUMLIgnored):
+			continue
+		case strings.HasPrefix(line, "title "):
+			title = cleanLabel(line[len("title "):])
+			continue
+		case line == "left to right direction":
+			direction = "LR"
+			continue
+		case line == "top to bottom direction":
+			direction = "TB"
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return title, direction, lines
+}
+
+// isActivityLine reports whether a statement only exists in activity diagrams
+func isActivityLine(line string) bool {
+	return line == "start" || strings.HasPrefix(line, ":") && strings.HasSuffix(line, ";") ||
+		strings.HasPrefix(line, "if (") || strings.HasPrefix(line, "while (")
+}
+
+var (
+	plantUMLDeclaration = regexp.MustCompile(`^(participant|actor|boundary|control|entity|database|collections|queue|component|node|rectangle|cloud|package|frame|folder|usecase|interface|storage|artifact|card|agent|file)\s+(.+?)\s*(\{)?$`)
+	plantUMLComponent   = regexp.MustCompile(`\[[^\]]+\]|^\([^)]+\)`)
+)
+
+// plantUMLContainers are declarations that can hold other elements in braces
+var plantUMLContainers = []string{"node", "rectangle", "cloud", "package", "frame", "folder"}
+
+// isComponentLine reports whether a statement only exists in component or use case diagrams
+func isComponentLine(line string) bool {
+	if m := plantUMLDeclaration.FindStringSubmatch(line); m != nil {
+		switch m[1] {
+		case "participant", "actor", "boundary", "control", "entity", "database", "collections", "queue":
+			return false
+		}
+		return true
+	}
+	return plantUMLComponent.MatchString(line)
+}
+
+// plantUMLShapes maps element keywords to shapes
+var plantUMLShapes = map[string]string{
+	"actor":       ShapeActor,
+	"database":    ShapeDatabase,
+	"queue":       ShapeStadium,
+	"usecase":     ShapeEllipse,
+	"interface":   ShapeCircle,
+	"cloud":       ShapeRounded,
+	"collections": ShapeBox,
+}
+
+// plantUMLElement parses "X", "\"Long Name\" as X" or "X as \"Long Name\"" into an ID
+// and label
+func plantUMLElement(s string) (string, string) {
+	s = plantUMLColor.ReplaceAllString(s, "")
+	if a, b, ok := strings.Cut(s, " as "); ok {
+		a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+		if strings.HasPrefix(a, `"`) || strings.HasPrefix(a, "[") || strings.HasPrefix(a, "(") {
+			return b, plantUMLLabel(a)
+		}
+		return a, plantUMLLabel(b)
+	}
+	label := plantUMLLabel(s)
+	return label, label
+}
+
+var plantUMLColor = regexp.MustCompile(`\s+(#\w+|order\s+\d+|<<[^>]*>>)`)
+
+// plantUMLLabel strips quotes, [component] brackets, (use case) parens and :actor: colons
+func plantUMLLabel(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '[' && s[len(s)-1] == ']', s[0] == '(' && s[len(s)-1] == ')', s[0] == ':' && s[len(s)-1] == ':':
+			s = s[1 : len(s)-1]
+		}
+	}
+	return cleanLabel(s)
+}
+
+var (
+	plantUMLMessage = regexp.MustCompile(`^("[^"]+"|[^\s"<>.:-]+)\s*([ox]?<{0,2}-{1,2}(?:\[[^\]]*\])?-?>{0,2}[ox]?)\s*("[^"]+"|[^\s"<>.:-]+)\s*(?::\s*(.*))?$`)
+	plantUMLNote    = regexp.MustCompile(`(?i)^note\s+(?:left of|right of|over|left|right)\s*([^:]*?)\s*(?::\s*(.*))?$`)
+)
+
+// plantUMLNoteText returns the text of the note starting at lines[i], either after its
+// colon or up to "end note", and the index of the note's last line
+func plantUMLNoteText(lines []string, i int) (string, int) {
+	if _, text, ok := strings.Cut(lines[i], ":"); ok {
+		return cleanLabel(text), i
+	}
+	var body []string
+	for i++; i < len(lines) && !strings.EqualFold(lines[i], "end note"); i++ {
+		body = append(body, lines[i])
+	}
+	return cleanLabel(strings.Join(body, " ")), i
+}
+
+// plantUMLBlocks lists sequence statements that only group or decorate messages
+var plantUMLBlocks = []string{"alt", "else", "opt", "loop", "par", "break", "critical", "group", "end",
+	"activate", "deactivate", "destroy", "return", "box", "ref", "...", "|||", "=="}
+
+// parsePlantUMLSequence parses participants, messages and notes
+func parsePlantUMLSequence(lines []string) (*Graph, error) {
+	b := newGraphBuilder(KindSequence)
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := plantUMLDeclaration.FindStringSubmatch(line); m != nil {
+			id, label := plantUMLElement(m[2])
+			b.node(id, label, plantUMLShapes[m[1]])
+			continue
+		}
+		if m := plantUMLNote.FindStringSubmatch(line); m != nil {
+			var text string
+			text, i = plantUMLNoteText(lines, i)
+			var over []string
+			for _, id := range strings.Split(m[1], ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					id, _ = plantUMLElement(id)
+					b.node(id, "", "")
+					over = append(over, id)
+				}
+			}
+			b.g.Notes = append(b.g.Notes, Note{Over: over, Text: cleanLabel(text), After: len(b.g.Edges)})
+			continue
+		}
+		if m := plantUMLMessage.FindStringSubmatch(line); m != nil && strings.ContainsAny(m[2], "<>") {
+			from, _ := plantUMLElement(m[1])
+			to, _ := plantUMLElement(m[3])
+			if strings.Contains(m[2], "<") && !strings.Contains(m[2], ">") {
+				from, to = to, from
+			}
+			b.node(from, "", "")
+			b.node(to, "", "")
+			b.g.Edges = append(b.g.Edges, Edge{From: from, To: to, Label: cleanLabel(m[4]), Directed: true, Dashed: strings.Contains(m[2], "--")})
+			continue
+		}
+		if hasAnyPrefix(line, plantUMLBlocks) {
+			continue
+		}
+		return nil, fmt.Errorf("unsupported sequence statement %q", line)
+	}
+	return b.g, nil
+}
+
+var plantUMLLink = regexp.MustCompile(`^(\[[^\]]+\]|\([^)]+\)|:[^:]+:|"[^"]+"|[\w.]+)\s*(<?[-.]+(?:\[[^\]]*\])?(?:up|down|left|right|u|d|l|r)?[-.]*>?)\s*(\[[^\]]+\]|\([^)]+\)|:[^:]+:|"[^"]+"|[\w.]+)\s*(?::\s*(.*))?$`)
+
+// parsePlantUMLComponents parses component, deployment and use case diagrams as flowcharts
+func parsePlantUMLComponents(lines []string) (*Graph, error) {
+	b := newGraphBuilder(KindFlowchart)
+	var groups []int
+
+	// Elements referenced by their bracketed label are identified by it
+	ref := func(s string) string {
+		id, label := plantUMLElement(s)
+		shape := ""
+		switch {
+		case strings.HasPrefix(s, "("):
+			shape = ShapeEllipse
+		case strings.HasPrefix(s, ":"):
+			shape = ShapeActor
+		}
+		if _, ok := b.index[id]; !ok {
+			b.node(id, label, shape)
+		}
+		return id
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		before := len(b.g.Nodes)
+		switch m := plantUMLDeclaration.FindStringSubmatch(line); {
+		case plantUMLNote.MatchString(line):
+			var text string
+			text, i = plantUMLNoteText(lines, i)
+			b.g.Notes = append(b.g.Notes, Note{Text: text})
+			continue
+		case line == "}":
+			if len(groups) == 0 {
+				return nil, fmt.Errorf("unbalanced \"}\"")
+			}
+			groups = groups[:len(groups)-1]
+			continue
+		case m != nil && m[3] == "{" && slices.Contains(plantUMLContainers, m[1]):
+			_, label := plantUMLElement(m[2])
+			b.g.Groups = append(b.g.Groups, Group{Label: label})
+			groups = append(groups, len(b.g.Groups)-1)
+			continue
+		case m != nil:
+			id, label := plantUMLElement(m[2])
+			b.node(id, label, plantUMLShapes[m[1]])
+		default:
+			l := plantUMLLink.FindStringSubmatch(line)
+			if l == nil {
+				if plantUMLComponent.MatchString(line) && !strings.ContainsAny(line, "-.") {
+					ref(line)
+					break
+				}
+				return nil, fmt.Errorf("unsupported statement %q", line)
+			}
+			from, to := ref(l[1]), ref(l[3])
+			arrow := l[2]
+			if strings.HasPrefix(arrow, "<") && !strings.HasSuffix(arrow, ">") {
+				from, to = to, from
+			}
+			b.g.Edges = append(b.g.Edges, Edge{
+				From:     from,
+				To:       to,
+				Label:    cleanLabel(l[4]),
+				Directed: strings.ContainsAny(arrow, "<>"),
+				Dashed:   strings.Contains(arrow, "."),
+			})
+		}
+		if len(groups) > 0 {
+			grp := &b.g.Groups[groups[len(groups)-1]]
+			for _, n := range b.g.Nodes[before:] {
+				grp.Nodes = append(grp.Nodes, n.ID)
+			}
+		}
+	}
+	return b.g, nil
+}
+
+var (
+	activityIf        = regexp.MustCompile(`^(if|elseif|while)\s*\((.*?)\)\s*(?:then|is)?\s*(?:\((.*)\))?$`)
+	activityElse      = regexp.MustCompile(`^(else|endwhile|endif)\s*(?:\((.*)\))?$`)
+	activityArrow     = regexp.MustCompile(`^-+>\s*(.*?);?$`)
+	activityLane      = regexp.MustCompile(`^\|[^|]*\|[^|]*\|?$`)
+	activityPartition = regexp.MustCompile(`^(?:partition|group)\s+(.+?)\s*\{?$`)
+)
+
+// activityBranch tracks an open if, while or fork block
+type activityBranch struct {
+	kind   string // "if", "while" or "fork"
+	node   string // the current decision node
+	ends   []activityEnd
+	origin []activityEnd // what a fork's branches start from
+	others bool          // an if has an else branch
+}
+
+// activityEnd is a dangling arrow waiting for the next node
+type activityEnd struct {
+	from  string
+	label string
+}
+
+// parsePlantUMLActivity parses activity diagrams into a flowchart: actions become
+// boxes, if/while conditions diamonds, and the control flow edges
+func parsePlantUMLActivity(lines []string) (*Graph, error) {
+	b := newGraphBuilder(KindFlowchart)
+	var pending []activityEnd
+	var stack []*activityBranch
+	var groups []int
+	nextLabel := ""
+
+	add := func(label, shape string) string {
+		id := fmt.Sprintf("n%d", len(b.g.Nodes)+1)
+		b.node(id, label, shape)
+		b.g.Nodes[len(b.g.Nodes)-1].Label = label // start and stop have no text
+		if len(groups) > 0 {
+			grp := &b.g.Groups[groups[len(groups)-1]]
+			grp.Nodes = append(grp.Nodes, id)
+		}
+		for _, p := range pending {
+			label := p.label
+			if label == "" {
+				label = nextLabel
+			}
+			b.g.Edges = append(b.g.Edges, Edge{From: p.from, To: id, Label: cleanLabel(label), Directed: true})
+		}
+		nextLabel = ""
+		pending = []activityEnd{{from: id}}
+		return id
+	}
+	top := func(kind string) (*activityBranch, error) {
+		if len(stack) == 0 || stack[len(stack)-1].kind != kind {
+			return nil, fmt.Errorf("unbalanced %s block", kind)
+		}
+		return stack[len(stack)-1], nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case line == "start":
+			add("", ShapeCircle)
+		case line == "stop" || line == "end":
+			add("", ShapeCircle)
+			pending = nil
+		case line == "detach" || line == "kill":
+			pending = nil
+		case strings.HasPrefix(line, ":"):
+			// Actions run until a line ending in ';'
+			text := line[1:]
+			for !strings.HasSuffix(text, ";") && i+1 < len(lines) {
+				i++
+				text += " " + lines[i]
+			}
+			add(strings.TrimSuffix(text, ";"), ShapeRounded)
+		case activityArrow.MatchString(line):
+			nextLabel = activityArrow.FindStringSubmatch(line)[1]
+		case activityIf.MatchString(line):
+			m := activityIf.FindStringSubmatch(line)
+			switch m[1] {
+			case "if", "while":
+				id := add(m[2], ShapeDiamond)
+				stack = append(stack, &activityBranch{kind: m[1], node: id})
+				pending = []activityEnd{{from: id, label: m[3]}}
+			case "elseif":
+				br, err := top("if")
+				if err != nil {
+					return nil, err
+				}
+				br.ends = append(br.ends, pending...)
+				pending = []activityEnd{{from: br.node}}
+				br.node = add(m[2], ShapeDiamond)
+				pending = []activityEnd{{from: br.node, label: m[3]}}
+			}
+		case activityElse.MatchString(line):
+			m := activityElse.FindStringSubmatch(line)
+			switch m[1] {
+			case "else":
+				br, err := top("if")
+				if err != nil {
+					return nil, err
+				}
+				br.ends = append(br.ends, pending...)
+				pending = []activityEnd{{from: br.node, label: m[2]}}
+				br.others = true
+			case "endif":
+				br, err := top("if")
+				if err != nil {
+					return nil, err
+				}
+				pending = append(br.ends, pending...)
+				if !br.others {
+					pending = append(pending, activityEnd{from: br.node})
+				}
+				stack = stack[:len(stack)-1]
+			case "endwhile":
+				br, err := top("while")
+				if err != nil {
+					return nil, err
+				}
+				for _, p := range pending {
+					b.g.Edges = append(b.g.Edges, Edge{From: p.from, To: br.node, Label: cleanLabel(p.label), Directed: true})
+				}
+				pending = []activityEnd{{from: br.node, label: m[2]}}
+				stack = stack[:len(stack)-1]
+			}
+		case line == "fork" || line == "split":
+			stack = append(stack, &activityBranch{kind: "fork", origin: pending})
+		case line == "fork again" || line == "split again":
+			br, err := top("fork")
+			if err != nil {
+				return nil, err
+			}
+			br.ends = append(br.ends, pending...)
+			pending = br.origin
+		case line == "end fork" || line == "end merge" || line == "end split":
+			br, err := top("fork")
+			if err != nil {
+				return nil, err
+			}
+			pending = append(br.ends, pending...)
+			stack = stack[:len(stack)-1]
+		case activityPartition.MatchString(line):
+			b.g.Groups = append(b.g.Groups, Group{Label: cleanLabel(activityPartition.FindStringSubmatch(line)[1])})
+			groups = append(groups, len(b.g.Groups)-1)
+		case line == "}" || line == "end group":
+			if len(groups) == 0 {
+				return nil, fmt.Errorf("unbalanced \"}\"")
+			}
+			groups = groups[:len(groups)-1]
+		case plantUMLNote.MatchString(line):
+			var text string
+			text, i = plantUMLNoteText(lines, i)
+			b.g.Notes = append(b.g.Notes, Note{Text: text})
+		case activityLane.MatchString(line):
+			// Swimlanes only change where actions are drawn
+		default:
+			return nil, fmt.Errorf("unsupported activity statement %q", line)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s block is missing its end", stack[len(stack)-1].kind)
+	}
+	return b.g, nil
+}
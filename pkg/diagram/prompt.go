@@ -0,0 +1,141 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shapeNames describes each shape for the model
+var shapeNames = map[string]string{
+	ShapeBox:      "rectangle",
+	ShapeRounded:  "rounded rectangle",
+	ShapeStadium:  "pill shape",
+	ShapeEllipse:  "ellipse",
+	ShapeCircle:   "circle",
+	ShapeDiamond:  "diamond (decision)",
+	ShapeHexagon:  "hexagon",
+	ShapeDatabase: "cylinder (database)",
+	ShapeActor:    "stick figure",
+}
+
+// directionNames describes flowchart directions
+var directionNames = map[string]string{
+	"TB": "top to bottom",
+	"BT": "bottom to top",
+	"LR": "left to right",
+	"RL": "right to left",
+}
+
+// Describe enumerates every element of the diagram for an image prompt, quoting each
+// label verbatim, so the model draws what the source says instead of inventing it
+func (g *Graph) Describe() string {
+	var b strings.Builder
+	if g.Title != "" {
+		fmt.Fprintf(&b, "Title at the top: %q.\n", g.Title)
+	}
+
+	refs := g.nodeRefs()
+	if g.Kind == KindSequence {
+		fmt.Fprintf(&b, "Draw exactly these %d participants, left to right, each in a box at the top with a vertical lifeline below it:\n", len(g.Nodes))
+		for i, n := range g.Nodes {
+			fmt.Fprintf(&b, "%d. %q - %s\n", i+1, n.Label, shapeNames[n.Shape])
+		}
+		if len(g.Edges) > 0 {
+			fmt.Fprintf(&b, "Draw exactly these %d messages as horizontal arrows between lifelines, top to bottom in this order:\n", len(g.Edges))
+			for i, e := range g.Edges {
+				fmt.Fprintf(&b, "%d. %s → %s", i+1, refs[e.From], refs[e.To])
+				if e.Label != "" {
+					fmt.Fprintf(&b, ": %q", e.Label)
+				}
+				b.WriteString(edgeStyle(e, "dashed return arrow"))
+				b.WriteString("\n")
+			}
+		}
+	} else {
+		if dir, ok := directionNames[g.Direction]; ok {
+			fmt.Fprintf(&b, "Lay the flow out %s.\n", dir)
+		}
+		fmt.Fprintf(&b, "Draw exactly these %d nodes, each labeled with exactly the quoted text:\n", len(g.Nodes))
+		for i, n := range g.Nodes {
+			if n.Label == "" {
+				fmt.Fprintf(&b, "%d. small filled %s with no text\n", i+1, shapeNames[n.Shape])
+			} else {
+				fmt.Fprintf(&b, "%d. %q - %s\n", i+1, n.Label, shapeNames[n.Shape])
+			}
+		}
+		for _, grp := range g.Groups {
+			members := make([]string, len(grp.Nodes))
+			for i, id := range grp.Nodes {
+				members[i] = refs[id]
+			}
+			fmt.Fprintf(&b, "Enclose %s in a box labeled %q.\n", strings.Join(members, ", "), grp.Label)
+		}
+		if len(g.Edges) > 0 {
+			fmt.Fprintf(&b, "Draw exactly these %d connections:\n", len(g.Edges))
+			for i, e := range g.Edges {
+				arrow := "→"
+				if !e.Directed {
+					arrow = "—"
+				}
+				fmt.Fprintf(&b, "%d. %s %s %s", i+1, refs[e.From], arrow, refs[e.To])
+				if e.Label != "" {
+					fmt.Fprintf(&b, ", labeled %q", e.Label)
+				}
+				b.WriteString(edgeStyle(e, "dashed"))
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	for _, n := range g.Notes {
+		if len(n.Over) == 0 {
+			fmt.Fprintf(&b, "Add a note: %q.\n", n.Text)
+			continue
+		}
+		over := make([]string, len(n.Over))
+		for i, id := range n.Over {
+			over[i] = refs[id]
+		}
+		fmt.Fprintf(&b, "Add a note next to %s: %q.\n", strings.Join(over, " and "), n.Text)
+	}
+
+	b.WriteString("Use every quoted label exactly as written - same spelling, capitalization and punctuation. ")
+	b.WriteString("Do not add, merge, rename or leave out any node, connection or label, and add no other text.")
+	return b.String()
+}
+
+// edgeStyle describes an edge's line for the prompt
+func edgeStyle(e Edge, dashed string) string {
+	var notes []string
+	if e.Dashed {
+		notes = append(notes, dashed)
+	}
+	if !e.Directed {
+		notes = append(notes, "no arrowhead")
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, ", ") + ")"
+}
+
+// nodeRefs returns how the prompt refers to each node: by its quoted label when that
+// is unique, otherwise by its number in the node list
+func (g *Graph) nodeRefs() map[string]string {
+	counts := map[string]int{}
+	for _, n := range g.Nodes {
+		counts[n.Label]++
+	}
+	refs := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		switch {
+		case n.Label == "":
+			refs[n.ID] = fmt.Sprintf("node %d", i+1)
+		case counts[n.Label] > 1:
+			refs[n.ID] = fmt.Sprintf("node %d (%q)", i+1, n.Label)
+		default:
+			refs[n.ID] = fmt.Sprintf("%q", n.Label)
+		}
+	}
+	return refs
+}
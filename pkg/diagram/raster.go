@@ -0,0 +1,253 @@
+package diagram
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Image rasterizes the scene at the given scale, e.g. 2 for a crisp PNG
+func (s *Scene) Image(scale float64) (*image.NRGBA, error) {
+	w := int(math.Ceil(s.Width * scale))
+	h := int(math.Ceil(s.Height * scale))
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Rect, image.White, image.Point{}, draw.Src)
+
+	faces := map[[2]float64]font.Face{}
+	for _, it := range s.items {
+		if it.text != "" {
+			key := [2]float64{it.size * scale, 0}
+			ttf := goregular.TTF
+			if it.bold {
+				key[1], ttf = 1, gobold.TTF
+			}
+			face, ok := faces[key]
+			if !ok {
+				var err error
+				if face, err = loadFace(ttf, key[0]); err != nil {
+					return nil, err
+				}
+				faces[key] = face
+			}
+			x := it.x * scale
+			if it.center {
+				x -= float64(font.MeasureString(face, it.text)) / 64 / 2
+			}
+			d := font.Drawer{
+				Dst:  dst,
+				Src:  image.NewUniform(it.fill),
+				Face: face,
+				Dot:  fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(it.y * scale * 64)},
+			}
+			d.DrawString(it.text)
+			continue
+		}
+
+		path := scalePath(it.path, scale)
+		if it.fill.A > 0 {
+			fillPath(dst, path, it.fill)
+		}
+		if it.stroke.A > 0 {
+			for _, line := range flatten(path) {
+				if it.dashed {
+					for _, dash := range dashes(line, it.width*scale*4, it.width*scale*3) {
+						strokeLine(dst, dash, it.width*scale, it.stroke)
+					}
+				} else {
+					strokeLine(dst, line, it.width*scale, it.stroke)
+				}
+			}
+		}
+	}
+	return dst, nil
+}
+
+// loadFace loads a bundled TrueType font at the given pixel size
+func loadFace(ttf []byte, size float64) (font.Face, error) {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+	return face, nil
+}
+
+// scalePath multiplies every coordinate of a path by scale
+func scalePath(ops []pathOp, scale float64) []pathOp {
+	out := make([]pathOp, len(ops))
+	for i, op := range ops {
+		pts := make([]point, len(op.pts))
+		for j, p := range op.pts {
+			pts[j] = point{p.x * scale, p.y * scale}
+		}
+		out[i] = pathOp{op.op, pts}
+	}
+	return out
+}
+
+// bounds returns the integer rectangle covering points, grown by pad
+func bounds(pts []point, pad float64) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range pts {
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+		maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+	}
+	return image.Rect(int(math.Floor(minX-pad)), int(math.Floor(minY-pad)), int(math.Ceil(maxX+pad)), int(math.Ceil(maxY+pad)))
+}
+
+// fillPath fills a closed path. The rasterizer only covers the path's bounding box.
+func fillPath(dst *image.NRGBA, ops []pathOp, c color.NRGBA) {
+	var all []point
+	for _, op := range ops {
+		all = append(all, op.pts...)
+	}
+	r := bounds(all, 1).Intersect(dst.Rect)
+	if r.Empty() {
+		return
+	}
+
+	z := vector.NewRasterizer(r.Dx(), r.Dy())
+	ox, oy := float32(r.Min.X), float32(r.Min.Y)
+	for _, op := range ops {
+		switch op.op {
+		case 'M':
+			z.MoveTo(float32(op.pts[0].x)-ox, float32(op.pts[0].y)-oy)
+		case 'L':
+			z.LineTo(float32(op.pts[0].x)-ox, float32(op.pts[0].y)-oy)
+		case 'C':
+			z.CubeTo(float32(op.pts[0].x)-ox, float32(op.pts[0].y)-oy,
+				float32(op.pts[1].x)-ox, float32(op.pts[1].y)-oy,
+				float32(op.pts[2].x)-ox, float32(op.pts[2].y)-oy)
+		case 'Z':
+			z.ClosePath()
+		}
+	}
+	z.Draw(dst, r, image.NewUniform(c), image.Point{})
+}
+
+// flatten turns a path into polylines, approximating curves with line segments.
+// Closed subpaths end where they started.
+func flatten(ops []pathOp) [][]point {
+	var lines [][]point
+	var cur []point
+	for _, op := range ops {
+		switch op.op {
+		case 'M':
+			if len(cur) > 1 {
+				lines = append(lines, cur)
+			}
+			cur = []point{op.pts[0]}
+		case 'L':
+			cur = append(cur, op.pts[0])
+		case 'C':
+			p0 := cur[len(cur)-1]
+			for i := 1; i <= 12; i++ {
+				t := float64(i) / 12
+				u := 1 - t
+				cur = append(cur, point{
+					u*u*u*p0.x + 3*u*u*t*op.pts[0].x + 3*u*t*t*op.pts[1].x + t*t*t*op.pts[2].x,
+					u*u*u*p0.y + 3*u*u*t*op.pts[0].y + 3*u*t*t*op.pts[1].y + t*t*t*op.pts[2].y,
+				})
+			}
+		case 'Z':
+			if len(cur) > 0 {
+				cur = append(cur, cur[0])
+			}
+		}
+	}
+	if len(cur) > 1 {
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+// dashes splits a polyline into dashes of length on separated by gaps of length off
+func dashes(line []point, on, off float64) [][]point {
+	var out [][]point
+	var cur []point
+	drawing, left := true, on
+	cur = append(cur, line[0])
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		seg := math.Hypot(b.x-a.x, b.y-a.y)
+		for seg > 0 {
+			step := math.Min(seg, left)
+			t := step / math.Hypot(b.x-a.x, b.y-a.y)
+			p := point{a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t}
+			if drawing {
+				cur = append(cur, p)
+			}
+			a, seg, left = p, seg-step, left-step
+			if left <= 0 {
+				if drawing {
+					out = append(out, cur)
+					cur = nil
+					left = off
+				} else {
+					cur = []point{a}
+					left = on
+				}
+				drawing = !drawing
+			}
+		}
+	}
+	if drawing && len(cur) > 1 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// strokeLine draws a polyline of the given width with round joins. Every segment and
+// join is added with the same winding so overlaps don't cancel out.
+func strokeLine(dst *image.NRGBA, line []point, width float64, c color.NRGBA) {
+	if len(line) < 2 {
+		return
+	}
+	half := width / 2
+	r := bounds(line, half+1).Intersect(dst.Rect)
+	if r.Empty() {
+		return
+	}
+
+	z := vector.NewRasterizer(r.Dx(), r.Dy())
+	ox, oy := float32(r.Min.X), float32(r.Min.Y)
+	poly := func(pts ...point) {
+		z.MoveTo(float32(pts[0].x)-ox, float32(pts[0].y)-oy)
+		for _, p := range pts[1:] {
+			z.LineTo(float32(p.x)-ox, float32(p.y)-oy)
+		}
+		z.ClosePath()
+	}
+
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		length := math.Hypot(b.x-a.x, b.y-a.y)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -(b.y-a.y)/length*half, (b.x-a.x)/length*half
+		poly(point{a.x + nx, a.y + ny}, point{b.x + nx, b.y + ny}, point{b.x - nx, b.y - ny}, point{a.x - nx, a.y - ny})
+	}
+	for _, p := range line[1 : len(line)-1] {
+		var join []point
+		for k := 0; k < 12; k++ {
+			angle := -float64(k) * math.Pi / 6
+			join = append(join, point{p.x + half*math.Cos(angle), p.y + half*math.Sin(angle)})
+		}
+		poly(join...)
+	}
+	z.Draw(dst, r, image.NewUniform(c), image.Point{})
+}
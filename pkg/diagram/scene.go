@@ -0,0 +1,186 @@
+package diagram
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Scene is a laid-out diagram: paths and text in drawing order, in pixels at 1x
+type Scene struct {
+	Width  float64
+	Height float64
+	items  []item
+}
+
+// item is one drawing instruction: a path or a line of text
+type item struct {
+	path   []pathOp
+	fill   color.NRGBA // zero alpha means no fill
+	stroke color.NRGBA // zero alpha means no stroke
+	width  float64
+	dashed bool
+
+	text   string
+	x, y   float64 // text baseline; x is the center for centered text
+	size   float64
+	bold   bool
+	center bool
+}
+
+// pathOp is a path command: 'M' move, 'L' line, 'C' cubic curve (three points), 'Z' close
+type pathOp struct {
+	op  byte
+	pts []point
+}
+
+type point struct{ x, y float64 }
+
+// Diagram colors
+var (
+	colorInk       = color.NRGBA{R: 0x22, G: 0x2b, B: 0x36, A: 0xff}
+	colorNodeFill  = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	colorGroupFill = color.NRGBA{R: 0xf2, G: 0xf5, B: 0xf9, A: 0xff}
+	colorGroupLine = color.NRGBA{R: 0x9a, G: 0xa5, B: 0xb1, A: 0xff}
+	colorNoteFill  = color.NRGBA{R: 0xff, G: 0xf6, B: 0xbf, A: 0xff}
+	colorLabelFill = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xe6}
+	colorMuted     = color.NRGBA{R: 0x5b, G: 0x67, B: 0x74, A: 0xff}
+)
+
+// pathBuilder assembles path commands
+type pathBuilder []pathOp
+
+func (p *pathBuilder) move(x, y float64) { *p = append(*p, pathOp{'M', []point{{x, y}}}) }
+func (p *pathBuilder) line(x, y float64) { *p = append(*p, pathOp{'L', []point{{x, y}}}) }
+func (p *pathBuilder) close()            { *p = append(*p, pathOp{op: 'Z'}) }
+func (p *pathBuilder) cubic(x1, y1, x2, y2, x, y float64) {
+	*p = append(*p, pathOp{'C', []point{{x1, y1}, {x2, y2}, {x, y}}})
+}
+
+// kappa places cubic control points to approximate a quarter circle
+const kappa = 0.5522847498
+
+// ellipse adds an ellipse centered at (cx, cy)
+func (p *pathBuilder) ellipse(cx, cy, rx, ry float64) {
+	kx, ky := rx*kappa, ry*kappa
+	p.move(cx+rx, cy)
+	p.cubic(cx+rx, cy+ky, cx+kx, cy+ry, cx, cy+ry)
+	p.cubic(cx-kx, cy+ry, cx-rx, cy+ky, cx-rx, cy)
+	p.cubic(cx-rx, cy-ky, cx-kx, cy-ry, cx, cy-ry)
+	p.cubic(cx+kx, cy-ry, cx+rx, cy-ky, cx+rx, cy)
+	p.close()
+}
+
+// roundRect adds a rectangle with corner radius r
+func (p *pathBuilder) roundRect(x, y, w, h, r float64) {
+	r = math.Min(r, math.Min(w, h)/2)
+	if r <= 0 {
+		p.polygon(point{x, y}, point{x + w, y}, point{x + w, y + h}, point{x, y + h})
+		return
+	}
+	k := r * (1 - kappa)
+	p.move(x+r, y)
+	p.line(x+w-r, y)
+	p.cubic(x+w-k, y, x+w, y+k, x+w, y+r)
+	p.line(x+w, y+h-r)
+	p.cubic(x+w, y+h-k, x+w-k, y+h, x+w-r, y+h)
+	p.line(x+r, y+h)
+	p.cubic(x+k, y+h, x, y+h-k, x, y+h-r)
+	p.line(x, y+r)
+	p.cubic(x, y+k, x+k, y, x+r, y)
+	p.close()
+}
+
+// polygon adds a closed polygon
+func (p *pathBuilder) polygon(pts ...point) {
+	p.move(pts[0].x, pts[0].y)
+	for _, pt := range pts[1:] {
+		p.line(pt.x, pt.y)
+	}
+	p.close()
+}
+
+// polyline adds an open polyline
+func (p *pathBuilder) polyline(pts []point) {
+	p.move(pts[0].x, pts[0].y)
+	for _, pt := range pts[1:] {
+		p.line(pt.x, pt.y)
+	}
+}
+
+// svgFonts is the font stack for SVG text; layout measures with the bundled Go font,
+// which these fallbacks closely match in width
+const svgFonts = "Go, 'Helvetica Neue', Helvetica, Arial, sans-serif"
+
+// SVG encodes the scene as a standalone SVG document
+func (s *Scene) SVG() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+		num(s.Width), num(s.Height), num(s.Width), num(s.Height))
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+
+	for _, it := range s.items {
+		if it.text != "" {
+			anchor := ""
+			if it.center {
+				anchor = ` text-anchor="middle"`
+			}
+			weight := ""
+			if it.bold {
+				weight = ` font-weight="bold"`
+			}
+			fmt.Fprintf(&b, `<text x="%s" y="%s"%s font-family="%s" font-size="%s"%s fill="%s">%s</text>`+"\n",
+				num(it.x), num(it.y), anchor, svgFonts, num(it.size), weight, svgColor(it.fill), html.EscapeString(it.text))
+			continue
+		}
+
+		fill := "none"
+		if it.fill.A > 0 {
+			fill = svgColor(it.fill)
+		}
+		fmt.Fprintf(&b, `<path d="%s" fill="%s"`, svgPath(it.path), fill)
+		if it.fill.A > 0 && it.fill.A < 0xff {
+			fmt.Fprintf(&b, ` fill-opacity="%s"`, num(float64(it.fill.A)/255))
+		}
+		if it.stroke.A > 0 {
+			fmt.Fprintf(&b, ` stroke="%s" stroke-width="%s" stroke-linejoin="round"`, svgColor(it.stroke), num(it.width))
+			if it.dashed {
+				fmt.Fprintf(&b, ` stroke-dasharray="%s %s"`, num(it.width*4), num(it.width*3))
+			}
+		}
+		b.WriteString("/>\n")
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// svgPath formats path commands as SVG path data
+func svgPath(ops []pathOp) string {
+	var parts []string
+	for _, op := range ops {
+		s := string(op.op)
+		for _, p := range op.pts {
+			s += num(p.x) + "," + num(p.y) + " "
+		}
+		parts = append(parts, strings.TrimSpace(s))
+	}
+	return strings.Join(parts, " ")
+}
+
+// svgColor formats an opaque color as #rrggbb
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// num formats a coordinate compactly
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package diagram
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Type is a kind of diagram with its own prompt template, layout guidance and default
+// aspect ratio
+type Type struct {
+	Name        string // canonical name, also the output filename prefix
+	Aliases     []string
+	Title       string // how prompts refer to it, e.g. "C4 container"
+	Summary     string // one line for --list-types
+	Template    string // what to draw; %s is the user's description
+	Layout      string // layout and notation conventions
+	AspectRatio string
+}
+
+// DefaultType is the generic type used when none is given
+const DefaultType = "diagram"
+
+// Types lists the supported diagram types
+var Types = []Type{
+	{
+		Name:        DefaultType,
+		Title:       "technical",
+		Summary:     "General technical diagram; the model picks the notation",
+		Template:    "Create a clear, professional technical diagram: %s.",
+		Layout:      "The diagram should be well-organized, easy to read, with clear labels, appropriate shapes/symbols, connecting lines/arrows, and good visual hierarchy.",
+		AspectRatio: "4:3",
+	},
+	{
+		Name:        KindFlowchart,
+		Aliases:     []string{"flow", "process"},
+		Title:       "flowchart",
+		Summary:     "Process flow with steps, decisions and branches",
+		Template:    "Create a clear, professional flowchart of this process: %s.",
+		Layout:      "Keep the flow in one direction, top to bottom unless stated otherwise. Use rounded terminals for start and end, rectangles for steps, diamonds for decisions with each outgoing branch labeled (e.g. yes/no), and arrows that never cross where avoidable.",
+		AspectRatio: "3:4",
+	},
+	{
+		Name:        "architecture",
+		Aliases:     []string{"arch", "system"},
+		Title:       "software architecture",
+		Summary:     "Services, data stores and the connections between them",
+		Template:    "Create a clear, professional software architecture diagram: %s.",
+		Layout:      "Arrange components in tiers from clients on the left to data stores on the right. Group related services in labeled boundary boxes, draw databases as cylinders and queues as pipes, and label every connection with its protocol or purpose.",
+		AspectRatio: "16:9",
+	},
+	{
+		Name:        "c4-context",
+		Aliases:     []string{"c4", "context"},
+		Title:       "C4 system context",
+		Summary:     "C4 level 1: the system, its users and external systems",
+		Template:    "Create a C4 model system context diagram (level 1): %s.",
+		Layout:      "Put the system in scope as one large box in the center, people as person shapes around it and external systems as grey boxes. Every element shows its name, a [Person] or [Software System] type line and a one-sentence description. Label every relationship arrow with what it does. Add a small key.",
+		AspectRatio: "4:3",
+	},
+	{
+		Name:        "c4-container",
+		Aliases:     []string{"container"},
+		Title:       "C4 container",
+		Summary:     "C4 level 2: apps, services and data stores inside the system",
+		Template:    "Create a C4 model container diagram (level 2): %s.",
+		Layout:      "Draw a dashed system boundary containing the containers (applications, services, databases as cylinders). Every container shows its name, a [Container: technology] line and a short description. Put people and external systems outside the boundary. Label every arrow with its purpose and protocol. Add a small key.",
+		AspectRatio: "16:9",
+	},
+	{
+		Name:        KindSequence,
+		Aliases:     []string{"seq"},
+		Title:       "UML sequence",
+		Summary:     "Messages between participants over time",
+		Template:    "Create a UML sequence diagram: %s.",
+		Layout:      "Put participants in boxes along the top with dashed vertical lifelines. Draw messages as horizontal arrows in time order from top to bottom, solid for calls and dashed for returns, each labeled. Show activation bars where a participant is busy.",
+		AspectRatio: "4:3",
+	},
+	{
+		Name:        "erd",
+		Aliases:     []string{"entity-relationship", "er"},
+		Title:       "entity-relationship",
+		Summary:     "Database tables, columns and relationships",
+		Template:    "Create an entity-relationship diagram: %s.",
+		Layout:      "Draw each entity as a table with its name in a header row and one column per row, marking primary keys (PK) and foreign keys (FK). Connect related entities with crow's foot notation showing cardinality at both ends. Keep relationship lines orthogonal.",
+		AspectRatio: "16:9",
+	},
+	{
+		Name:        "network",
+		Aliases:     []string{"network-topology", "topology"},
+		Title:       "network topology",
+		Summary:     "Network devices, segments and links",
+		Template:    "Create a network topology diagram: %s.",
+		Layout:      "Use standard network icons for routers, switches, firewalls, servers and clients. Group devices into labeled zones or subnets (e.g. DMZ, LAN) with CIDR ranges where given, put the internet or WAN at the top, and label links with speed or type.",
+		AspectRatio: "16:9",
+	},
+	{
+		Name:        "state",
+		Aliases:     []string{"state-machine", "statechart"},
+		Title:       "state machine",
+		Summary:     "States and the events that move between them",
+		Template:    "Create a UML state machine diagram: %s.",
+		Layout:      "Draw states as rounded rectangles, a filled black circle for the initial state and a ringed circle for final states. Label every transition arrow with its event and any [guard] or /action.",
+		AspectRatio: "4:3",
+	},
+	{
+		Name:        "timeline",
+		Aliases:     []string{"roadmap"},
+		Title:       "timeline",
+		Summary:     "Events or milestones along a time axis",
+		Template:    "Create a timeline diagram: %s.",
+		Layout:      "Draw one horizontal time axis with evenly spaced, clearly labeled dates or periods. Alternate event labels above and below the axis with short connector ticks so none overlap, and mark milestones with distinct markers.",
+		AspectRatio: "21:9",
+	},
+	{
+		Name:        "mindmap",
+		Aliases:     []string{"mind-map"},
+		Title:       "mind map",
+		Summary:     "A central idea branching into topics and subtopics",
+		Template:    "Create a mind map: %s.",
+		Layout:      "Put the central idea in the middle and radiate main branches outward in all directions, each branch in its own color with its subtopics along curved lines. Keep labels short and horizontal.",
+		AspectRatio: "16:9",
+	},
+	{
+		Name:        "orgchart",
+		Aliases:     []string{"org-chart", "org"},
+		Title:       "organizational chart",
+		Summary:     "Reporting lines in a team or company",
+		Template:    "Create an organizational chart: %s.",
+		Layout:      "Draw a top-down hierarchy with the most senior role at the top. Each box shows a name and a title, peers line up on the same row, and reporting lines are orthogonal connectors.",
+		AspectRatio: "16:9",
+	},
+}
+
+// LookupType finds a diagram type by name or alias, ignoring case
+func LookupType(name string) (Type, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, t := range Types {
+		if t.Name == name || slices.Contains(t.Aliases, name) {
+			return t, nil
+		}
+	}
+	names := make([]string, len(Types))
+	for i, t := range Types {
+		names[i] = t.Name
+	}
+	return Type{}, fmt.Errorf("unknown diagram type %q (use %s, or see --list-types)", name, strings.Join(names, ", "))
+}
+
+// Prompt builds the image prompt for a description
+func (t Type) Prompt(description string) string {
+	return fmt.Sprintf(t.Template, description) + " " + t.Layout + " Use a clean, technical style."
+}
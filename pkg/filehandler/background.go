@@ -0,0 +1,256 @@
+package filehandler
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BackgroundOptions controls local background removal
+type BackgroundOptions struct {
+	Tolerance float64     // color distance treated as background, in percent (0-100)
+	Feather   int         // width in pixels of the soft edge inside the subject
+	Color     color.Color // background color; nil detects it from the image border
+	ChromaKey bool        // remove every matching pixel, not just those connected to the border
+}
+
+// DefaultBackgroundOptions are tuned for the flat backgrounds image models produce
+var DefaultBackgroundOptions = BackgroundOptions{Tolerance: 12, Feather: 1}
+
+// minBorderCoverage is the share of border pixels that must match the detected
+// background color for the background to count as uniform
+const minBorderCoverage = 0.6
+
+// RemoveBackground converts a uniform background to transparency. The background color
+// is sampled from the image border unless given. By default only background pixels
+// connected to the border are removed (flood fill), so matching colors inside the
+// subject survive. It returns the cut-out image and the background color used.
+func RemoveBackground(img image.Image, opts BackgroundOptions) (*image.NRGBA, color.NRGBA, error) {
+	b := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Rect, img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	var key color.NRGBA
+	if opts.Color != nil {
+		key = color.NRGBAModel.Convert(opts.Color).(color.NRGBA)
+	} else {
+		var coverage float64
+		key, coverage = borderColor(src)
+		if coverage < minBorderCoverage {
+			return nil, key, fmt.Errorf("no uniform background detected (only %.0f%% of the border matches)", coverage*100)
+		}
+	}
+
+	tolerance := opts.Tolerance / 100 * maxColorDistance
+	matches := func(i int) bool {
+		return colorDistance(src.Pix[4*i:4*i+4], key) <= tolerance
+	}
+
+	// Mark background pixels
+	background := make([]bool, w*h)
+	if opts.ChromaKey {
+		for i := range background {
+			background[i] = matches(i)
+		}
+	} else {
+		queue := make([]int, 0, 2*(w+h))
+		push := func(x, y int) {
+			i := y*w + x
+			if !background[i] && matches(i) {
+				background[i] = true
+				queue = append(queue, i)
+			}
+		}
+		for x := 0; x < w; x++ {
+			push(x, 0)
+			push(x, h-1)
+		}
+		for y := 0; y < h; y++ {
+			push(0, y)
+			push(w-1, y)
+		}
+		for len(queue) > 0 {
+			i := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			x, y := i%w, i/w
+			if x > 0 {
+				push(x-1, y)
+			}
+			if x < w-1 {
+				push(x+1, y)
+			}
+			if y > 0 {
+				push(x, y-1)
+			}
+			if y < h-1 {
+				push(x, y+1)
+			}
+		}
+	}
+
+	// Feather the subject's edge and remove the background color bleeding into it
+	dist := distanceToBackground(background, w, h)
+	for i := range background {
+		p := src.Pix[4*i : 4*i+4]
+		if background[i] {
+			p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+			continue
+		}
+		if opts.Feather <= 0 || dist[i] > float64(opts.Feather) {
+			continue
+		}
+		alpha := dist[i] / float64(opts.Feather+1)
+		for c := 0; c < 3; c++ {
+			v := (float64(p[c]) - (1-alpha)*float64(keyChannel(key, c))) / alpha
+			p[c] = uint8(math.Max(0, math.Min(255, v)))
+		}
+		p[3] = uint8(float64(p[3]) * alpha)
+	}
+
+	return src, key, nil
+}
+
+// RemoveBackgroundData is RemoveBackground for encoded image data, returning a PNG
+func RemoveBackgroundData(data []byte, opts BackgroundOptions) ([]byte, color.NRGBA, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, color.NRGBA{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	cutout, key, err := RemoveBackground(img, opts)
+	if err != nil {
+		return nil, key, err
+	}
+
+	encoded, err := encodePNG(cutout, png.DefaultCompression)
+	return encoded, key, err
+}
+
+// ParseHexColor parses a #rrggbb or #rgb color
+func ParseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q (use #rrggbb)", s)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// maxColorDistance is the distance between black and white
+var maxColorDistance = math.Sqrt(3 * 255 * 255)
+
+// colorDistance returns the Euclidean RGB distance between an NRGBA pixel and c.
+// Transparent pixels always count as background.
+func colorDistance(p []uint8, c color.NRGBA) float64 {
+	if p[3] == 0 {
+		return 0
+	}
+	dr := float64(p[0]) - float64(c.R)
+	dg := float64(p[1]) - float64(c.G)
+	db := float64(p[2]) - float64(c.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+func keyChannel(c color.NRGBA, i int) uint8 {
+	return [3]uint8{c.R, c.G, c.B}[i]
+}
+
+// borderColor returns the dominant color along the image border and the share of
+// border pixels close to it
+func borderColor(img *image.NRGBA) (color.NRGBA, float64) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	var border [][]uint8
+	for x := 0; x < w; x++ {
+		border = append(border, img.Pix[img.PixOffset(x, 0):][:4], img.Pix[img.PixOffset(x, h-1):][:4])
+	}
+	for y := 1; y < h-1; y++ {
+		border = append(border, img.Pix[img.PixOffset(0, y):][:4], img.Pix[img.PixOffset(w-1, y):][:4])
+	}
+
+	// Find the most common coarse color bucket, then average its members
+	buckets := map[uint32]int{}
+	bucketOf := func(p []uint8) uint32 {
+		return uint32(p[0]>>4)<<8 | uint32(p[1]>>4)<<4 | uint32(p[2]>>4)
+	}
+	best, bestCount := uint32(0), 0
+	for _, p := range border {
+		k := bucketOf(p)
+		buckets[k]++
+		if buckets[k] > bestCount {
+			best, bestCount = k, buckets[k]
+		}
+	}
+
+	var r, g, bl, n int
+	for _, p := range border {
+		if bucketOf(p) == best {
+			r += int(p[0])
+			g += int(p[1])
+			bl += int(p[2])
+			n++
+		}
+	}
+	key := color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 255}
+
+	// Coverage counts everything near the key, not just the exact bucket
+	near := 0
+	for _, p := range border {
+		if colorDistance(p, key) <= 0.1*maxColorDistance {
+			near++
+		}
+	}
+	return key, float64(near) / float64(len(border))
+}
+
+// distanceToBackground returns each pixel's approximate (chamfer) distance to the
+// nearest background pixel
+func distanceToBackground(background []bool, w, h int) []float64 {
+	const inf = math.MaxFloat64 / 2
+	d := make([]float64, w*h)
+	for i, bg := range background {
+		if !bg {
+			d[i] = inf
+		}
+	}
+
+	relax := func(i, x, y int, dx, dy int, cost float64) {
+		nx, ny := x+dx, y+dy
+		if nx < 0 || ny < 0 || nx >= w || ny >= h {
+			return
+		}
+		if v := d[ny*w+nx] + cost; v < d[i] {
+			d[i] = v
+		}
+	}
+
+	// Forward and backward passes
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			relax(i, x, y, -1, 0, 1)
+			relax(i, x, y, 0, -1, 1)
+			relax(i, x, y, -1, -1, math.Sqrt2)
+			relax(i, x, y, 1, -1, math.Sqrt2)
+		}
+	}
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			i := y*w + x
+			relax(i, x, y, 1, 0, 1)
+			relax(i, x, y, 0, 1, 1)
+			relax(i, x, y, 1, 1, math.Sqrt2)
+			relax(i, x, y, -1, 1, math.Sqrt2)
+		}
+	}
+	return d
+}
@@ -0,0 +1,59 @@
+package filehandler
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ringImage draws a dark ring with a white hole on a white background
+func ringImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 60; x++ {
+			dx, dy := x-30, y-30
+			d := dx*dx + dy*dy
+			c := color.NRGBA{R: 250, G: 250, B: 250, A: 255}
+			if d < 20*20 && d >= 8*8 {
+				c = color.NRGBA{R: 20, G: 40, B: 160, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestRemoveBackground(t *testing.T) {
+	cutout, key, err := RemoveBackground(ringImage(), BackgroundOptions{Tolerance: 10, Feather: 1})
+	if err != nil {
+		t.Fatalf("RemoveBackground failed: %v", err)
+	}
+	if key.R != 250 || key.B != 250 {
+		t.Errorf("detected background %v, want near white", key)
+	}
+
+	if a := cutout.NRGBAAt(0, 0).A; a != 0 {
+		t.Errorf("corner alpha = %d, want 0", a)
+	}
+	if a := cutout.NRGBAAt(30, 15).A; a != 255 {
+		t.Errorf("ring alpha = %d, want 255", a)
+	}
+	// The hole is not connected to the border, so flood fill keeps it
+	if a := cutout.NRGBAAt(30, 30).A; a != 255 {
+		t.Errorf("hole alpha = %d, want 255 without chroma key", a)
+	}
+
+	keyed, _, err := RemoveBackground(ringImage(), BackgroundOptions{Tolerance: 10, ChromaKey: true})
+	if err != nil {
+		t.Fatalf("RemoveBackground with chroma key failed: %v", err)
+	}
+	if a := keyed.NRGBAAt(30, 30).A; a != 0 {
+		t.Errorf("hole alpha = %d, want 0 with chroma key", a)
+	}
+}
+
+func TestRemoveBackgroundRejectsBusyBorder(t *testing.T) {
+	if _, _, err := RemoveBackground(testImage(64, 64), DefaultBackgroundOptions); err == nil {
+		t.Error("expected an error for an image without a uniform background")
+	}
+}
@@ -0,0 +1,261 @@
+package filehandler
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported output formats
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatPNG, FormatJPEG, FormatWebP}
+
+// DefaultJPEGQuality is used when no quality is given for JPEG output
+const DefaultJPEGQuality = 90
+
+// OutputOptions controls how images are encoded before they are written
+type OutputOptions struct {
+	Format   string // png, jpeg or webp; empty means infer from the path, else png
+	Quality  int    // JPEG quality 1-100; 0 means the default
+	MaxBytes int    // size budget in bytes; 0 means no limit
+	Resize   ResizeOptions
+}
+
+// Validate checks the options for unsupported values
+func (o OutputOptions) Validate() error {
+	if o.Format != "" && NormalizeFormat(o.Format) == "" {
+		return fmt.Errorf("unsupported image format: %s (use %s)", o.Format, strings.Join(Formats, ", "))
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100, got %d", o.Quality)
+	}
+	if o.MaxBytes < 0 {
+		return fmt.Errorf("max bytes must be positive, got %d", o.MaxBytes)
+	}
+	return o.Resize.Validate()
+}
+
+// Resolve returns a copy of the options with Format decided for the given output path.
+// An explicit format wins; otherwise the path's extension is used, then JPEG when a
+// size budget is set (it is the only lossy format), then PNG.
+func (o OutputOptions) Resolve(path string) OutputOptions {
+	switch {
+	case o.Format != "":
+		o.Format = NormalizeFormat(o.Format)
+	case formatFromExtension(path) != "":
+		o.Format = formatFromExtension(path)
+	case o.MaxBytes > 0:
+		o.Format = FormatJPEG
+	default:
+		o.Format = FormatPNG
+	}
+	return o
+}
+
+// NormalizeFormat maps a format name or alias to a supported format, or "" if unsupported
+func NormalizeFormat(format string) string {
+	switch strings.ToLower(format) {
+	case "png":
+		return FormatPNG
+	case "jpeg", "jpg":
+		return FormatJPEG
+	case "webp":
+		return FormatWebP
+	default:
+		return ""
+	}
+}
+
+// ExtensionFor returns the file extension for a format
+func ExtensionFor(format string) string {
+	switch NormalizeFormat(format) {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatWebP:
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
+
+// formatFromExtension returns the format implied by a path's extension, or ""
+func formatFromExtension(path string) string {
+	return NormalizeFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// WithExtension returns path with its extension replaced to match format.
+// Paths without a recognized image extension get the extension appended.
+func WithExtension(path, format string) string {
+	want := ExtensionFor(format)
+	ext := filepath.Ext(path)
+	if strings.EqualFold(ext, want) || (want == ".jpg" && strings.EqualFold(ext, ".jpeg")) {
+		return path
+	}
+	if ext != "" && (formatFromExtension(path) != "" || strings.EqualFold(ext, ".gif")) {
+		path = strings.TrimSuffix(path, ext)
+	}
+	return path + want
+}
+
+// DetectFormat sniffs the encoded image format of data ("png", "jpeg", "webp", "gif"), or "" if unknown
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return FormatJPEG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "gif"
+	default:
+		return ""
+	}
+}
+
+// Encode resizes and re-encodes image data according to opts, which must already be
+// resolved. Data already in the requested format is passed through untouched when no
+// resize, quality or size budget forces a re-encode.
+func Encode(data []byte, opts OutputOptions) ([]byte, error) {
+	format := NormalizeFormat(opts.Format)
+	if format == "" {
+		format = FormatPNG
+	}
+
+	requality := format == FormatJPEG && opts.Quality != 0
+	resize := opts.Resize.Width > 0
+	if DetectFormat(data) == format && !requality && !resize && (opts.MaxBytes == 0 || len(data) <= opts.MaxBytes) {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	opts.Format = format
+	return EncodeImage(Resize(img, opts.Resize), opts)
+}
+
+// EncodeImage encodes img according to opts, searching JPEG quality to fit MaxBytes
+func EncodeImage(img image.Image, opts OutputOptions) ([]byte, error) {
+	var encoded []byte
+	var err error
+
+	switch NormalizeFormat(opts.Format) {
+	case FormatJPEG:
+		quality := opts.Quality
+		if quality == 0 {
+			quality = DefaultJPEGQuality
+		}
+		flat := flattenOnWhite(img)
+		if opts.MaxBytes > 0 {
+			return encodeJPEGWithin(flat, quality, opts.MaxBytes)
+		}
+		encoded, err = encodeJPEG(flat, quality)
+	case FormatWebP:
+		var buf bytes.Buffer
+		err = EncodeWebPLossless(&buf, img)
+		encoded = buf.Bytes()
+	default:
+		encoded, err = encodePNG(img, png.DefaultCompression)
+		if err == nil && opts.MaxBytes > 0 && len(encoded) > opts.MaxBytes {
+			encoded, err = encodePNG(img, png.BestCompression)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxBytes > 0 && len(encoded) > opts.MaxBytes {
+		return nil, fmt.Errorf("%s is lossless and needs %d bytes, over the %d byte budget (use jpeg to trade quality for size)", NormalizeFormat(opts.Format), len(encoded), opts.MaxBytes)
+	}
+	return encoded, nil
+}
+
+// encodeJPEGWithin finds the highest quality up to maxQuality whose encoding fits in maxBytes
+func encodeJPEGWithin(img image.Image, maxQuality, maxBytes int) ([]byte, error) {
+	best, err := encodeJPEG(img, maxQuality)
+	if err != nil || len(best) <= maxBytes {
+		return best, err
+	}
+
+	// Binary search the highest fitting quality in [1, maxQuality)
+	best = nil
+	lo, hi := 1, maxQuality-1
+	for lo <= hi {
+		q := (lo + hi) / 2
+		encoded, err := encodeJPEG(img, q)
+		if err != nil {
+			return nil, err
+		}
+		if len(encoded) <= maxBytes {
+			best = encoded
+			lo = q + 1
+		} else {
+			hi = q - 1
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("image does not fit in %d bytes even at JPEG quality 1", maxBytes)
+	}
+	return best, nil
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func encodePNG(img image.Image, level png.CompressionLevel) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: level}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// flattenOnWhite composites img over white, since JPEG has no alpha channel
+func flattenOnWhite(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// WriteImageFile writes encoded image data to path, creating the parent directory if needed
+func WriteImageFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return nil
+}
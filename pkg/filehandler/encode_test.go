@@ -0,0 +1,94 @@
+package filehandler
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// testImage returns an image with gradients, noise and transparency
+func testImage(w, h int) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 3), G: uint8(y * 5), B: uint8(x ^ y), A: 255}
+			if x > w/2 {
+				c.R += uint8(rng.Intn(8))
+				c.A = uint8(128 + y)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeWebPLosslessRoundTrip(t *testing.T) {
+	for _, size := range []image.Point{{37, 21}, {80, 64}, {1, 1}} {
+		src := testImage(size.X, size.Y)
+
+		var buf bytes.Buffer
+		if err := EncodeWebPLossless(&buf, src); err != nil {
+			t.Fatalf("%v: encode failed: %v", size, err)
+		}
+		if DetectFormat(buf.Bytes()) != FormatWebP {
+			t.Fatalf("%v: encoded data not detected as webp", size)
+		}
+
+		decoded, err := webp.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%v: decode failed: %v", size, err)
+		}
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				want := src.NRGBAAt(x, y)
+				got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+				if got != want {
+					t.Fatalf("%v: pixel (%d,%d) = %v, want %v", size, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeMaxBytes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(200, 150)); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := OutputOptions{MaxBytes: 8000}.Resolve("out")
+	if opts.Format != FormatJPEG {
+		t.Fatalf("expected a size budget to imply jpeg, got %s", opts.Format)
+	}
+	encoded, err := Encode(buf.Bytes(), opts)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(encoded) > 8000 || DetectFormat(encoded) != FormatJPEG {
+		t.Errorf("got %d bytes of %q, want jpeg within 8000 bytes", len(encoded), DetectFormat(encoded))
+	}
+
+	if _, err := Encode(buf.Bytes(), OutputOptions{Format: FormatPNG, MaxBytes: 100}); err == nil {
+		t.Error("expected an error when a lossless format cannot meet the budget")
+	}
+}
+
+func TestWithExtension(t *testing.T) {
+	tests := []struct{ path, format, want string }{
+		{"out.png", FormatJPEG, "out.jpg"},
+		{"out.jpeg", FormatJPEG, "out.jpeg"},
+		{"dir/out", FormatWebP, "dir/out.webp"},
+		{"photo.v2.JPG", FormatPNG, "photo.v2.png"},
+	}
+	for _, tt := range tests {
+		if got := WithExtension(tt.path, tt.format); got != tt.want {
+			t.Errorf("WithExtension(%q, %q) = %q, want %q", tt.path, tt.format, got, tt.want)
+		}
+	}
+}
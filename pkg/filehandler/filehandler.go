@@ -25,23 +25,11 @@ func SaveImage(imageData, outputPath string) error {
 		return fmt.Errorf("failed to decode image data: %w", err)
 	}
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(outputPath)
-	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
-		}
-	}
-
-	// Write image to file
-	if err := os.WriteFile(outputPath, decoded, 0644); err != nil {
-		return fmt.Errorf("failed to write image: %w", err)
-	}
-
-	return nil
+	return WriteImageFile(outputPath, decoded)
 }
 
-// GenerateFilename creates a descriptive filename from a prompt
+// GenerateFilename creates a descriptive filename from a prompt.
+// The name has no extension; add the one for the output format with WithExtension.
 func GenerateFilename(prompt, prefix string, count int) string {
 	// Clean the prompt to make it filename-friendly
 	cleaned := cleanPrompt(prompt)
@@ -65,7 +53,7 @@ func GenerateFilename(prompt, prefix string, count int) string {
 		filename = fmt.Sprintf("%s_%d", filename, count)
 	}
 
-	return filename + ".png"
+	return filename
 }
 
 // cleanPrompt converts a prompt into a filename-safe string
@@ -119,16 +107,26 @@ func LoadImageAsBase64(path string) (string, error) {
 
 // ResizeAndSaveImage decodes base64 image data, resizes it to the target size, and saves to outputPath
 func ResizeAndSaveImage(imageData string, size int, outputPath string) error {
+	encoded, err := ResizeImage(imageData, size)
+	if err != nil {
+		return err
+	}
+
+	return WriteImageFile(outputPath, encoded)
+}
+
+// ResizeImage decodes base64 image data and returns it resized to a size x size PNG
+func ResizeImage(imageData string, size int) ([]byte, error) {
 	// Decode base64 image data
 	decoded, err := base64.StdEncoding.DecodeString(imageData)
 	if err != nil {
-		return fmt.Errorf("failed to decode image data: %w", err)
+		return nil, fmt.Errorf("failed to decode image data: %w", err)
 	}
 
 	// Decode image
 	src, _, err := image.Decode(bytes.NewReader(decoded))
 	if err != nil {
-		return fmt.Errorf("failed to decode image: %w", err)
+		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
 	// Create destination image with target size (square for icons)
@@ -137,27 +135,13 @@ func ResizeAndSaveImage(imageData string, size int, outputPath string) error {
 	// Use high-quality CatmullRom interpolation for resizing
 	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(outputPath)
-	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
-		}
-	}
-
-	// Create output file
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-
 	// Encode as PNG
-	if err := png.Encode(f, dst); err != nil {
-		_ = f.Close()
-		return fmt.Errorf("failed to encode PNG: %w", err)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
 	}
 
-	return f.Close()
+	return buf.Bytes(), nil
 }
 
 // GetImageDimensions returns the width and height of an image file
@@ -179,3 +163,50 @@ func GetImageDimensions(path string) (width, height int, err error) {
 
 	return config.Width, config.Height, nil
 }
+
+// GetImageDimensionsFromData returns the width and height of encoded image data
+func GetImageDimensionsFromData(data []byte) (width, height int, err error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image config: %w", err)
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// Thumbnail loads an image file and returns a PNG-encoded copy scaled to fit within maxDim pixels
+func Thumbnail(path string, maxDim int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Preserve aspect ratio, never upscale
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxDim || h > maxDim {
+		if w >= h {
+			h = max(1, h*maxDim/w)
+			w = maxDim
+		} else {
+			w = max(1, w*maxDim/h)
+			h = maxDim
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
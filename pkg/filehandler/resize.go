@@ -0,0 +1,176 @@
+package filehandler
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Supported fit modes for exact output sizes
+const (
+	FitCover   = "cover"   // scale to cover the target and crop the overflow
+	FitContain = "contain" // scale to fit inside the target and pad with transparency
+	FitFill    = "fill"    // stretch to the target, ignoring aspect ratio
+)
+
+// Supported crop gravities for FitCover
+const (
+	GravityCenter = "center" // crop evenly from both sides
+	GravitySmart  = "smart"  // keep the window with the most detail (highest entropy)
+)
+
+// ResizeOptions resizes images to exact pixel dimensions. A zero Width means no resize.
+type ResizeOptions struct {
+	Width   int
+	Height  int
+	Fit     string
+	Gravity string
+}
+
+// ParseSize parses a WxH size such as 1200x630
+func ParseSize(s string) (width, height int, err error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "x")
+	if len(parts) == 2 {
+		width, err = strconv.Atoi(parts[0])
+		if err == nil {
+			height, err = strconv.Atoi(parts[1])
+		}
+	}
+	if len(parts) != 2 || err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q (use WIDTHxHEIGHT, e.g. 1200x630)", s)
+	}
+	return width, height, nil
+}
+
+// Validate checks the resize options for unsupported values
+func (o ResizeOptions) Validate() error {
+	switch o.Fit {
+	case "", FitCover, FitContain, FitFill:
+	default:
+		return fmt.Errorf("unsupported fit: %s (use cover, contain or fill)", o.Fit)
+	}
+	switch o.Gravity {
+	case "", GravityCenter, GravitySmart:
+	default:
+		return fmt.Errorf("unsupported gravity: %s (use center or smart)", o.Gravity)
+	}
+	return nil
+}
+
+// Resize scales img to exactly Width x Height using the fit mode and gravity
+func Resize(img image.Image, opts ResizeOptions) image.Image {
+	b := img.Bounds()
+	if opts.Width == 0 || (b.Dx() == opts.Width && b.Dy() == opts.Height) {
+		return img
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	switch opts.Fit {
+	case FitFill:
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	case FitContain:
+		scale := math.Min(float64(opts.Width)/float64(b.Dx()), float64(opts.Height)/float64(b.Dy()))
+		w := max(1, int(math.Round(float64(b.Dx())*scale)))
+		h := max(1, int(math.Round(float64(b.Dy())*scale)))
+		x := (opts.Width - w) / 2
+		y := (opts.Height - h) / 2
+		draw.Draw(dst, dst.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(dst, image.Rect(x, y, x+w, y+h), img, b, draw.Src, nil)
+	default:
+		crop := coverCrop(img, opts.Width, opts.Height, opts.Gravity)
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	}
+	return dst
+}
+
+// coverCrop returns the largest region of img with the target aspect ratio,
+// positioned along the overflowing axis according to gravity
+func coverCrop(img image.Image, width, height int, gravity string) image.Rectangle {
+	b := img.Bounds()
+	target := float64(width) / float64(height)
+
+	if float64(b.Dx())/float64(b.Dy()) > target {
+		// Too wide: slide horizontally
+		cropW := max(1, int(math.Round(float64(b.Dy())*target)))
+		x := (b.Dx() - cropW) / 2
+		if gravity == GravitySmart {
+			x = smartOffset(img, cropW, b.Dy(), true)
+		}
+		return image.Rect(b.Min.X+x, b.Min.Y, b.Min.X+x+cropW, b.Max.Y)
+	}
+
+	// Too tall: slide vertically
+	cropH := max(1, int(math.Round(float64(b.Dx())/target)))
+	y := (b.Dy() - cropH) / 2
+	if gravity == GravitySmart {
+		y = smartOffset(img, b.Dx(), cropH, false)
+	}
+	return image.Rect(b.Min.X, b.Min.Y+y, b.Max.X, b.Min.Y+y+cropH)
+}
+
+// smartOffset finds the crop offset along one axis whose window has the highest
+// luminance entropy. The search runs on a small grayscale copy for speed.
+func smartOffset(img image.Image, cropW, cropH int, horizontal bool) int {
+	const analysisSize = 256
+	const steps = 32
+
+	b := img.Bounds()
+	scale := math.Min(1, analysisSize/float64(max(b.Dx(), b.Dy())))
+	sw := max(1, int(float64(b.Dx())*scale))
+	sh := max(1, int(float64(b.Dy())*scale))
+	gray := image.NewGray(image.Rect(0, 0, sw, sh))
+	draw.ApproxBiLinear.Scale(gray, gray.Bounds(), img, b, draw.Src, nil)
+
+	winW := min(sw, max(1, int(float64(cropW)*scale)))
+	winH := min(sh, max(1, int(float64(cropH)*scale)))
+	span := sh - winH
+	if horizontal {
+		span = sw - winW
+	}
+	if span <= 0 {
+		return 0
+	}
+
+	bestOffset, bestEntropy := 0, -1.0
+	for i := 0; i <= steps; i++ {
+		offset := span * i / steps
+		window := image.Rect(0, offset, winW, offset+winH)
+		if horizontal {
+			window = image.Rect(offset, 0, offset+winW, winH)
+		}
+		if e := entropy(gray, window); e > bestEntropy {
+			bestOffset, bestEntropy = offset, e
+		}
+	}
+
+	// Map back to source pixels
+	full := b.Dy() - cropH
+	if horizontal {
+		full = b.Dx() - cropW
+	}
+	return min(full, int(math.Round(float64(bestOffset)*float64(full)/float64(span))))
+}
+
+// entropy returns the Shannon entropy of the luminance histogram inside r
+func entropy(gray *image.Gray, r image.Rectangle) float64 {
+	var hist [256]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			hist[gray.GrayAt(x, y).Y]++
+		}
+	}
+
+	total := float64(r.Dx() * r.Dy())
+	e := 0.0
+	for _, n := range hist {
+		if n > 0 {
+			p := float64(n) / total
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
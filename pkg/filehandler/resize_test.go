@@ -0,0 +1,46 @@
+package filehandler
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeFitModes(t *testing.T) {
+	src := testImage(400, 300)
+	for _, fit := range []string{FitCover, FitContain, FitFill} {
+		got := Resize(src, ResizeOptions{Width: 120, Height: 63, Fit: fit})
+		if b := got.Bounds(); b.Dx() != 120 || b.Dy() != 63 {
+			t.Errorf("%s: got %dx%d, want 120x63", fit, b.Dx(), b.Dy())
+		}
+	}
+
+	// contain pads the sides of a wider target with transparency
+	contained := Resize(src, ResizeOptions{Width: 200, Height: 100, Fit: FitContain})
+	if _, _, _, a := contained.At(0, 50).RGBA(); a != 0 {
+		t.Errorf("expected transparent padding, got alpha %d", a)
+	}
+}
+
+func TestSmartGravityKeepsDetail(t *testing.T) {
+	// Flat gray on the left, noisy detail on the right
+	src := image.NewNRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+			if x >= 200 {
+				v := uint8((x*37 + y*91) % 256)
+				c = color.NRGBA{R: v, G: v, B: v, A: 255}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+
+	crop := coverCrop(src, 100, 100, GravitySmart)
+	if crop.Min.X < 150 {
+		t.Errorf("smart crop %v should favor the detailed right side", crop)
+	}
+	if center := coverCrop(src, 100, 100, GravityCenter); center.Min.X != 100 {
+		t.Errorf("center crop %v should start at x=100", center)
+	}
+}
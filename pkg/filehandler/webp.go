@@ -0,0 +1,488 @@
+package filehandler
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// This file implements a small lossless WebP (VP8L) encoder. It applies the
+// subtract-green and predictor transforms and entropy codes the residuals with
+// one set of Huffman codes. It does not use backward references or a color
+// cache, so files are larger than libwebp's, but they are valid lossless WebP
+// and decode bit-exactly.
+
+const (
+	vp8lSignature        = 0x2f
+	vp8lMaxDimension     = 1 << 14
+	vp8lPredictorBits    = 4 // 16x16 predictor tiles
+	vp8lMaxCodeLength    = 15
+	vp8lMaxCodeLenLength = 7
+	vp8lNumLengthCodes   = 19
+	vp8lGreenAlphabet    = 256 + 24 // literals + length prefix codes
+	vp8lDistanceAlphabet = 40
+)
+
+// vp8lCodeLengthOrder is the order in which code length code lengths are written
+var vp8lCodeLengthOrder = [vp8lNumLengthCodes]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// vp8lPredictors are the predictor modes tried for each tile
+var vp8lPredictors = []uint8{1, 2, 7, 11, 12}
+
+// EncodeWebPLossless writes img to w as a lossless WebP file
+func EncodeWebPLossless(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return fmt.Errorf("webp: image dimensions %dx%d out of range (1-%d)", width, height, vp8lMaxDimension)
+	}
+
+	// VP8L stores non-premultiplied RGBA
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+	pix := nrgba.Pix
+
+	hasAlpha := false
+	for i := 3; i < len(pix); i += 4 {
+		if pix[i] != 0xff {
+			hasAlpha = true
+			break
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if hasAlpha {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3) // version
+
+	// Transform 1: subtract green
+	bw.writeBits(1, 1)
+	bw.writeBits(2, 2)
+	subtractGreen(pix)
+
+	// Transform 2: predictor
+	bw.writeBits(1, 1)
+	bw.writeBits(0, 2)
+	bw.writeBits(vp8lPredictorBits-2, 3)
+	modes, tilesX, tilesY := choosePredictors(pix, width, height)
+	modePix := make([]byte, 4*len(modes))
+	for i, m := range modes {
+		modePix[4*i+1] = m // mode is stored in the green channel
+		modePix[4*i+3] = 0xff
+	}
+	writeImageData(bw, modePix, tilesX*tilesY, false)
+
+	residuals := applyPredictors(pix, width, height, modes, tilesX)
+
+	// No more transforms, then the main image
+	bw.writeBits(0, 1)
+	writeImageData(bw, residuals, width*height, true)
+
+	data := bw.bytes()
+
+	// RIFF container
+	var buf bytes.Buffer
+	chunkSize := len(data)
+	padded := chunkSize + chunkSize&1
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(4+8+padded))
+	buf.WriteString("WEBPVP8L")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(chunkSize))
+	buf.Write(data)
+	if chunkSize&1 == 1 {
+		buf.WriteByte(0)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// subtractGreen applies the subtract-green transform in place
+func subtractGreen(pix []byte) {
+	for i := 0; i < len(pix); i += 4 {
+		pix[i] -= pix[i+1]
+		pix[i+2] -= pix[i+1]
+	}
+}
+
+// choosePredictors picks, for every tile, the predictor mode with the smallest residuals
+func choosePredictors(pix []byte, width, height int) ([]uint8, int, int) {
+	tile := 1 << vp8lPredictorBits
+	tilesX := (width + tile - 1) / tile
+	tilesY := (height + tile - 1) / tile
+	modes := make([]uint8, tilesX*tilesY)
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			best, bestCost := uint8(1), -1
+			for _, mode := range vp8lPredictors {
+				cost := 0
+				for y := ty * tile; y < min(height, (ty+1)*tile); y++ {
+					if y == 0 {
+						continue // first row always predicts from the left
+					}
+					for x := max(1, tx*tile); x < min(width, (tx+1)*tile); x++ {
+						p := 4 * (y*width + x)
+						var pred [4]byte
+						predict(pix, p, 4*width, mode, &pred)
+						for c := 0; c < 4; c++ {
+							cost += residualCost(pix[p+c] - pred[c])
+						}
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = best
+		}
+	}
+
+	return modes, tilesX, tilesY
+}
+
+// residualCost approximates how expensive a residual byte is to entropy code
+func residualCost(r byte) int {
+	v := int(int8(r))
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// applyPredictors returns the residual image, mirroring the decoder's inverse predictor
+func applyPredictors(pix []byte, width, height int, modes []uint8, tilesX int) []byte {
+	out := make([]byte, len(pix))
+	stride := 4 * width
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := 4 * (y*width + x)
+			var pred [4]byte
+			switch {
+			case x == 0 && y == 0:
+				pred = [4]byte{0, 0, 0, 0xff}
+			case y == 0:
+				predict(pix, p, stride, 1, &pred)
+			case x == 0:
+				predict(pix, p, stride, 2, &pred)
+			default:
+				mode := modes[(y>>vp8lPredictorBits)*tilesX+(x>>vp8lPredictorBits)]
+				predict(pix, p, stride, mode, &pred)
+			}
+			for c := 0; c < 4; c++ {
+				out[p+c] = pix[p+c] - pred[c]
+			}
+		}
+	}
+	return out
+}
+
+// predict computes the prediction for the pixel at offset p using the given mode
+func predict(pix []byte, p, stride int, mode uint8, pred *[4]byte) {
+	l := p - 4
+	t := p - stride
+	tl := t - 4
+	for c := 0; c < 4; c++ {
+		switch mode {
+		case 1:
+			pred[c] = pix[l+c]
+		case 2:
+			pred[c] = pix[t+c]
+		case 7:
+			pred[c] = avg2(pix[l+c], pix[t+c])
+		case 12:
+			v := int(pix[l+c]) + int(pix[t+c]) - int(pix[tl+c])
+			pred[c] = clampByte(v)
+		}
+	}
+	if mode == 11 {
+		// Select: pick L or T, whichever is closer to the gradient estimate
+		lDist, tDist := 0, 0
+		for c := 0; c < 4; c++ {
+			lDist += absInt(int(pix[tl+c]) - int(pix[t+c]))
+			tDist += absInt(int(pix[tl+c]) - int(pix[l+c]))
+		}
+		src := t
+		if lDist < tDist {
+			src = l
+		}
+		copy(pred[:], pix[src:src+4])
+	}
+}
+
+func avg2(a, b byte) byte {
+	return byte((int(a) + int(b)) / 2)
+}
+
+func clampByte(v int) byte {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// writeImageData entropy codes an RGBA pixel array as literals with a single prefix code group
+func writeImageData(bw *bitWriter, pix []byte, numPixels int, topLevel bool) {
+	bw.writeBits(0, 1) // no color cache
+	if topLevel {
+		bw.writeBits(0, 1) // no meta prefix codes
+	}
+
+	// Histograms for green, red, blue, alpha and distance
+	green := make([]int, vp8lGreenAlphabet)
+	red := make([]int, 256)
+	blue := make([]int, 256)
+	alpha := make([]int, 256)
+	for i := 0; i < numPixels; i++ {
+		red[pix[4*i]]++
+		green[pix[4*i+1]]++
+		blue[pix[4*i+2]]++
+		alpha[pix[4*i+3]]++
+	}
+	distance := make([]int, vp8lDistanceAlphabet)
+
+	codes := make([]prefixCode, 5)
+	for i, hist := range [][]int{green, red, blue, alpha, distance} {
+		codes[i] = newPrefixCode(hist, vp8lMaxCodeLength)
+		writePrefixCode(bw, codes[i])
+	}
+
+	for i := 0; i < numPixels; i++ {
+		codes[0].write(bw, int(pix[4*i+1]))
+		codes[1].write(bw, int(pix[4*i]))
+		codes[2].write(bw, int(pix[4*i+2]))
+		codes[3].write(bw, int(pix[4*i+3]))
+	}
+}
+
+// prefixCode is a canonical Huffman code
+type prefixCode struct {
+	lengths []int
+	codes   []uint32 // bit-reversed, ready to write LSB first
+	single  bool     // only one symbol is used; it is coded with zero bits
+}
+
+// write emits the code for symbol
+func (pc prefixCode) write(bw *bitWriter, symbol int) {
+	if !pc.single {
+		bw.writeBits(pc.codes[symbol], pc.lengths[symbol])
+	}
+}
+
+// newPrefixCode builds a length-limited canonical Huffman code from a histogram
+func newPrefixCode(hist []int, maxLength int) prefixCode {
+	lengths := huffmanLengths(hist, maxLength)
+	used := 0
+	for _, l := range lengths {
+		if l > 0 {
+			used++
+		}
+	}
+	return prefixCode{lengths: lengths, codes: canonicalCodes(lengths), single: used == 1}
+}
+
+// huffmanLengths computes code lengths no longer than maxLength.
+// A single used symbol gets length 1 (the decoder reads it with zero bits).
+func huffmanLengths(hist []int, maxLength int) []int {
+	lengths := make([]int, len(hist))
+	freq := append([]int(nil), hist...)
+
+	used := 0
+	last := 0
+	for s, f := range freq {
+		if f > 0 {
+			used++
+			last = s
+		}
+	}
+	switch used {
+	case 0:
+		lengths[0] = 1
+		return lengths
+	case 1:
+		lengths[last] = 1
+		return lengths
+	}
+
+	for {
+		buildHuffmanLengths(freq, lengths)
+		longest := 0
+		for _, l := range lengths {
+			longest = max(longest, l)
+		}
+		if longest <= maxLength {
+			return lengths
+		}
+		// Flatten the distribution and try again
+		for s, f := range freq {
+			if f > 0 {
+				freq[s] = (f + 1) / 2
+			}
+		}
+	}
+}
+
+// huffNode is a node in the Huffman construction heap
+type huffNode struct {
+	freq   int
+	symbol int // -1 for internal nodes
+	left   *huffNode
+	right  *huffNode
+}
+
+type huffHeap []*huffNode
+
+func (h huffHeap) Len() int { return len(h) }
+func (h huffHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].symbol > h[j].symbol
+	}
+	return h[i].freq < h[j].freq
+}
+func (h huffHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *huffHeap) Push(x any)   { *h = append(*h, x.(*huffNode)) }
+func (h *huffHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// buildHuffmanLengths fills lengths with unrestricted Huffman code lengths for freq
+func buildHuffmanLengths(freq []int, lengths []int) {
+	h := &huffHeap{}
+	for s, f := range freq {
+		lengths[s] = 0
+		if f > 0 {
+			*h = append(*h, &huffNode{freq: f, symbol: s})
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(*huffNode)
+		b := heap.Pop(h).(*huffNode)
+		heap.Push(h, &huffNode{freq: a.freq + b.freq, symbol: -1, left: a, right: b})
+	}
+
+	var walk func(n *huffNode, depth int)
+	walk = func(n *huffNode, depth int) {
+		if n.symbol >= 0 {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(heap.Pop(h).(*huffNode), 0)
+}
+
+// canonicalCodes assigns canonical codes to lengths and bit-reverses them for LSB-first output
+func canonicalCodes(lengths []int) []uint32 {
+	type sym struct{ symbol, length int }
+	var syms []sym
+	for s, l := range lengths {
+		if l > 0 {
+			syms = append(syms, sym{s, l})
+		}
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].length == syms[j].length {
+			return syms[i].symbol < syms[j].symbol
+		}
+		return syms[i].length < syms[j].length
+	})
+
+	codes := make([]uint32, len(lengths))
+	code, prevLen := uint32(0), 0
+	for i, s := range syms {
+		if i > 0 {
+			code = (code + 1) << uint(s.length-prevLen)
+		}
+		prevLen = s.length
+		codes[s.symbol] = reverseBits(code, s.length)
+	}
+	return codes
+}
+
+// reverseBits reverses the low n bits of v
+func reverseBits(v uint32, n int) uint32 {
+	var r uint32
+	for i := 0; i < n; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+	return r
+}
+
+// writePrefixCode writes a prefix code's lengths using the normal (code length code) encoding
+func writePrefixCode(bw *bitWriter, pc prefixCode) {
+	bw.writeBits(0, 1) // normal, not simple
+
+	// The code lengths themselves are written literally (symbols 0-15), so the
+	// code length code only needs to cover the lengths that occur
+	hist := make([]int, vp8lNumLengthCodes)
+	for _, l := range pc.lengths {
+		hist[l]++
+	}
+	lengthCode := newPrefixCode(hist, vp8lMaxCodeLenLength)
+
+	numCodes := vp8lNumLengthCodes
+	for numCodes > 4 && lengthCode.lengths[vp8lCodeLengthOrder[numCodes-1]] == 0 {
+		numCodes--
+	}
+	bw.writeBits(uint32(numCodes-4), 4)
+	for i := 0; i < numCodes; i++ {
+		bw.writeBits(uint32(lengthCode.lengths[vp8lCodeLengthOrder[i]]), 3)
+	}
+
+	bw.writeBits(0, 1) // max_symbol is the full alphabet
+	for _, l := range pc.lengths {
+		lengthCode.write(bw, l)
+	}
+}
+
+// bitWriter packs bits LSB first, as VP8L requires
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits int
+}
+
+func (b *bitWriter) writeBits(v uint32, n int) {
+	b.acc |= uint64(v) << uint(b.nBits)
+	b.nBits += n
+	for b.nBits >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nBits -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.nBits > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nBits = 0, 0
+	}
+	return b.buf
+}
@@ -8,12 +8,14 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	ModelName       = "gemini-3-pro-image-preview"
 	ModelNameFrugal = "gemini-2.5-flash-image"
+	ModelNameText   = "gemini-2.5-flash" // reads images and answers in text, e.g. to check labels
 	BaseURL         = "https://generativelanguage.googleapis.com/v1beta/models"
 )
 
@@ -37,6 +39,19 @@ type Client struct {
 	httpClient *http.Client
 	model      string
 	baseURL    string
+	usage      UsageMetadata
+	callHook   func(CallInfo)
+	mu         sync.Mutex // guards usage and the call hook so goroutines can share a client
+}
+
+// CallInfo describes a successful request, reported to the client's call hook
+type CallInfo struct {
+	Model       string
+	Prompt      string
+	InputImages int
+	ImageConfig *ImageConfig // nil for text requests
+	TextOnly    bool         // the response was text, not an image
+	Usage       UsageMetadata
 }
 
 // GenerateRequest represents a request to generate content
@@ -76,8 +91,23 @@ type InlineData struct {
 
 // GenerateResponse represents the API response
 type GenerateResponse struct {
-	Candidates []Candidate `json:"candidates"`
-	Error      *ErrorInfo  `json:"error,omitempty"`
+	Candidates    []Candidate    `json:"candidates"`
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	Error         *ErrorInfo     `json:"error,omitempty"`
+}
+
+// UsageMetadata represents token usage reported by the API
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// Add accumulates another usage report into u
+func (u *UsageMetadata) Add(other UsageMetadata) {
+	u.PromptTokenCount += other.PromptTokenCount
+	u.CandidatesTokenCount += other.CandidatesTokenCount
+	u.TotalTokenCount += other.TotalTokenCount
 }
 
 // Candidate represents a response candidate
@@ -109,11 +139,17 @@ func NewClientWithModel(model string) (*Client, error) {
 		return nil, fmt.Errorf("API key not found. Please set one of: NANOBANANA_GEMINI_API_KEY, NANOBANANA_GOOGLE_API_KEY, GEMINI_API_KEY, or GOOGLE_API_KEY")
 	}
 
+	// Allow pointing the client at a proxy or a fake backend
+	baseURL := BaseURL
+	if override := os.Getenv("IMAGEMAGE_BASE_URL"); override != "" {
+		baseURL = strings.TrimSuffix(override, "/")
+	}
+
 	return &Client{
 		apiKey:     apiKey,
 		httpClient: &http.Client{Timeout: 5 * time.Minute},
 		model:      model,
-		baseURL:    BaseURL,
+		baseURL:    baseURL,
 	}, nil
 }
 
@@ -135,6 +171,54 @@ func getAPIKey() string {
 	return ""
 }
 
+// NewImageConfig builds the image configuration sent for a model, resolution and aspect ratio
+func NewImageConfig(model, resolution, aspectRatio string) *ImageConfig {
+	// Configure image generation based on model capabilities
+	imageConfig := &ImageConfig{
+		AspectRatio: aspectRatio,
+	}
+
+	// Frugal model (2.5 Flash) has fixed 1024px output and doesn't accept imageSize parameter
+	// Pro model supports 1K, 2K, 4K via imageSize parameter
+	if model != ModelNameFrugal {
+		imageSize := resolution
+		if imageSize == "" {
+			imageSize = "4K" // Pro model default
+		}
+		imageConfig.ImageSize = imageSize
+	}
+	// For frugal model, omit ImageSize entirely (fixed 1024px output)
+
+	return imageConfig
+}
+
+// Model returns the model name used by this client
+func (c *Client) Model() string {
+	if c.model == "" {
+		return ModelName
+	}
+	return c.model
+}
+
+// SetCallHook registers a function called after every successful image request
+func (c *Client) SetCallHook(hook func(CallInfo)) {
+	c.callHook = hook
+}
+
+// Usage returns the token usage accumulated across all requests made by this client
+func (c *Client) Usage() UsageMetadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage
+}
+
+// called reports a successful request to the call hook, one call at a time
+func (c *Client) called(info CallInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callHook(info)
+}
+
 // ValidateAspectRatio checks if the aspect ratio is supported
 func ValidateAspectRatio(aspectRatio string) error {
 	if aspectRatio == "" {
@@ -242,29 +326,97 @@ func (c *Client) GenerateContentWithFullOptions(prompt string, imagesBase64 []st
 		},
 	}
 
-	// Configure image generation based on model capabilities
-	imageConfig := &ImageConfig{
-		AspectRatio: aspectRatio,
+	imageConfig := NewImageConfig(c.model, resolution, aspectRatio)
+	reqBody.GenerationConfig = &GenerationConfig{
+		ImageConfig: imageConfig,
 	}
 
-	// Frugal model (2.5 Flash) has fixed 1024px output and doesn't accept imageSize parameter
-	// Pro model supports 1K, 2K, 4K via imageSize parameter
-	if c.model != ModelNameFrugal {
-		imageSize := resolution
-		if imageSize == "" {
-			imageSize = "4K" // Pro model default
+	model := c.Model()
+	result, err := c.send(model, reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	// Extract image data from response
+	imageData := c.extractImageData(result)
+	if imageData == "" {
+		return "", fmt.Errorf("no image data found in response")
+	}
+
+	if c.callHook != nil {
+		info := CallInfo{
+			Model:       model,
+			Prompt:      prompt,
+			InputImages: len(parts) - 1,
+			ImageConfig: imageConfig,
 		}
-		imageConfig.ImageSize = imageSize
+		if result.UsageMetadata != nil {
+			info.Usage = *result.UsageMetadata
+		}
+		c.called(info)
 	}
-	// For frugal model, omit ImageSize entirely (fixed 1024px output)
 
-	reqBody.GenerationConfig = &GenerationConfig{
-		ImageConfig: imageConfig,
+	return imageData, nil
+}
+
+// ReadTextPrompt asks the text model for a transcription, not an interpretation
+const ReadTextPrompt = "List every piece of text visible in this image, one item per line, exactly as it is written - " +
+	"keep spelling mistakes, capitalization and punctuation as they appear. Put each separate label, heading or text box " +
+	"on its own line. Output only the text, with no commentary, numbering or formatting. If there is no text, output nothing."
+
+// ReadText returns the text visible in an image, one label or line per entry. It uses
+// the text model regardless of the client's image model.
+func (c *Client) ReadText(imageBase64 string) ([]string, error) {
+	reqBody := GenerateRequest{
+		Contents: []Content{
+			{
+				Role: "user",
+				Parts: []Part{
+					{Text: ReadTextPrompt},
+					{InlineData: &InlineData{MimeType: "image/png", Data: imageBase64}},
+				},
+			},
+		},
+	}
+
+	result, err := c.send(ModelNameText, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Candidates) == 0 {
+		return nil, fmt.Errorf("no text found in response")
+	}
+
+	var lines []string
+	for _, part := range result.Candidates[0].Content.Parts {
+		for _, line := range strings.Split(part.Text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
 	}
 
+	if c.callHook != nil {
+		info := CallInfo{
+			Model:       ModelNameText,
+			Prompt:      ReadTextPrompt,
+			InputImages: 1,
+			TextOnly:    true,
+		}
+		if result.UsageMetadata != nil {
+			info.Usage = *result.UsageMetadata
+		}
+		c.called(info)
+	}
+
+	return lines, nil
+}
+
+// send posts a request to a model and returns the decoded response, accumulating usage
+func (c *Client) send(model string, reqBody GenerateRequest) (*GenerateResponse, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Debug: Print request body if DEBUG env var is set
@@ -272,11 +424,7 @@ func (c *Client) GenerateContentWithFullOptions(prompt string, imagesBase64 []st
 		fmt.Fprintf(os.Stderr, "DEBUG: Request body:\n%s\n", string(jsonData))
 	}
 
-	// Use client's model and baseURL, falling back to defaults if not set
-	model := c.model
-	if model == "" {
-		model = ModelName
-	}
+	// Use client's baseURL, falling back to the default if not set
 	baseURL := c.baseURL
 	if baseURL == "" {
 		baseURL = BaseURL
@@ -291,20 +439,20 @@ func (c *Client) GenerateContentWithFullOptions(prompt string, imagesBase64 []st
 	}
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Debug: Print response if DEBUG env var is set
@@ -314,25 +462,25 @@ func (c *Client) GenerateContentWithFullOptions(prompt string, imagesBase64 []st
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", c.handleError(resp.StatusCode, body)
+		return nil, c.handleError(resp.StatusCode, body)
 	}
 
 	var result GenerateResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("API error (%d): %s", result.Error.Code, result.Error.Message)
+		return nil, fmt.Errorf("API error (%d): %s", result.Error.Code, result.Error.Message)
 	}
 
-	// Extract image data from response
-	imageData := c.extractImageData(&result)
-	if imageData == "" {
-		return "", fmt.Errorf("no image data found in response")
+	if result.UsageMetadata != nil {
+		c.mu.Lock()
+		c.usage.Add(*result.UsageMetadata)
+		c.mu.Unlock()
 	}
 
-	return imageData, nil
+	return &result, nil
 }
 
 // extractImageData extracts base64 image data from the response
@@ -9,6 +9,7 @@ import (
 
 // ImageConfig represents configuration for image generation
 type ImageGenConfig struct {
+	Project  string           `json:"project"`
 	Defaults ImageGenDefaults `json:"defaults"`
 }
 
@@ -106,3 +107,11 @@ func (c *ImageGenConfig) GetResolution() string {
 	}
 	return c.Defaults.Resolution
 }
+
+// GetProject returns the project tag used for usage tracking, or empty string if not set
+func (c *ImageGenConfig) GetProject() string {
+	if c == nil {
+		return ""
+	}
+	return c.Project
+}
@@ -0,0 +1,78 @@
+package gemini
+
+// Pricing describes the published per-request cost of an image model in USD
+type Pricing struct {
+	// InputPerMillionTokens is the price of prompt text and input images
+	InputPerMillionTokens float64
+	// InputImageTokens is the number of tokens billed per input image
+	InputImageTokens int
+	// OutputPerMillionTokens is the price of generated output, used when actual usage is known
+	OutputPerMillionTokens float64
+	// OutputImage is the price per generated image, keyed by resolution ("" is the model default)
+	OutputImage map[string]float64
+}
+
+// PricingTable holds list prices for the supported models.
+// Prices change; treat estimates as a guide rather than an invoice.
+var PricingTable = map[string]Pricing{
+	ModelName: {
+		InputPerMillionTokens:  2.00,
+		InputImageTokens:       560,
+		OutputPerMillionTokens: 120.00,
+		OutputImage: map[string]float64{
+			"":   0.24,
+			"1K": 0.134,
+			"2K": 0.134,
+			"4K": 0.24,
+		},
+	},
+	ModelNameFrugal: {
+		InputPerMillionTokens:  0.30,
+		InputImageTokens:       258,
+		OutputPerMillionTokens: 30.00,
+		OutputImage: map[string]float64{
+			"": 0.039,
+		},
+	},
+	ModelNameText: {
+		InputPerMillionTokens:  0.30,
+		InputImageTokens:       258,
+		OutputPerMillionTokens: 2.50,
+		OutputImage: map[string]float64{
+			"": 0, // text only; a few hundred output tokens cost a fraction of a cent
+		},
+	},
+}
+
+// EstimatePromptTokens approximates the token count of a text prompt (~4 characters per token)
+func EstimatePromptTokens(prompt string) int {
+	return (len(prompt) + 3) / 4
+}
+
+// EstimateCost returns the estimated USD cost of a single image request.
+// Unknown models are priced like the default Pro model so budgets err on the safe side.
+func EstimateCost(model, resolution, prompt string, inputImages int) float64 {
+	pricing, ok := PricingTable[model]
+	if !ok {
+		pricing = PricingTable[ModelName]
+	}
+
+	output, ok := pricing.OutputImage[resolution]
+	if !ok {
+		output = pricing.OutputImage[""]
+	}
+
+	inputTokens := EstimatePromptTokens(prompt) + inputImages*pricing.InputImageTokens
+	return output + float64(inputTokens)*pricing.InputPerMillionTokens/1e6
+}
+
+// CostFromUsage returns the USD cost of a request from the token usage reported by the API
+func CostFromUsage(model string, usage UsageMetadata) float64 {
+	pricing, ok := PricingTable[model]
+	if !ok {
+		pricing = PricingTable[ModelName]
+	}
+
+	return float64(usage.PromptTokenCount)*pricing.InputPerMillionTokens/1e6 +
+		float64(usage.CandidatesTokenCount)*pricing.OutputPerMillionTokens/1e6
+}
@@ -0,0 +1,389 @@
+package iconset
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported bundle platforms
+const (
+	PlatformWeb     = "web"
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformMacOS   = "macos"
+	PlatformAll     = "all"
+)
+
+// Platforms lists the individual bundle platforms in output order
+var Platforms = []string{PlatformWeb, PlatformIOS, PlatformAndroid, PlatformMacOS}
+
+// Options customizes the generated bundles
+type Options struct {
+	Name       string      // app name for site.webmanifest
+	Background color.Color // background for opaque icons; nil samples the source's edges
+	Optimize   bool        // sharpen and clean up small sizes (see Optimize)
+	Glyph      image.Image // simplified variant used below GlyphMaxSize; nil uses the source
+}
+
+// Bundle is the result of writing one platform bundle
+type Bundle struct {
+	Platform string
+	Dir      string
+	Files    []string
+	Notes    []string // snippets to show the user, e.g. HTML link tags
+}
+
+// ParsePlatforms parses a comma-separated platform list, expanding "all"
+func ParsePlatforms(s string) ([]string, error) {
+	seen := map[string]bool{}
+	var platforms []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch p {
+		case PlatformAll:
+			for _, all := range Platforms {
+				if !seen[all] {
+					seen[all] = true
+					platforms = append(platforms, all)
+				}
+			}
+		case PlatformWeb, PlatformIOS, PlatformAndroid, PlatformMacOS:
+			if !seen[p] {
+				seen[p] = true
+				platforms = append(platforms, p)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported bundle: %s (use web, ios, android, macos or all)", p)
+		}
+	}
+	return platforms, nil
+}
+
+// Write writes the bundle for platform into dir/<platform>
+func Write(dir, platform string, src image.Image, opts Options) (*Bundle, error) {
+	if opts.Background == nil {
+		opts.Background = EdgeColor(src)
+	}
+
+	w := &bundleWriter{
+		Bundle: Bundle{Platform: platform, Dir: filepath.Join(dir, platform)},
+		render: func(size int) image.Image { return opts.Render(src, size) },
+	}
+	switch platform {
+	case PlatformWeb:
+		w.web(opts)
+	case PlatformIOS:
+		w.ios(opts)
+	case PlatformAndroid:
+		w.android(opts)
+	case PlatformMacOS:
+		w.macos()
+	default:
+		return nil, fmt.Errorf("unsupported bundle: %s", platform)
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+	return &w.Bundle, nil
+}
+
+// bundleWriter accumulates written files and stops at the first error
+type bundleWriter struct {
+	Bundle
+	render func(size int) image.Image
+	err    error
+}
+
+// write writes data to a path relative to the bundle directory
+func (w *bundleWriter) write(rel string, data []byte) {
+	if w.err != nil {
+		return
+	}
+	path := filepath.Join(w.Dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		w.err = fmt.Errorf("failed to create directory: %w", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		w.err = fmt.Errorf("failed to write %s: %w", rel, err)
+		return
+	}
+	w.Files = append(w.Files, path)
+}
+
+// writePNG encodes img as PNG and writes it
+func (w *bundleWriter) writePNG(rel string, img image.Image) {
+	if w.err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		w.err = fmt.Errorf("failed to encode %s: %w", rel, err)
+		return
+	}
+	w.write(rel, buf.Bytes())
+}
+
+// writeJSON writes v as indented JSON
+func (w *bundleWriter) writeJSON(rel string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil && w.err == nil {
+		w.err = fmt.Errorf("failed to encode %s: %w", rel, err)
+	}
+	w.write(rel, append(data, '\n'))
+}
+
+// webManifest is the PWA site.webmanifest
+type webManifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name"`
+	Icons           []manifestIcon `json:"icons"`
+	ThemeColor      string         `json:"theme_color"`
+	BackgroundColor string         `json:"background_color"`
+	Display         string         `json:"display"`
+}
+
+type manifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// web writes favicon.ico, PNG favicons, apple-touch-icon, PWA icons and the manifest
+func (w *bundleWriter) web(opts Options) {
+	ico, err := EncodeICO([]image.Image{w.render(16), w.render(32), w.render(48)})
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.write("favicon.ico", ico)
+	w.writePNG("favicon-16x16.png", w.render(16))
+	w.writePNG("favicon-32x32.png", w.render(32))
+	// iOS ignores transparency on home screen icons and fills it with black
+	w.writePNG("apple-touch-icon.png", flatten(w.render(180), opts.Background))
+	w.writePNG("android-chrome-192x192.png", w.render(192))
+	w.writePNG("android-chrome-512x512.png", w.render(512))
+
+	name := opts.Name
+	shortName := name
+	if runes := []rune(name); len(runes) > 12 {
+		shortName = strings.TrimSpace(string(runes[:12]))
+	}
+	background := hexColor(opts.Background)
+	w.writeJSON("site.webmanifest", webManifest{
+		Name:      name,
+		ShortName: shortName,
+		Icons: []manifestIcon{
+			{Src: "/android-chrome-192x192.png", Sizes: "192x192", Type: "image/png"},
+			{Src: "/android-chrome-512x512.png", Sizes: "512x512", Type: "image/png"},
+		},
+		ThemeColor:      background,
+		BackgroundColor: background,
+		Display:         "standalone",
+	})
+
+	links := strings.Join([]string{
+		`<link rel="icon" href="/favicon.ico" sizes="16x16 32x32 48x48">`,
+		`<link rel="icon" type="image/png" sizes="32x32" href="/favicon-32x32.png">`,
+		`<link rel="icon" type="image/png" sizes="16x16" href="/favicon-16x16.png">`,
+		`<link rel="apple-touch-icon" sizes="180x180" href="/apple-touch-icon.png">`,
+		`<link rel="manifest" href="/site.webmanifest">`,
+		fmt.Sprintf(`<meta name="theme-color" content="%s">`, background),
+	}, "\n")
+	w.write("icon-links.html", []byte(links+"\n"))
+	w.Notes = append(w.Notes, links)
+}
+
+// appIconImage is one entry of an asset catalog's Contents.json
+type appIconImage struct {
+	Size     string `json:"size"`
+	Idiom    string `json:"idiom"`
+	Filename string `json:"filename"`
+	Scale    string `json:"scale"`
+}
+
+// iosAppIcons lists every size, idiom and scale of an iOS AppIcon set
+var iosAppIcons = []struct {
+	idiom  string
+	points float64
+	scales []int
+}{
+	{"iphone", 20, []int{2, 3}},
+	{"iphone", 29, []int{2, 3}},
+	{"iphone", 40, []int{2, 3}},
+	{"iphone", 60, []int{2, 3}},
+	{"ipad", 20, []int{1, 2}},
+	{"ipad", 29, []int{1, 2}},
+	{"ipad", 40, []int{1, 2}},
+	{"ipad", 76, []int{1, 2}},
+	{"ipad", 83.5, []int{2}},
+	{"ios-marketing", 1024, []int{1}},
+}
+
+// ios writes AppIcon.appiconset with Contents.json. App Store icons must be opaque.
+func (w *bundleWriter) ios(opts Options) {
+	var images []appIconImage
+	written := map[string]bool{}
+	for _, icon := range iosAppIcons {
+		points := fmt.Sprintf("%g", icon.points)
+		for _, s := range icon.scales {
+			pixels := int(math.Round(icon.points * float64(s)))
+			filename := fmt.Sprintf("Icon-%dx%d.png", pixels, pixels)
+			if !written[filename] {
+				w.writePNG(filepath.Join("AppIcon.appiconset", filename), flatten(w.render(pixels), opts.Background))
+				written[filename] = true
+			}
+			images = append(images, appIconImage{
+				Size:     points + "x" + points,
+				Idiom:    icon.idiom,
+				Filename: filename,
+				Scale:    fmt.Sprintf("%dx", s),
+			})
+		}
+	}
+
+	w.writeJSON(filepath.Join("AppIcon.appiconset", "Contents.json"), map[string]any{
+		"images": images,
+		"info":   map[string]any{"version": 1, "author": "imagemage"},
+	})
+}
+
+// androidDensities maps mipmap density buckets to the launcher icon size in pixels
+var androidDensities = []struct {
+	name string
+	size int
+}{
+	{"mdpi", 48},
+	{"hdpi", 72},
+	{"xhdpi", 96},
+	{"xxhdpi", 144},
+	{"xxxhdpi", 192},
+}
+
+const adaptiveIconXML = `<?xml version="1.0" encoding="utf-8"?>
+<adaptive-icon xmlns:android="http://schemas.android.com/apk/res/android">
+    <background android:drawable="@color/ic_launcher_background"/>
+    <foreground android:drawable="@mipmap/ic_launcher_foreground"/>
+</adaptive-icon>
+`
+
+// android writes legacy and round launcher icons for each density, adaptive icon
+// layers, and the Play Store icon, laid out as an app's res/ directory
+func (w *bundleWriter) android(opts Options) {
+	for _, d := range androidDensities {
+		dir := filepath.Join("res", "mipmap-"+d.name)
+		w.writePNG(filepath.Join(dir, "ic_launcher.png"), w.render(d.size))
+		w.writePNG(filepath.Join(dir, "ic_launcher_round.png"), circle(w.render(d.size)))
+
+		// Adaptive icon layers are 108dp; keep the artwork inside the 72dp area
+		// launchers never mask away
+		layer := d.size * 108 / 48
+		w.writePNG(filepath.Join(dir, "ic_launcher_foreground.png"), inset(w.render(d.size*72/48), layer))
+	}
+
+	anydpi := filepath.Join("res", "mipmap-anydpi-v26")
+	w.write(filepath.Join(anydpi, "ic_launcher.xml"), []byte(adaptiveIconXML))
+	w.write(filepath.Join(anydpi, "ic_launcher_round.xml"), []byte(adaptiveIconXML))
+	w.write(filepath.Join("res", "values", "ic_launcher_background.xml"), []byte(fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<resources>
+    <color name="ic_launcher_background">%s</color>
+</resources>
+`, hexColor(opts.Background))))
+
+	w.writePNG("playstore-icon.png", flatten(w.render(512), opts.Background))
+}
+
+// macos writes AppIcon.icns
+func (w *bundleWriter) macos() {
+	icns, err := encodeICNS(w.render)
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.write("AppIcon.icns", icns)
+}
+
+// flatten composites img over an opaque background
+func flatten(img image.Image, bg color.Color) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// circle masks img to an anti-aliased circle
+func circle(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	r := float64(b.Dx()) / 2
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			d := math.Hypot(float64(x)+0.5-r, float64(y)+0.5-r)
+			coverage := math.Max(0, math.Min(1, r-d+0.5))
+			c.A = uint8(float64(c.A) * coverage)
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return dst
+}
+
+// inset centers img on a transparent canvas of size pixels
+func inset(img image.Image, size int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	b := img.Bounds()
+	offset := image.Pt((size-b.Dx())/2, (size-b.Dy())/2)
+	draw.Draw(dst, image.Rectangle{Min: offset, Max: offset.Add(b.Size())}, img, b.Min, draw.Src)
+	return dst
+}
+
+// EdgeColor returns the average opaque color along the border of img,
+// or white when the border is mostly transparent
+func EdgeColor(img image.Image) color.Color {
+	b := img.Bounds()
+	var r, g, bl, n uint64
+	sample := func(x, y int) {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		if c.A < 128 {
+			return
+		}
+		r += uint64(c.R)
+		g += uint64(c.G)
+		bl += uint64(c.B)
+		n++
+	}
+
+	total := 0
+	for x := b.Min.X; x < b.Max.X; x++ {
+		sample(x, b.Min.Y)
+		sample(x, b.Max.Y-1)
+		total += 2
+	}
+	for y := b.Min.Y + 1; y < b.Max.Y-1; y++ {
+		sample(b.Min.X, y)
+		sample(b.Max.X-1, y)
+		total += 2
+	}
+
+	if n == 0 || int(n) < total/2 {
+		return color.White
+	}
+	return color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 255}
+}
+
+// hexColor formats a color as #rrggbb
+func hexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
@@ -0,0 +1,61 @@
+package iconset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"imagemage/pkg/filehandler"
+)
+
+// icnsTypes maps each PNG-based icns element type to its pixel size
+var icnsTypes = []struct {
+	osType string
+	size   int
+}{
+	{"icp4", 16},
+	{"icp5", 32},
+	{"ic11", 32}, // 16x16@2x
+	{"icp6", 64},
+	{"ic12", 64}, // 32x32@2x
+	{"ic07", 128},
+	{"ic08", 256},
+	{"ic13", 256}, // 128x128@2x
+	{"ic09", 512},
+	{"ic14", 512},  // 256x256@2x
+	{"ic10", 1024}, // 512x512@2x
+}
+
+// encodeICNS builds an .icns file from a function rendering the icon at each size
+func encodeICNS(render func(size int) image.Image) ([]byte, error) {
+	var body bytes.Buffer
+	encoded := map[int][]byte{}
+
+	for _, t := range icnsTypes {
+		data, ok := encoded[t.size]
+		if !ok {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, render(t.size)); err != nil {
+				return nil, fmt.Errorf("failed to encode icns %s: %w", t.osType, err)
+			}
+			data = buf.Bytes()
+			encoded[t.size] = data
+		}
+
+		body.WriteString(t.osType)
+		_ = binary.Write(&body, binary.BigEndian, uint32(8+len(data)))
+		body.Write(data)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("icns")
+	_ = binary.Write(&out, binary.BigEndian, uint32(8+body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// scale resizes src to a size x size square, cropping non-square sources from the center
+func scale(src image.Image, size int) image.Image {
+	return filehandler.Resize(src, filehandler.ResizeOptions{Width: size, Height: size, Fit: filehandler.FitCover})
+}
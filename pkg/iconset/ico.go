@@ -0,0 +1,57 @@
+package iconset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+// EncodeICO encodes images as a multi-resolution Windows icon.
+// Each entry is stored as PNG, which every browser and Windows Vista+ understands.
+func EncodeICO(images []image.Image) ([]byte, error) {
+	if len(images) == 0 || len(images) > 0xffff {
+		return nil, fmt.Errorf("ico needs between 1 and 65535 images, got %d", len(images))
+	}
+
+	entries := make([][]byte, len(images))
+	for i, img := range images {
+		b := img.Bounds()
+		if b.Dx() > 256 || b.Dy() > 256 {
+			return nil, fmt.Errorf("ico images must be at most 256x256, got %dx%d", b.Dx(), b.Dy())
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode ico entry: %w", err)
+		}
+		entries[i] = buf.Bytes()
+	}
+
+	var out bytes.Buffer
+	le := binary.LittleEndian
+
+	// ICONDIR: reserved, type (1 = icon), count
+	_ = binary.Write(&out, le, [3]uint16{0, 1, uint16(len(images))})
+
+	// ICONDIRENTRY for each image; 0 means 256 for width and height
+	offset := 6 + 16*len(images)
+	for i, img := range images {
+		b := img.Bounds()
+		_ = out.WriteByte(byte(b.Dx() % 256))
+		_ = out.WriteByte(byte(b.Dy() % 256))
+		_ = out.WriteByte(0)                   // palette colors
+		_ = out.WriteByte(0)                   // reserved
+		_ = binary.Write(&out, le, uint16(1))  // color planes
+		_ = binary.Write(&out, le, uint16(32)) // bits per pixel
+		_ = binary.Write(&out, le, uint32(len(entries[i])))
+		_ = binary.Write(&out, le, uint32(offset))
+		offset += len(entries[i])
+	}
+
+	for _, entry := range entries {
+		out.Write(entry)
+	}
+
+	return out.Bytes(), nil
+}
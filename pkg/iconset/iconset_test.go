@@ -0,0 +1,153 @@
+package iconset
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func solidImage(size int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = 0xc0
+	}
+	return img
+}
+
+func TestEncodeICO(t *testing.T) {
+	data, err := EncodeICO([]image.Image{solidImage(16), solidImage(256)})
+	if err != nil {
+		t.Fatalf("EncodeICO failed: %v", err)
+	}
+
+	le := binary.LittleEndian
+	if le.Uint16(data[2:]) != 1 || le.Uint16(data[4:]) != 2 {
+		t.Fatalf("bad ICONDIR header: % x", data[:6])
+	}
+	if data[6] != 16 || data[22] != 0 {
+		t.Errorf("expected widths 16 and 0 (256), got %d and %d", data[6], data[22])
+	}
+	offset := le.Uint32(data[6+12:])
+	if string(data[offset+1:offset+4]) != "PNG" {
+		t.Errorf("first entry is not PNG data")
+	}
+
+	if _, err := EncodeICO([]image.Image{solidImage(512)}); err == nil {
+		t.Error("expected an error for images larger than 256x256")
+	}
+}
+
+func TestEncodeICNS(t *testing.T) {
+	src := solidImage(64)
+	data, err := encodeICNS(func(size int) image.Image { return scale(src, size) })
+	if err != nil {
+		t.Fatalf("encodeICNS failed: %v", err)
+	}
+	if string(data[:4]) != "icns" || int(binary.BigEndian.Uint32(data[4:])) != len(data) {
+		t.Fatalf("bad icns header")
+	}
+
+	// Walk the elements and check every type is present
+	var types []string
+	for p := 8; p < len(data); {
+		types = append(types, string(data[p:p+4]))
+		p += int(binary.BigEndian.Uint32(data[p+4:]))
+	}
+	if len(types) != len(icnsTypes) {
+		t.Errorf("got %d icns elements, want %d: %v", len(types), len(icnsTypes), types)
+	}
+}
+
+func TestParsePlatforms(t *testing.T) {
+	got, err := ParsePlatforms("ios, all")
+	if err != nil {
+		t.Fatalf("ParsePlatforms failed: %v", err)
+	}
+	want := []string{PlatformIOS, PlatformWeb, PlatformAndroid, PlatformMacOS}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := ParsePlatforms("windows"); err == nil {
+		t.Error("expected an error for an unknown platform")
+	}
+}
+
+func TestWriteIOSContents(t *testing.T) {
+	dir := t.TempDir()
+	bundle, err := Write(dir, PlatformIOS, solidImage(128), Options{Background: color.White})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(bundle.Dir, "AppIcon.appiconset", "Contents.json"))
+	if err != nil {
+		t.Fatalf("missing Contents.json: %v", err)
+	}
+	var contents struct {
+		Images []appIconImage `json:"images"`
+	}
+	if err := json.Unmarshal(data, &contents); err != nil {
+		t.Fatalf("invalid Contents.json: %v", err)
+	}
+	if len(contents.Images) != 18 {
+		t.Errorf("expected 18 icon entries, got %d", len(contents.Images))
+	}
+	for _, img := range contents.Images {
+		if _, err := os.Stat(filepath.Join(bundle.Dir, "AppIcon.appiconset", img.Filename)); err != nil {
+			t.Errorf("Contents.json references missing file %s", img.Filename)
+		}
+	}
+}
+
+func TestOptimizeSnapsAlpha(t *testing.T) {
+	// A soft-edged disc: opaque center fading out to transparent corners
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			d := math.Hypot(float64(x)-128, float64(y)-128)
+			a := math.Max(0, math.Min(255, (120-d)*4))
+			src.SetNRGBA(x, y, color.NRGBA{R: 200, G: 40, B: 40, A: uint8(a)})
+		}
+	}
+
+	partial := func(img image.Image) int {
+		n := 0
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if a := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA).A; a > 0 && a < 255 {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	plain := scale(src, 16)
+	optimized := Optimize(src, 16)
+	if optimized.Bounds().Dx() != 16 || optimized.Bounds().Dy() != 16 {
+		t.Fatalf("expected 16x16, got %v", optimized.Bounds())
+	}
+	if partial(optimized) >= partial(plain) {
+		t.Errorf("expected fewer semi-transparent pixels after optimizing: %d vs %d", partial(optimized), partial(plain))
+	}
+}
+
+func TestRenderUsesGlyphBelowMaxSize(t *testing.T) {
+	glyph := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	opts := Options{Glyph: glyph}
+	src := solidImage(64)
+
+	if c := color.NRGBAModel.Convert(opts.Render(src, 16).At(8, 8)).(color.NRGBA); c.A != 0 {
+		t.Errorf("expected the transparent glyph at 16px, got %v", c)
+	}
+	if c := color.NRGBAModel.Convert(opts.Render(src, GlyphMaxSize).At(8, 8)).(color.NRGBA); c.A == 0 {
+		t.Errorf("expected the full icon at %dpx", GlyphMaxSize)
+	}
+}
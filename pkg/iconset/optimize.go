@@ -0,0 +1,129 @@
+package iconset
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// GlyphMaxSize is the size below which a simplified glyph variant replaces the
+// full icon when one is available
+const GlyphMaxSize = 48
+
+// tuning holds the post-processing strength for one size range
+type tuning struct {
+	maxSize  int     // applies to icons up to this size
+	sharpen  float64 // unsharp mask amount
+	contrast float64 // contrast multiplier around mid-gray
+	alphaLo  uint8   // alpha at or below this becomes fully transparent
+	alphaHi  uint8   // alpha at or above this becomes fully opaque
+}
+
+// tunings are ordered by size; the smallest icons get the most aggressive treatment
+// because every pixel of blur is a large share of the icon
+var tunings = []tuning{
+	{maxSize: 24, sharpen: 0.9, contrast: 1.15, alphaLo: 64, alphaHi: 192},
+	{maxSize: 48, sharpen: 0.7, contrast: 1.1, alphaLo: 40, alphaHi: 215},
+	{maxSize: 64, sharpen: 0.5, contrast: 1.05, alphaLo: 16, alphaHi: 240},
+	{maxSize: 128, sharpen: 0.3, contrast: 1, alphaLo: 8, alphaHi: 248},
+}
+
+// Render returns the icon at size pixels. Below GlyphMaxSize the glyph variant is
+// used when there is one, and Optimize enables small-size post-processing.
+func (o Options) Render(src image.Image, size int) image.Image {
+	if o.Glyph != nil && size < GlyphMaxSize {
+		src = o.Glyph
+	}
+	if o.Optimize {
+		return Optimize(src, size)
+	}
+	return scale(src, size)
+}
+
+// Optimize downscales src to size pixels and counters the softness of downscaling:
+// an unsharp mask and contrast boost bring back edges, and alpha thresholding snaps
+// semi-transparent fringes to the pixel grid. Sizes above 128 are only scaled.
+func Optimize(src image.Image, size int) image.Image {
+	scaled := scale(src, size)
+
+	var t *tuning
+	for i := range tunings {
+		if size <= tunings[i].maxSize {
+			t = &tunings[i]
+			break
+		}
+	}
+	if t == nil {
+		return scaled
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Rect, scaled, scaled.Bounds().Min, draw.Src)
+
+	blurred := blur(img)
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+4]
+		if p[3] == 0 {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			v := float64(p[c])
+			v += t.sharpen * (v - float64(blurred.Pix[i+c]))
+			v = (v-128)*t.contrast + 128
+			p[c] = clamp(v)
+		}
+		p[3] = snapAlpha(p[3], t.alphaLo, t.alphaHi)
+	}
+	return img
+}
+
+// snapAlpha maps alpha at or below lo to 0 and at or above hi to 255, stretching
+// the range in between so anti-aliasing survives
+func snapAlpha(a, lo, hi uint8) uint8 {
+	switch {
+	case a <= lo:
+		return 0
+	case a >= hi:
+		return 255
+	}
+	return clamp(float64(a-lo) / float64(hi-lo) * 255)
+}
+
+// blur applies a 3x3 binomial blur. Colors are weighted by alpha so transparent
+// pixels don't darken the edges they border.
+func blur(img *image.NRGBA) *image.NRGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dst := image.NewNRGBA(img.Rect)
+	kernel := [3]float64{1, 2, 1}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [3]float64
+			var weight float64
+			for ky := -1; ky <= 1; ky++ {
+				for kx := -1; kx <= 1; kx++ {
+					sx := min(max(x+kx, 0), w-1)
+					sy := min(max(y+ky, 0), h-1)
+					p := img.Pix[img.PixOffset(sx, sy):][:4]
+					k := kernel[kx+1] * kernel[ky+1] * float64(p[3])
+					for c := 0; c < 3; c++ {
+						sum[c] += k * float64(p[c])
+					}
+					weight += k
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				if weight > 0 {
+					dst.Pix[i+c] = clamp(sum[c] / weight)
+				}
+			}
+			dst.Pix[i+3] = img.Pix[i+3]
+		}
+	}
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
@@ -0,0 +1,108 @@
+package iconset
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Contact sheet layout
+const (
+	sheetPadding = 12
+	sheetLabel   = 20  // height of the size label row
+	sheetZoom    = 128 // icons smaller than this are also shown magnified
+)
+
+// Contact sheet colors
+var (
+	sheetBackground = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+	sheetLight      = color.NRGBA{R: 0xf4, G: 0xf4, B: 0xf4, A: 0xff}
+	sheetDark       = color.NRGBA{R: 0x1a, G: 0x1a, B: 0x1a, A: 0xff}
+	sheetText       = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+// ContactSheet lays out icons side by side for review. Each column shows one icon at
+// actual size on a light and a dark background, and small icons magnified with
+// nearest-neighbor scaling so individual pixels are visible.
+func ContactSheet(icons []image.Image) *image.NRGBA {
+	rowHeight := 0
+	width := sheetPadding
+	for _, icon := range icons {
+		rowHeight = max(rowHeight, icon.Bounds().Dy())
+		width += max(icon.Bounds().Dx(), sheetZoom) + sheetPadding
+	}
+	height := sheetPadding + sheetLabel + 2*(rowHeight+sheetPadding) + sheetZoom + sheetPadding
+
+	sheet := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Rect, &image.Uniform{C: sheetBackground}, image.Point{}, draw.Src)
+
+	x := sheetPadding
+	for _, icon := range icons {
+		b := icon.Bounds()
+		column := max(b.Dx(), sheetZoom)
+		y := sheetPadding
+
+		drawLabel(sheet, x, y+sheetLabel-6, fmt.Sprintf("%dx%d", b.Dx(), b.Dy()))
+		y += sheetLabel
+
+		for _, bg := range []color.Color{sheetLight, sheetDark} {
+			cell := image.Rect(x, y, x+column, y+rowHeight)
+			draw.Draw(sheet, cell, &image.Uniform{C: bg}, image.Point{}, draw.Src)
+			at := image.Pt(x+(column-b.Dx())/2, y+(rowHeight-b.Dy())/2)
+			draw.Draw(sheet, image.Rectangle{Min: at, Max: at.Add(b.Size())}, icon, b.Min, draw.Over)
+			y += rowHeight + sheetPadding
+		}
+
+		if b.Dx() < sheetZoom {
+			zoom := sheetZoom / b.Dx()
+			size := b.Dx() * zoom
+			at := image.Pt(x+(column-size)/2, y+(sheetZoom-b.Dy()*zoom)/2)
+			checkerboard(sheet, image.Rectangle{Min: at, Max: at.Add(image.Pt(size, b.Dy()*zoom))})
+			magnify(sheet, at, icon, zoom)
+		}
+
+		x += column + sheetPadding
+	}
+	return sheet
+}
+
+// drawLabel draws text with its baseline at (x, y)
+func drawLabel(dst draw.Image, x, y int, text string) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  &image.Uniform{C: sheetText},
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// checkerboard fills r with the usual transparency pattern
+func checkerboard(dst draw.Image, r image.Rectangle) {
+	const cell = 8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := sheetLight
+			if ((x-r.Min.X)/cell+(y-r.Min.Y)/cell)%2 == 1 {
+				c = color.NRGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}
+			}
+			dst.Set(x, y, c)
+		}
+	}
+}
+
+// magnify draws src at an integer zoom factor with nearest-neighbor scaling
+func magnify(dst draw.Image, at image.Point, src image.Image, zoom int) {
+	b := src.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r := image.Rect(at.X+x*zoom, at.Y+y*zoom, at.X+(x+1)*zoom, at.Y+(y+1)*zoom)
+			draw.Draw(dst, r, &image.Uniform{C: src.At(b.Min.X+x, b.Min.Y+y)}, image.Point{}, draw.Over)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package inpaint
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Anchors lists where the original can sit on an extended canvas
+var Anchors = []string{"center", "left", "right", "top", "bottom", "top-left", "top-right", "bottom-left", "bottom-right"}
+
+// padColor fills the empty part of an extended canvas
+var padColor = color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+
+// Extension is where an image goes on a canvas extended to a new aspect ratio
+type Extension struct {
+	Canvas image.Rectangle // at the original's pixel scale, origin 0,0
+	Source image.Rectangle // where the original sits on the canvas
+}
+
+// Extend works out the smallest canvas with the given aspect ratio ("16:9") that
+// holds a width x height image, and where the anchor puts the image on it. The anchor
+// names the side the image stays against ("left" extends to the right).
+func Extend(width, height int, ratio, anchor string) (Extension, error) {
+	var rw, rh int
+	if _, err := fmt.Sscanf(ratio, "%d:%d", &rw, &rh); err != nil || rw <= 0 || rh <= 0 {
+		return Extension{}, fmt.Errorf("invalid aspect ratio: %s (use a ratio like 16:9)", ratio)
+	}
+	if !slices.Contains(Anchors, anchor) {
+		return Extension{}, fmt.Errorf("unsupported anchor: %s (use %s)", anchor, strings.Join(Anchors, ", "))
+	}
+
+	cw, ch := width, height
+	target := float64(rw) / float64(rh)
+	if float64(width)/float64(height) < target {
+		cw = int(math.Round(float64(height) * target))
+	} else {
+		ch = int(math.Round(float64(width) / target))
+	}
+	if cw <= width && ch <= height {
+		return Extension{}, fmt.Errorf("image is already %s; nothing to extend", ratio)
+	}
+
+	x, y := (cw-width)/2, (ch-height)/2
+	if strings.Contains(anchor, "left") {
+		x = 0
+	} else if strings.Contains(anchor, "right") {
+		x = cw - width
+	}
+	if strings.HasPrefix(anchor, "top") {
+		y = 0
+	} else if strings.HasPrefix(anchor, "bottom") {
+		y = ch - height
+	}
+	return Extension{
+		Canvas: image.Rect(0, 0, cw, ch),
+		Source: image.Rect(x, y, x+width, y+height),
+	}, nil
+}
+
+// Pad places img on the extended canvas with the new area filled flat gray
+func (e Extension) Pad(img image.Image) *image.NRGBA {
+	dst := image.NewNRGBA(e.Canvas)
+	draw.Draw(dst, dst.Rect, image.NewUniform(padColor), image.Point{}, draw.Src)
+	draw.Draw(dst, e.Source, img, img.Bounds().Min, draw.Src)
+	return dst
+}
+
+// Composite lays the original's pixels from the padded canvas back over the model's
+// output, which is scaled to the canvas. With feather the original fades into the
+// output over that many pixels inside its edges that border the new area; otherwise
+// its pixels are kept exactly.
+func (e Extension) Composite(output image.Image, padded *image.NRGBA, feather int) *image.NRGBA {
+	return Composite(output, padded, e.keep(feather))
+}
+
+// keep returns the mask of the original's area on the canvas, feathered
+func (e Extension) keep(feather int) *Mask {
+	w, h := e.Canvas.Dx(), e.Canvas.Dy()
+	m := &Mask{Width: w, Height: h, Alpha: make([]float64, w*h)}
+	for y := e.Source.Min.Y; y < e.Source.Max.Y; y++ {
+		for x := e.Source.Min.X; x < e.Source.Max.X; x++ {
+			m.Alpha[y*w+x] = 1
+		}
+	}
+	return m.Feather(feather)
+}
+
+// ExtendInstruction asks the model to fill the gray area of a padded canvas, with an
+// optional description of what belongs there
+func ExtendInstruction(hint string) string {
+	p := "This image was placed on a larger canvas and the flat gray area around it is empty. " +
+		"Fill the gray area by extending the scene naturally beyond its original edges, continuing the perspective, lighting, colors, textures and style seamlessly so there is no visible border. "
+	if hint = strings.TrimRight(strings.TrimSpace(hint), "."); hint != "" {
+		p += "In the new area: " + hint + ". "
+	}
+	return p + "Keep the existing part of the image exactly as it is, in the same position and size, and leave no gray."
+}
@@ -0,0 +1,96 @@
+package inpaint
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestExtend(t *testing.T) {
+	tests := []struct {
+		w, h          int
+		ratio, anchor string
+		canvas        image.Rectangle
+		source        image.Rectangle
+	}{
+		{100, 100, "16:9", "center", image.Rect(0, 0, 178, 100), image.Rect(39, 0, 139, 100)},
+		{100, 100, "16:9", "left", image.Rect(0, 0, 178, 100), image.Rect(0, 0, 100, 100)},
+		{100, 100, "16:9", "bottom-right", image.Rect(0, 0, 178, 100), image.Rect(78, 0, 178, 100)},
+		{160, 90, "1:1", "top", image.Rect(0, 0, 160, 160), image.Rect(0, 0, 160, 90)},
+		{160, 90, "1:1", "bottom", image.Rect(0, 0, 160, 160), image.Rect(0, 70, 160, 160)},
+	}
+	for _, tt := range tests {
+		e, err := Extend(tt.w, tt.h, tt.ratio, tt.anchor)
+		if err != nil {
+			t.Fatalf("%dx%d to %s: %v", tt.w, tt.h, tt.ratio, err)
+		}
+		if e.Canvas != tt.canvas || e.Source != tt.source {
+			t.Errorf("%dx%d to %s %s = %v %v, want %v %v", tt.w, tt.h, tt.ratio, tt.anchor, e.Canvas, e.Source, tt.canvas, tt.source)
+		}
+	}
+
+	if _, err := Extend(160, 90, "16:9", "center"); err == nil {
+		t.Error("extending to the same ratio should be an error")
+	}
+	if _, err := Extend(100, 100, "16:9", "middle"); err == nil {
+		t.Error("unknown anchors should be rejected")
+	}
+}
+
+func TestExtensionComposite(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 13)
+	}
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 255
+	}
+	e, err := Extend(40, 40, "2:1", "right")
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded := e.Pad(src)
+	if got := padded.NRGBAAt(0, 0); got != padColor {
+		t.Errorf("new area = %v, want the pad color", got)
+	}
+
+	// The model's output comes back at its own size
+	output := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for i := range output.Pix {
+		output.Pix[i] = 255
+	}
+
+	out := e.Composite(output, padded, 0)
+	if out.Bounds() != e.Canvas {
+		t.Fatalf("composite bounds = %v, want %v", out.Bounds(), e.Canvas)
+	}
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if got, want := out.NRGBAAt(x+40, y), src.NRGBAAt(x, y); got != want {
+				t.Fatalf("source pixel %d,%d = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if got := out.NRGBAAt(10, 10); got != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("new area = %v, want the model's output", got)
+	}
+
+	// Feathering only blends along the seam, not the image border
+	out = e.Composite(output, padded, 8)
+	if got := out.NRGBAAt(40, 20); got == src.NRGBAAt(0, 20) {
+		t.Error("feathered seam should blend")
+	}
+	if got, want := out.NRGBAAt(79, 20), src.NRGBAAt(39, 20); got != want {
+		t.Errorf("pixel at the image border = %v, want %v", got, want)
+	}
+}
+
+func TestExtendInstruction(t *testing.T) {
+	if p := ExtendInstruction("more beach."); !strings.Contains(p, "In the new area: more beach. ") {
+		t.Errorf("hint not embedded cleanly: %s", p)
+	}
+	if p := ExtendInstruction(""); strings.Contains(p, "In the new area") {
+		t.Errorf("no hint should leave it out: %s", p)
+	}
+}
@@ -0,0 +1,182 @@
+package inpaint
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// highlight is the tint marking the editable area on the annotated input
+var highlight = color.NRGBA{R: 255, G: 0, B: 255, A: 255}
+
+// Mask is how much of each pixel the edit may change, from 0 (keep the original) to 1
+type Mask struct {
+	Width, Height int
+	Alpha         []float64
+}
+
+// NewMask reads a black-and-white mask image, scaled to width x height. White marks
+// the area to edit; transparent pixels count as black.
+func NewMask(img image.Image, width, height int) (*Mask, error) {
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(scaled, scaled.Rect, img, img.Bounds(), draw.Src, nil)
+
+	m := &Mask{Width: width, Height: height, Alpha: make([]float64, width*height)}
+	found := false
+	for i := range m.Alpha {
+		p := scaled.Pix[i*4 : i*4+4]
+		luma := (0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])) / 255
+		m.Alpha[i] = luma * float64(p[3]) / 255
+		if m.Alpha[i] >= 0.5 {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("mask is empty: paint the area to edit white on black")
+	}
+	return m, nil
+}
+
+// Coverage returns the fraction of the image the mask lets the edit change
+func (m *Mask) Coverage() float64 {
+	n := 0
+	for _, a := range m.Alpha {
+		if a >= 0.5 {
+			n++
+		}
+	}
+	return float64(n) / float64(len(m.Alpha))
+}
+
+// Feather fades the mask in over radius pixels inside its edge, so the edit blends
+// into the original without touching a single pixel outside the mask
+func (m *Mask) Feather(radius int) *Mask {
+	if radius <= 0 {
+		return m
+	}
+	dist := insideDistance(m)
+	out := &Mask{Width: m.Width, Height: m.Height, Alpha: make([]float64, len(m.Alpha))}
+	for i, a := range m.Alpha {
+		out.Alpha[i] = a * min(1, dist[i]/float64(radius))
+	}
+	return out
+}
+
+// insideDistance returns, for each pixel inside the mask, the approximate distance
+// to the nearest pixel outside it (a two-pass chamfer transform); 0 outside. The
+// image border doesn't count as outside.
+func insideDistance(m *Mask) []float64 {
+	w, h := m.Width, m.Height
+	const straight, diagonal = 1.0, math.Sqrt2
+	inf := float64(w + h)
+	d := make([]float64, w*h)
+	for i, a := range m.Alpha {
+		if a >= 0.5 {
+			d[i] = inf
+		}
+	}
+	relax := func(x, y, dx, dy int, cost float64) {
+		nx, ny := x+dx, y+dy
+		if nx < 0 || ny < 0 || nx >= w || ny >= h {
+			return
+		}
+		if v := d[ny*w+nx] + cost; v < d[y*w+x] {
+			d[y*w+x] = v
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			relax(x, y, -1, 0, straight)
+			relax(x, y, 0, -1, straight)
+			relax(x, y, -1, -1, diagonal)
+			relax(x, y, 1, -1, diagonal)
+		}
+	}
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			relax(x, y, 1, 0, straight)
+			relax(x, y, 0, 1, straight)
+			relax(x, y, 1, 1, diagonal)
+			relax(x, y, -1, 1, diagonal)
+		}
+	}
+	return d
+}
+
+// Annotate returns a copy of img with the masked area tinted and outlined in magenta,
+// to show the model where to edit
+func Annotate(img image.Image, m *Mask) *image.NRGBA {
+	dst := toNRGBA(img, m.Width, m.Height)
+	inside := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < m.Width && y < m.Height && m.Alpha[y*m.Width+x] >= 0.5
+	}
+	// Outlines stay visible on large images
+	thickness := max(2, max(m.Width, m.Height)/300)
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if !inside(x, y) {
+				continue
+			}
+			tint := 0.45
+			if !inside(x-thickness, y) || !inside(x+thickness, y) || !inside(x, y-thickness) || !inside(x, y+thickness) {
+				tint = 1
+			}
+			p := dst.Pix[dst.PixOffset(x, y):]
+			p[0] = blend(p[0], highlight.R, tint)
+			p[1] = blend(p[1], highlight.G, tint)
+			p[2] = blend(p[2], highlight.B, tint)
+			p[3] = 255
+		}
+	}
+	return dst
+}
+
+// Composite puts the edit back onto the original through the mask. Both are scaled
+// to the mask's size if needed; wherever the mask is 0 the original's pixels are
+// kept exactly.
+func Composite(original, edited image.Image, m *Mask) *image.NRGBA {
+	dst := toNRGBA(original, m.Width, m.Height)
+	scaled := toNRGBA(edited, m.Width, m.Height)
+
+	for i, a := range m.Alpha {
+		if a <= 0 {
+			continue
+		}
+		o := dst.Pix[i*4 : i*4+4]
+		e := scaled.Pix[i*4 : i*4+4]
+		for c := range 4 {
+			o[c] = blend(o[c], e[c], a)
+		}
+	}
+	return dst
+}
+
+// Instruction wraps an edit instruction with directions for the annotated input,
+// which follows the image being edited
+func Instruction(instruction string) string {
+	return "The first image is the image to edit. The second image is the same image with the area you may change tinted and outlined in magenta. " +
+		"Apply this edit only inside the magenta area: " + strings.TrimRight(strings.TrimSpace(instruction), ".") + ". " +
+		"Keep everything outside that area exactly as it is in the first image, blend the edit naturally into its surroundings, " +
+		"and do not draw any magenta tint or outline in the result. Keep the same framing and size as the first image."
+}
+
+// toNRGBA returns a copy of img as an NRGBA of the given size at origin 0,0
+func toNRGBA(img image.Image, width, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	b := img.Bounds()
+	if b.Dx() == width && b.Dy() == height {
+		draw.Draw(dst, dst.Rect, img, b.Min, draw.Src)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Rect, img, b, draw.Src, nil)
+	}
+	return dst
+}
+
+// blend mixes a towards b by t
+func blend(a, b uint8, t float64) uint8 {
+	return uint8(math.Round(float64(a)*(1-t) + float64(b)*t))
+}
@@ -0,0 +1,97 @@
+package inpaint
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// square returns a w x h image of one color with a white square from lo to hi
+func square(w, h, lo, hi int, background color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := background
+			if x >= lo && x < hi && y >= lo && y < hi {
+				c = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestNewMask(t *testing.T) {
+	// A mask at half size is scaled up to the image
+	m, err := NewMask(square(20, 20, 5, 15, color.NRGBA{A: 255}), 40, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Coverage(); got < 0.2 || got > 0.3 {
+		t.Errorf("coverage = %.2f, want about 0.25", got)
+	}
+	if _, err := NewMask(image.NewNRGBA(image.Rect(0, 0, 4, 4)), 4, 4); err == nil {
+		t.Error("an all-black (or transparent) mask should be rejected")
+	}
+}
+
+func TestCompositeKeepsOriginalOutsideMask(t *testing.T) {
+	original := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := range original.Pix {
+		original.Pix[i] = uint8(i * 7)
+	}
+	for i := 3; i < len(original.Pix); i += 4 {
+		original.Pix[i] = 255
+	}
+	// The edit comes back at a different size, as model output does
+	edited := square(64, 64, 0, 64, color.NRGBA{})
+
+	mask, err := NewMask(square(40, 40, 10, 30, color.NRGBA{A: 255}), 40, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := Composite(original, edited, mask.Feather(4))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			inside := x >= 10 && x < 30 && y >= 10 && y < 30
+			got, want := out.NRGBAAt(x, y), original.NRGBAAt(x, y)
+			if !inside && got != want {
+				t.Fatalf("pixel %d,%d outside the mask changed: %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	// The feather ramps in from the mask edge to the full edit
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	if got := out.NRGBAAt(10, 20); got == original.NRGBAAt(10, 20) || got == white {
+		t.Errorf("feathered edge = %v, want a blend", got)
+	}
+	if got := out.NRGBAAt(15, 20); got != white {
+		t.Errorf("past the feather = %v, want the edit", got)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	img := square(30, 30, 0, 0, color.NRGBA{R: 0, G: 128, B: 0, A: 255})
+	mask, err := NewMask(square(30, 30, 10, 20, color.NRGBA{A: 255}), 30, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := Annotate(img, mask)
+	if got := out.NRGBAAt(2, 2); got != img.NRGBAAt(2, 2) {
+		t.Errorf("outside the mask = %v, want unchanged", got)
+	}
+	if got := out.NRGBAAt(10, 15); got != highlight {
+		t.Errorf("mask edge = %v, want the outline color", got)
+	}
+	if got := out.NRGBAAt(15, 15); got.R == 0 || got == highlight {
+		t.Errorf("mask inside = %v, want a tint", got)
+	}
+}
+
+func TestInstruction(t *testing.T) {
+	p := Instruction("remove the person on the left.")
+	if !strings.Contains(p, "magenta area: remove the person on the left. Keep") {
+		t.Errorf("instruction not embedded cleanly: %s", p)
+	}
+}
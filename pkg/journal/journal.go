@@ -0,0 +1,99 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is one finished piece of work
+type Entry struct {
+	Key    string    `json:"key"`
+	Output string    `json:"output,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Journal records finished work in a JSON-lines file so an interrupted batch can
+// resume where it stopped. It is safe for concurrent use.
+type Journal struct {
+	path string
+	mu   sync.Mutex
+	done map[string]Entry
+}
+
+// Open loads the journal at path, or starts an empty one if it doesn't exist yet
+func Open(path string) (*Journal, error) {
+	j := &Journal{path: path, done: map[string]Entry{}}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		// A line cut short by a crash is skipped; its work is simply redone
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry.Key != "" {
+			j.done[entry.Key] = entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return j, nil
+}
+
+// Done reports whether key was finished, returning its entry
+func (j *Journal) Done(key string) (Entry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.done[key]
+	return entry, ok
+}
+
+// Mark records key as finished, with the file it produced
+func (j *Journal) Mark(key, output string) error {
+	entry := Entry{Key: key, Output: output, Time: time.Now().UTC()}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.done[key] = entry
+	return nil
+}
+
+// Reset forgets every entry, for a run that starts over
+func (j *Journal) Reset() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to reset journal: %w", err)
+	}
+	j.done = map[string]Entry{}
+	return nil
+}
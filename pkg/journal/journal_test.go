@@ -0,0 +1,51 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "journal.jsonl")
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Mark("a.png", "out/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Mark("b.png", "out/b.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash can leave half a line behind
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"key":"c.p`)
+	_ = f.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := j.Done("b.png"); !ok || entry.Output != "out/b.png" {
+		t.Errorf("b.png = %+v, %v", entry, ok)
+	}
+	if _, ok := j.Done("c.png"); ok {
+		t.Error("a truncated entry should not count as done")
+	}
+
+	if err := j.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j.Done("a.png"); ok {
+		t.Error("Reset should forget entries")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Reset should remove the file")
+	}
+}
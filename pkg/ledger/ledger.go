@@ -0,0 +1,233 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Entry records a single successful API call
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Command          string    `json:"command"`
+	Model            string    `json:"model"`
+	Resolution       string    `json:"resolution,omitempty"`
+	AspectRatio      string    `json:"aspectRatio,omitempty"`
+	Images           int       `json:"images"`
+	InputImages      int       `json:"inputImages,omitempty"`
+	PromptTokens     int       `json:"promptTokens"`
+	CandidatesTokens int       `json:"candidatesTokens"`
+	TotalTokens      int       `json:"totalTokens"`
+	EstimatedCost    float64   `json:"estimatedCost"`
+	Project          string    `json:"project,omitempty"`
+}
+
+// Supported summary groupings
+const (
+	GroupByDay     = "day"
+	GroupByModel   = "model"
+	GroupByProject = "project"
+	GroupByCommand = "command"
+)
+
+// Groupings lists the supported summary groupings in display order
+var Groupings = []string{GroupByDay, GroupByModel, GroupByProject, GroupByCommand}
+
+// Row is one line of a ledger summary
+type Row struct {
+	Key           string  `json:"key"`
+	Calls         int     `json:"calls"`
+	Images        int     `json:"images"`
+	TotalTokens   int     `json:"totalTokens"`
+	EstimatedCost float64 `json:"estimatedCost"`
+}
+
+// DefaultPath returns the ledger location: $IMAGEMAGE_LEDGER if set,
+// otherwise imagemage/ledger.jsonl in the user's config directory
+func DefaultPath() (string, error) {
+	if path := os.Getenv("IMAGEMAGE_LEDGER"); path != "" {
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(configDir, "imagemage", "ledger.jsonl"), nil
+}
+
+// Append adds an entry to the ledger file, creating it if needed
+func Append(path string, entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger entry: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Load reads all entries from the ledger file. A missing ledger is empty, not an error.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid ledger entry on line %d: %w", lineNum, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Filter returns the entries at or after since (zero means no lower bound) matching project (empty means any)
+func Filter(entries []Entry, since time.Time, project string) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if !since.IsZero() && e.Time.Before(since) {
+			continue
+		}
+		if project != "" && e.Project != project {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Summarize groups entries by the given key and totals each group.
+// Days are sorted chronologically; other groupings by descending cost.
+func Summarize(entries []Entry, groupBy string) ([]Row, error) {
+	keyFn, err := groupKey(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := map[string]*Row{}
+	for _, e := range entries {
+		key := keyFn(e)
+		row, ok := rows[key]
+		if !ok {
+			row = &Row{Key: key}
+			rows[key] = row
+		}
+		row.Calls++
+		row.Images += e.Images
+		row.TotalTokens += e.TotalTokens
+		row.EstimatedCost += e.EstimatedCost
+	}
+
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if groupBy == GroupByDay || out[i].EstimatedCost == out[j].EstimatedCost {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].EstimatedCost > out[j].EstimatedCost
+	})
+
+	return out, nil
+}
+
+// groupKey returns the function extracting the grouping key from an entry
+func groupKey(groupBy string) (func(Entry) string, error) {
+	switch groupBy {
+	case GroupByDay:
+		return func(e Entry) string { return e.Time.Local().Format("2006-01-02") }, nil
+	case GroupByModel:
+		return func(e Entry) string { return e.Model }, nil
+	case GroupByProject:
+		return func(e Entry) string {
+			if e.Project == "" {
+				return "(untagged)"
+			}
+			return e.Project
+		}, nil
+	case GroupByCommand:
+		return func(e Entry) string { return e.Command }, nil
+	default:
+		return nil, fmt.Errorf("unsupported grouping: %s (use day, model, project or command)", groupBy)
+	}
+}
+
+// WriteRowsCSV writes summary rows as CSV with a header named after the grouping
+func WriteRowsCSV(w io.Writer, groupBy string, rows []Row) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{groupBy, "calls", "images", "total_tokens", "estimated_cost_usd"})
+	for _, r := range rows {
+		_ = cw.Write([]string{
+			r.Key,
+			strconv.Itoa(r.Calls),
+			strconv.Itoa(r.Images),
+			strconv.Itoa(r.TotalTokens),
+			strconv.FormatFloat(r.EstimatedCost, 'f', 4, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteEntriesCSV writes raw ledger entries as CSV
+func WriteEntriesCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "command", "model", "resolution", "aspect_ratio", "images", "input_images", "prompt_tokens", "candidates_tokens", "total_tokens", "estimated_cost_usd", "project"})
+	for _, e := range entries {
+		_ = cw.Write([]string{
+			e.Time.Format(time.RFC3339),
+			e.Command,
+			e.Model,
+			e.Resolution,
+			e.AspectRatio,
+			strconv.Itoa(e.Images),
+			strconv.Itoa(e.InputImages),
+			strconv.Itoa(e.PromptTokens),
+			strconv.Itoa(e.CandidatesTokens),
+			strconv.Itoa(e.TotalTokens),
+			strconv.FormatFloat(e.EstimatedCost, 'f', 4, 64),
+			e.Project,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
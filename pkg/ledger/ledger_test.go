@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "ledger.jsonl")
+
+	entries, err := Load(path)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected empty ledger for missing file, got %v, %v", entries, err)
+	}
+
+	want := Entry{
+		Time:          time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Command:       "generate",
+		Model:         "gemini-3-pro-image-preview",
+		Resolution:    "4K",
+		Images:        1,
+		TotalTokens:   1300,
+		EstimatedCost: 0.24,
+		Project:       "website",
+	}
+	for i := 0; i < 2; i++ {
+		if err := Append(path, want); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	entries, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[1] != want {
+		t.Errorf("entry mismatch:\n got %+v\nwant %+v", entries[1], want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+	entries := []Entry{
+		{Time: day2, Command: "generate", Model: "pro", Images: 1, TotalTokens: 100, EstimatedCost: 0.24, Project: "a"},
+		{Time: day1, Command: "icon", Model: "flash", Images: 1, TotalTokens: 50, EstimatedCost: 0.04},
+		{Time: day1, Command: "generate", Model: "pro", Images: 1, TotalTokens: 100, EstimatedCost: 0.24, Project: "a"},
+	}
+
+	byDay, err := Summarize(entries, GroupByDay)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(byDay) != 2 || byDay[0].Key != "2026-03-01" || byDay[0].Calls != 2 {
+		t.Errorf("unexpected day summary: %+v", byDay)
+	}
+
+	byProject, _ := Summarize(entries, GroupByProject)
+	if byProject[0].Key != "a" || byProject[0].Images != 2 || byProject[1].Key != "(untagged)" {
+		t.Errorf("unexpected project summary: %+v", byProject)
+	}
+
+	if _, err := Summarize(entries, "color"); err == nil {
+		t.Error("expected error for unsupported grouping")
+	}
+
+	filtered := Filter(entries, day2, "a")
+	if len(filtered) != 1 {
+		t.Errorf("expected 1 entry after filter, got %d", len(filtered))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRowsCSV(&buf, GroupByModel, mustSummarize(t, entries, GroupByModel)); err != nil {
+		t.Fatalf("WriteRowsCSV failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "model,calls,images,total_tokens,estimated_cost_usd\npro,2,2,200,0.4800\n") {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+}
+
+func mustSummarize(t *testing.T, entries []Entry, groupBy string) []Row {
+	t.Helper()
+	rows, err := Summarize(entries, groupBy)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	return rows
+}
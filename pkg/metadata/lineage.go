@@ -0,0 +1,32 @@
+package metadata
+
+// Lineage records which image a variation was made from
+type Lineage struct {
+	Parent   string // path of the parent image, relative to the variation
+	Strength string // how far the variation was allowed to stray
+}
+
+// AddLineageToPNGData adds a variation's lineage as tEXt chunks to in-memory PNG data
+func AddLineageToPNGData(data []byte, lineage Lineage) ([]byte, error) {
+	data, err := AddTextToPNGData(data, "Parent", lineage.Parent)
+	if err != nil {
+		return nil, err
+	}
+	if lineage.Strength == "" {
+		return data, nil
+	}
+	return AddTextToPNGData(data, "Variation", lineage.Strength)
+}
+
+// ReadLineageFromPNG reads a variation's lineage from a PNG file's tEXt chunks
+func ReadLineageFromPNG(filepath string) (Lineage, error) {
+	parent, err := ReadTextFromPNG(filepath, "Parent")
+	if err != nil {
+		return Lineage{}, err
+	}
+	strength, err := ReadTextFromPNG(filepath, "Variation")
+	if err != nil {
+		strength = ""
+	}
+	return Lineage{Parent: parent, Strength: strength}, nil
+}
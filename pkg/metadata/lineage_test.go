@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLineageRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	data, err := AddPromptToPNGData(buf.Bytes(), "a red fox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = AddLineageToPNGData(data, Lineage{Parent: "../fox.png", Strength: "high"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("PNG no longer decodes: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "fox_var1.png")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	lineage, err := ReadLineageFromPNG(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lineage != (Lineage{Parent: "../fox.png", Strength: "high"}) {
+		t.Errorf("lineage = %+v", lineage)
+	}
+	if prompt, err := ReadPromptFromPNG(path); err != nil || prompt != "a red fox" {
+		t.Errorf("prompt = %q, %v", prompt, err)
+	}
+
+	plain := filepath.Join(t.TempDir(), "fox.png")
+	if err := os.WriteFile(plain, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLineageFromPNG(plain); err == nil {
+		t.Error("expected an error for an image without lineage")
+	}
+}
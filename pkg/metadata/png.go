@@ -20,36 +20,51 @@ func AddPromptToPNG(filepath string, prompt string) error {
 		return fmt.Errorf("failed to read image file: %w", err)
 	}
 
-	// Check if it's a PNG file (first 8 bytes are PNG signature)
-	isPNG := len(data) >= 8 && bytes.Equal(data[:8], []byte{137, 80, 78, 71, 13, 10, 26, 10})
+	newData, err := AddPromptToPNGData(data, prompt)
+	if err != nil {
+		return err
+	}
 
+	// Write the modified PNG
+	if err := os.WriteFile(filepath, newData, 0644); err != nil {
+		return fmt.Errorf("failed to write PNG file: %w", err)
+	}
+
+	return nil
+}
+
+// AddPromptToPNGData adds a prompt as a tEXt chunk to in-memory PNG data
+// If the data is JPEG, it will be converted to PNG first
+func AddPromptToPNGData(data []byte, prompt string) ([]byte, error) {
+	return AddTextToPNGData(data, "Prompt", prompt)
+}
+
+// AddTextToPNGData adds a keyword and its text as a tEXt chunk to in-memory PNG data
+// If the data is JPEG, it will be converted to PNG first
+func AddTextToPNGData(data []byte, keyword, text string) ([]byte, error) {
 	// If it's not PNG, try to convert from JPEG
-	if !isPNG {
+	if !isPNGData(data) {
 		// Check if it's JPEG
 		isJPEG := len(data) >= 2 && bytes.Equal(data[:2], []byte{0xFF, 0xD8})
 		if !isJPEG {
-			return fmt.Errorf("file is neither PNG nor JPEG format")
+			return nil, fmt.Errorf("file is neither PNG nor JPEG format")
 		}
 
 		// Convert JPEG to PNG
-		if err := convertJPEGToPNG(filepath); err != nil {
-			return fmt.Errorf("failed to convert JPEG to PNG: %w", err)
-		}
-
-		// Re-read the now-PNG file
-		data, err = os.ReadFile(filepath)
+		converted, err := convertJPEGDataToPNG(data)
 		if err != nil {
-			return fmt.Errorf("failed to read converted PNG file: %w", err)
+			return nil, fmt.Errorf("failed to convert JPEG to PNG: %w", err)
 		}
+		data = converted
 	}
 
-	// Create tEXt chunk with prompt
-	textChunk := createTextChunk("Prompt", prompt)
+	// Create tEXt chunk with the text
+	textChunk := createTextChunk(keyword, text)
 
 	// Find the position to insert (before IEND chunk)
 	// IEND is the last chunk and is always 12 bytes: 4(length) + 4(type) + 0(data) + 4(CRC)
 	if len(data) < 12 {
-		return fmt.Errorf("PNG file too short")
+		return nil, fmt.Errorf("PNG file too short")
 	}
 
 	// Insert the tEXt chunk before IEND
@@ -59,12 +74,12 @@ func AddPromptToPNG(filepath string, prompt string) error {
 	newData = append(newData, textChunk...)
 	newData = append(newData, data[insertPos:]...)
 
-	// Write the modified PNG
-	if err := os.WriteFile(filepath, newData, 0644); err != nil {
-		return fmt.Errorf("failed to write PNG file: %w", err)
-	}
+	return newData, nil
+}
 
-	return nil
+// isPNGData checks for the 8-byte PNG signature
+func isPNGData(data []byte) bool {
+	return len(data) >= 8 && bytes.Equal(data[:8], []byte{137, 80, 78, 71, 13, 10, 26, 10})
 }
 
 // createTextChunk creates a PNG tEXt chunk
@@ -97,6 +112,11 @@ func createTextChunk(keyword, text string) []byte {
 
 // ReadPromptFromPNG reads the prompt from a PNG file's tEXt chunks
 func ReadPromptFromPNG(filepath string) (string, error) {
+	return ReadTextFromPNG(filepath, "Prompt")
+}
+
+// ReadTextFromPNG reads the text stored under keyword in a PNG file's tEXt chunks
+func ReadTextFromPNG(filepath, keyword string) (string, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open PNG file: %w", err)
@@ -112,13 +132,13 @@ func ReadPromptFromPNG(filepath string) (string, error) {
 		return "", fmt.Errorf("not a valid PNG file")
 	}
 
-	// Read chunks until we find tEXt with "Prompt" keyword
+	// Read chunks until we find tEXt with the keyword
 	for {
 		// Read chunk length
 		var length uint32
 		if err := binary.Read(file, binary.BigEndian, &length); err != nil {
 			if err == io.EOF {
-				return "", fmt.Errorf("no Prompt metadata found in PNG")
+				return "", fmt.Errorf("no %s metadata found in PNG", keyword)
 			}
 			return "", err
 		}
@@ -141,13 +161,12 @@ func ReadPromptFromPNG(filepath string) (string, error) {
 			return "", err
 		}
 
-		// Check if this is a tEXt chunk with "Prompt" keyword
+		// Check if this is a tEXt chunk with the keyword
 		if string(chunkType) == "tEXt" {
 			// Find null separator
 			nullPos := bytes.IndexByte(chunkData, 0)
 			if nullPos > 0 {
-				keyword := string(chunkData[:nullPos])
-				if keyword == "Prompt" {
+				if string(chunkData[:nullPos]) == keyword {
 					text := string(chunkData[nullPos+1:])
 					return text, nil
 				}
@@ -156,37 +175,22 @@ func ReadPromptFromPNG(filepath string) (string, error) {
 
 		// IEND chunk means we've reached the end
 		if string(chunkType) == "IEND" {
-			return "", fmt.Errorf("no Prompt metadata found in PNG")
+			return "", fmt.Errorf("no %s metadata found in PNG", keyword)
 		}
 	}
 }
 
-// convertJPEGToPNG converts a JPEG file to PNG format in-place
-func convertJPEGToPNG(filepath string) error {
-	// Open and decode JPEG
-	file, err := os.Open(filepath)
-	if err != nil {
-		return err
-	}
-
-	img, err := jpeg.Decode(file)
-	if cerr := file.Close(); cerr != nil {
-		return cerr
-	}
-	if err != nil {
-		return fmt.Errorf("failed to decode JPEG: %w", err)
-	}
-
-	// Encode as PNG to the same path
-	outFile, err := os.Create(filepath)
+// convertJPEGDataToPNG re-encodes JPEG data as PNG
+func convertJPEGDataToPNG(data []byte) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to decode JPEG: %w", err)
 	}
 
-	if err := png.Encode(outFile, img); err != nil {
-		_ = outFile.Close()
-		return fmt.Errorf("failed to encode PNG: %w", err)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
 	}
 
-	return outFile.Close()
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,236 @@
+package restore
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// imageExtensions are the input formats a batch picks up from directories and globs
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true, ".gif": true}
+
+// Job is one photo in a batch
+type Job struct {
+	Input string // path to the photo
+	Rel   string // path relative to the directory or glob it came from, mirrored into the output directory
+}
+
+// IsBatch reports whether the arguments name more than a single photo: several
+// paths, a directory or a glob
+func IsBatch(args []string) bool {
+	if len(args) != 1 {
+		return true
+	}
+	if hasGlob(args[0]) {
+		return true
+	}
+	info, err := os.Stat(args[0])
+	return err == nil && info.IsDir()
+}
+
+// Expand turns files, directories and globs into jobs. Directories contribute the
+// images directly inside them, or their whole tree with recursive. Anything under
+// exclude (usually the output directory) is skipped so reruns don't restore
+// restorations.
+func Expand(args []string, recursive bool, exclude string) ([]Job, error) {
+	var jobs []Job
+	seen := map[string]string{} // Rel -> Input
+	add := func(input, rel string) error {
+		if exclude != "" && within(input, exclude) {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if prev, ok := seen[rel]; ok {
+			if filepath.Clean(prev) == filepath.Clean(input) {
+				return nil
+			}
+			return fmt.Errorf("%s and %s would both be written to %s; restore them separately", prev, input, rel)
+		}
+		seen[rel] = input
+		jobs = append(jobs, Job{Input: input, Rel: rel})
+		return nil
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		root := ""
+		if hasGlob(arg) {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+			root = globRoot(arg)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("image not found: %s", match)
+			}
+			if !info.IsDir() {
+				if root != "" && !isImage(match) {
+					continue
+				}
+				rel := filepath.Base(match)
+				if root != "" {
+					rel, _ = filepath.Rel(root, match)
+				}
+				if err := add(match, rel); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			// A directory matched by a glob keeps its name under the glob's root
+			prefix := ""
+			if root != "" {
+				prefix, _ = filepath.Rel(root, match)
+			}
+			err = filepath.WalkDir(match, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if p != match && (!recursive || strings.HasPrefix(d.Name(), ".")) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !isImage(p) {
+					return nil
+				}
+				rel, _ := filepath.Rel(match, p)
+				return add(p, filepath.Join(prefix, rel))
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read directory %s: %w", match, err)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// hasGlob reports whether a path contains glob metacharacters
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// globRoot returns the directory part of a glob before its first wildcard
+func globRoot(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for hasGlob(dir) {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// isImage reports whether a path has a supported image extension
+func isImage(p string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(p))]
+}
+
+// within reports whether p is dir or inside it
+func within(p, dir string) bool {
+	absP, err1 := filepath.Abs(p)
+	absDir, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absP)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// compareGap is the white gap between the two halves of a comparison
+const compareGap = 16
+
+// Compare puts the original and the restoration side by side at the restoration's
+// height, original on the left
+func Compare(before, after image.Image) *image.NRGBA {
+	ab := after.Bounds()
+	bb := before.Bounds()
+	h := ab.Dy()
+	w := max(1, bb.Dx()*h/max(1, bb.Dy()))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w+compareGap+ab.Dx(), h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, image.Rect(0, 0, w, h), before, bb, draw.Over, nil)
+	draw.Draw(dst, image.Rect(w+compareGap, 0, dst.Bounds().Dx(), h), after, ab.Min, draw.Over)
+	return dst
+}
+
+// IndexEntry is one photo on the review page. Paths are relative to the page.
+type IndexEntry struct {
+	Name    string
+	Before  string
+	After   string
+	Compare string // optional
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; background: #f4f4f4; color: #222; }
+figure { margin: 0 0 2rem; padding: 1rem; background: #fff; border-radius: 6px; }
+figcaption { font-weight: 600; margin-bottom: .5rem; }
+.pair { display: flex; gap: 1rem; }
+.pair a { flex: 1; }
+img { width: 100%; height: auto; display: block; }
+.label { font-size: .8rem; color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Entries}} photos</p>
+{{range .Entries}}<figure>
+<figcaption>{{.Name}}</figcaption>
+<div class="pair">
+<a href="{{.Before}}"><span class="label">Before</span><img src="{{.Before}}" loading="lazy" alt="{{.Name}} before"></a>
+<a href="{{.After}}"><span class="label">After</span><img src="{{.After}}" loading="lazy" alt="{{.Name}} after"></a>
+</div>
+{{if .Compare}}<p class="label"><a href="{{.Compare}}">Side by side</a></p>{{end}}
+</figure>
+{{end}}</body>
+</html>
+`))
+
+// WriteIndex writes an HTML page showing each photo before and after restoration
+func WriteIndex(w io.Writer, title string, entries []IndexEntry) error {
+	return indexTemplate.Execute(w, struct {
+		Title   string
+		Entries []IndexEntry
+	}{title, entries})
+}
+
+// IndexLink returns the page-relative URL of target for an index written in dir
+func IndexLink(dir, target string) string {
+	absDir, err1 := filepath.Abs(dir)
+	absTarget, err2 := filepath.Abs(target)
+	if err1 != nil || err2 != nil {
+		return filepath.ToSlash(target)
+	}
+	rel, err := filepath.Rel(absDir, absTarget)
+	if err != nil {
+		return filepath.ToSlash(absTarget)
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
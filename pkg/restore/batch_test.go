@@ -0,0 +1,104 @@
+package restore
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// touch creates an empty file and its directories
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"a.jpg", "notes.txt", "1950s/b.PNG", "1950s/deep/c.png", ".cache/d.png", "out/a.jpg"} {
+		touch(t, filepath.Join(dir, p))
+	}
+	rels := func(jobs []Job) []string {
+		var r []string
+		for _, j := range jobs {
+			r = append(r, j.Rel)
+		}
+		return r
+	}
+
+	jobs, err := Expand([]string{dir}, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rels(jobs), []string{"a.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flat = %q, want %q", got, want)
+	}
+
+	jobs, err = Expand([]string{dir}, true, filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rels(jobs), []string{"1950s/b.PNG", "1950s/deep/c.png", "a.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recursive = %q, want %q", got, want)
+	}
+
+	// Directories matched by a glob are expanded like directory arguments
+	jobs, err = Expand([]string{filepath.Join(dir, "*", "*")}, false, filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rels(jobs), []string{".cache/d.png", "1950s/b.PNG", "1950s/deep/c.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("glob = %q, want %q", got, want)
+	}
+
+	if _, err := Expand([]string{filepath.Join(dir, "a.jpg"), filepath.Join(dir, "out", "a.jpg")}, false, ""); err == nil {
+		t.Error("two photos with the same output should be rejected")
+	}
+	if _, err := Expand([]string{filepath.Join(dir, "*.gif")}, false, ""); err == nil {
+		t.Error("a glob matching nothing should be an error")
+	}
+}
+
+func TestIsBatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.png")
+	touch(t, file)
+	for args, want := range map[string]bool{file: false, dir: true, dir + "/*.png": true, file + " " + file: true} {
+		if got := IsBatch(strings.Fields(args)); got != want {
+			t.Errorf("IsBatch(%s) = %v, want %v", args, got, want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := image.NewNRGBA(image.Rect(0, 0, 50, 100))
+	after := image.NewNRGBA(image.Rect(0, 0, 100, 200))
+	if got := Compare(before, after).Bounds().Size(); got != image.Pt(100+compareGap+100, 200) {
+		t.Errorf("comparison size = %v", got)
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	if got := IndexLink("restored", "scans/old photo.jpg"); got != "../scans/old%20photo.jpg" {
+		t.Errorf("IndexLink = %q", got)
+	}
+
+	var page bytes.Buffer
+	err := WriteIndex(&page, "Restored photos", []IndexEntry{{Name: "<a>.jpg", Before: "../a.jpg", After: "a.jpg", Compare: "a_compare.jpg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`src="../a.jpg"`, `href="a_compare.jpg"`, "&lt;a&gt;.jpg"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("index missing %s", want)
+		}
+	}
+}
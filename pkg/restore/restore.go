@@ -0,0 +1,96 @@
+package restore
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Restoration modes, combinable
+const (
+	ModeColorize = "colorize"
+	ModeRepair   = "repair"
+	ModeDenoise  = "denoise"
+	ModeDeblur   = "deblur"
+	ModeFace     = "face"
+	ModeUpscale  = "upscale"
+)
+
+// Modes lists the restoration modes in the order their instructions are given
+var Modes = []string{ModeRepair, ModeDenoise, ModeDeblur, ModeColorize, ModeFace, ModeUpscale}
+
+// Restoration strengths
+const (
+	StrengthSubtle   = "subtle"
+	StrengthModerate = "moderate"
+	StrengthStrong   = "strong"
+)
+
+// modeInstructions tells the model what each mode does
+var modeInstructions = map[string]string{
+	ModeRepair:   "Repair physical damage: remove scratches, dust, creases, tears, stains and fading, and rebuild missing or torn areas from the surrounding detail.",
+	ModeDenoise:  "Remove film grain, sensor noise and compression artifacts while keeping genuine texture and fine detail.",
+	ModeDeblur:   "Correct motion and focus blur so edges and fine detail are crisp, without halos or oversharpening.",
+	ModeColorize: "Colorize this photo with natural, believable colors for skin, clothing, sky, foliage and materials.",
+	ModeFace:     "Enhance every face: restore natural detail in eyes, skin and hair while keeping each person's identity, age, expression and features exactly the same.",
+	ModeUpscale:  "Increase resolution and detail as if rescanned from the original at much higher quality, with crisp fine detail and no invented objects.",
+}
+
+// strengthInstructions sets how far the model may go
+var strengthInstructions = map[string]string{
+	StrengthSubtle:   "Keep every change subtle and conservative; when in doubt, leave the original as it is.",
+	StrengthModerate: "Apply a balanced restoration that looks natural.",
+	StrengthStrong:   "Apply a thorough restoration, fully reconstructing heavily damaged or degraded areas.",
+}
+
+// ParseModes parses a comma-separated mode list. An empty list means a general
+// all-round restoration.
+func ParseModes(s string) ([]string, error) {
+	var modes []string
+	for _, m := range strings.Split(s, ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if m == "" {
+			continue
+		}
+		if !slices.Contains(Modes, m) {
+			return nil, fmt.Errorf("unsupported restore mode: %s (use %s)", m, strings.Join(Modes, ", "))
+		}
+		if !slices.Contains(modes, m) {
+			modes = append(modes, m)
+		}
+	}
+	// Instructions go in a fixed order however the modes were listed
+	slices.SortFunc(modes, func(a, b string) int { return slices.Index(Modes, a) - slices.Index(Modes, b) })
+	return modes, nil
+}
+
+// ValidateStrength checks a --strength value
+func ValidateStrength(strength string) error {
+	if _, ok := strengthInstructions[strength]; !ok {
+		return fmt.Errorf("unsupported strength: %s (use subtle, moderate or strong)", strength)
+	}
+	return nil
+}
+
+// Prompt builds the restoration instruction for the given modes, strength and, for
+// colorization, era
+func Prompt(modes []string, strength, era string) string {
+	var parts []string
+	if len(modes) == 0 {
+		parts = append(parts, "Restore and enhance this photo. Remove noise, improve clarity, fix any damage or artifacts, enhance colors naturally, and improve overall quality.")
+	} else {
+		parts = append(parts, "Restore this photo.")
+		for _, m := range modes {
+			parts = append(parts, modeInstructions[m])
+			if m == ModeColorize && era != "" {
+				parts = append(parts, fmt.Sprintf("The photo was taken in this era: %s. Keep clothing, vehicles, signage, interiors and the overall color palette true to it.", era))
+			}
+		}
+	}
+	parts = append(parts, strengthInstructions[strength])
+	if !slices.Contains(modes, ModeColorize) && len(modes) > 0 {
+		parts = append(parts, "Keep the original colors; a black-and-white photo stays black and white.")
+	}
+	parts = append(parts, "Preserve the original composition, framing, people and the character of the image; do not add, remove or move anything.")
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,54 @@
+package restore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseModes(t *testing.T) {
+	got, err := ParseModes(" Face, colorize,repair,face ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{ModeRepair, ModeColorize, ModeFace}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseModes = %q, want %q", got, want)
+	}
+	if got, err := ParseModes(""); err != nil || len(got) != 0 {
+		t.Errorf("empty modes = %q, %v", got, err)
+	}
+	if _, err := ParseModes("repair,sharpen"); err == nil {
+		t.Error("unknown modes should be rejected")
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	p := Prompt([]string{ModeColorize, ModeFace}, StrengthSubtle, "the 1940s")
+	for _, want := range []string{"Colorize", "this era: the 1940s", "Enhance every face", "subtle"} {
+		if !strings.Contains(p, want) {
+			t.Errorf("prompt missing %q: %s", want, p)
+		}
+	}
+	if strings.Contains(p, "stays black and white") {
+		t.Error("colorizing should not ask to keep black and white")
+	}
+
+	p = Prompt([]string{ModeDenoise}, StrengthStrong, "")
+	if !strings.Contains(p, "stays black and white") || strings.Contains(p, "Colorize") {
+		t.Errorf("non-colorizing modes should keep the colors: %s", p)
+	}
+	if p := Prompt(nil, StrengthModerate, ""); !strings.Contains(p, "Restore and enhance this photo") {
+		t.Errorf("no modes should ask for a general restoration: %s", p)
+	}
+}
+
+func TestValidateStrength(t *testing.T) {
+	for _, s := range []string{StrengthSubtle, StrengthModerate, StrengthStrong} {
+		if err := ValidateStrength(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+	if err := ValidateStrength("extreme"); err == nil {
+		t.Error("unknown strengths should be rejected")
+	}
+}
@@ -0,0 +1,246 @@
+package storyboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"imagemage/pkg/filehandler"
+	"io"
+	"sort"
+	"time"
+)
+
+// EncodeGIF writes frames as a looping animated GIF. All frames share one adaptive
+// palette, so colors don't flicker between frames, and are dithered onto it.
+func EncodeGIF(w io.Writer, frames []image.Image, delays []time.Duration) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to animate")
+	}
+
+	pal := medianCutPalette(frames, 256)
+	anim := &gif.GIF{LoopCount: 0}
+	for i, frame := range frames {
+		b := frame.Bounds()
+		p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), pal)
+		draw.FloydSteinberg.Draw(p, p.Rect, frame, b.Min)
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, int(delays[i].Round(10*time.Millisecond)/(10*time.Millisecond)))
+	}
+
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return nil
+}
+
+// EncodeAPNG writes frames as a looping animated PNG. Unlike GIF it keeps full color.
+// Frames must all have the same size; they are flattened onto white so every frame
+// encodes with the same PNG color type.
+func EncodeAPNG(w io.Writer, frames []image.Image, delays []time.Duration) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to animate")
+	}
+
+	var ihdr []byte
+	var out bytes.Buffer
+	seq := uint32(0)
+	out.WriteString("\x89PNG\r\n\x1a\n")
+
+	for i, frame := range frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, flattenWhite(frame)); err != nil {
+			return fmt.Errorf("failed to encode frame %d: %w", i+1, err)
+		}
+		chunks, err := pngChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			ihdr = chunks[0].data
+			writeChunk(&out, "IHDR", ihdr)
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+			binary.BigEndian.PutUint32(actl[4:], 0) // loop forever
+			writeChunk(&out, "acTL", actl)
+		} else if !bytes.Equal(chunks[0].data, ihdr) {
+			return fmt.Errorf("frame %d differs in size or color type from frame 1", i+1)
+		}
+
+		b := frame.Bounds()
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+		// x/y offsets are zero; delay is in milliseconds; dispose and blend ops are zero
+		binary.BigEndian.PutUint16(fctl[20:], uint16(min(delays[i].Milliseconds(), 65535)))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		writeChunk(&out, "fcTL", fctl)
+		seq++
+
+		for _, c := range chunks {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writeChunk(&out, "IDAT", c.data)
+				continue
+			}
+			fdat := make([]byte, 4+len(c.data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			copy(fdat[4:], c.data)
+			writeChunk(&out, "fdAT", fdat)
+			seq++
+		}
+	}
+
+	writeChunk(&out, "IEND", nil)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// FitFrames scales frames to a common size: the first frame's, shrunk so its longest
+// side is at most maxSize. Animations and PDFs need neither 4K frames nor mixed sizes.
+func FitFrames(frames []image.Image, maxSize int) []image.Image {
+	if len(frames) == 0 {
+		return nil
+	}
+	b := frames[0].Bounds()
+	w, h := b.Dx(), b.Dy()
+	if longest := max(w, h); longest > maxSize {
+		w = max(1, w*maxSize/longest)
+		h = max(1, h*maxSize/longest)
+	}
+
+	fitted := make([]image.Image, len(frames))
+	for i, frame := range frames {
+		fitted[i] = filehandler.Resize(frame, filehandler.ResizeOptions{Width: w, Height: h, Fit: filehandler.FitCover})
+	}
+	return fitted
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// pngChunks splits an encoded PNG into its chunks
+func pngChunks(data []byte) ([]pngChunk, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid PNG data")
+	}
+	var chunks []pngChunk
+	for p := 8; p+12 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[p:]))
+		if p+12+n > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{typ: string(data[p+4 : p+8]), data: data[p+8 : p+8+n]})
+		p += 12 + n
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" {
+		return nil, fmt.Errorf("PNG data does not start with IHDR")
+	}
+	return chunks, nil
+}
+
+// writeChunk writes a PNG chunk with its length and CRC
+func writeChunk(w *bytes.Buffer, typ string, data []byte) {
+	_ = binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	_ = binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// flattenWhite composites img over white, returning an opaque RGBA image
+func flattenWhite(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Rect, image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Rect, img, b.Min, draw.Over)
+	return dst
+}
+
+// medianCutPalette builds an n-color palette from a sample of the frames' pixels by
+// repeatedly splitting the color box with the widest channel range at its median
+func medianCutPalette(frames []image.Image, n int) color.Palette {
+	const maxSamples = 1 << 16
+
+	var total int
+	for _, f := range frames {
+		total += f.Bounds().Dx() * f.Bounds().Dy()
+	}
+	step := max(1, total/maxSamples)
+
+	var pixels [][3]uint8
+	i := 0
+	for _, f := range frames {
+		b := f.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if i%step == 0 {
+					r, g, bl, _ := f.At(x, y).RGBA()
+					pixels = append(pixels, [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)})
+				}
+				i++
+			}
+		}
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// Split the box with the widest range
+		best, bestRange, bestChannel := -1, 0, 0
+		for bi, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				lo, hi := uint8(255), uint8(0)
+				for _, p := range box {
+					lo = min(lo, p[c])
+					hi = max(hi, p[c])
+				}
+				if r := int(hi) - int(lo); r > bestRange {
+					best, bestRange, bestChannel = bi, r, c
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(a, b int) bool { return box[a][bestChannel] < box[b][bestChannel] })
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	pal := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		var sum [3]int
+		for _, p := range box {
+			for c := 0; c < 3; c++ {
+				sum[c] += int(p[c])
+			}
+		}
+		pal = append(pal, color.RGBA{R: uint8(sum[0] / len(box)), G: uint8(sum[1] / len(box)), B: uint8(sum[2] / len(box)), A: 255})
+	}
+	if len(pal) == 0 {
+		pal = append(pal, color.Black)
+	}
+	return pal
+}
@@ -0,0 +1,180 @@
+package storyboard
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"imagemage/pkg/filehandler"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Panel is one frame of a comic page
+type Panel struct {
+	Image   image.Image
+	Caption string
+}
+
+// ComicOptions controls the comic page layout
+type ComicOptions struct {
+	Title   string
+	Columns int // panels per row; 0 picks 2 for up to 4 panels, else 3
+	Width   int // page width in pixels; 0 means 2400
+}
+
+// comicBorder is the width of the inked panel borders in pixels
+const comicBorder = 4
+
+// Comic page colors
+var (
+	comicPaper = color.NRGBA{R: 0xfb, G: 0xf8, B: 0xf0, A: 0xff}
+	comicInk   = color.NRGBA{R: 0x11, G: 0x11, B: 0x11, A: 0xff}
+)
+
+// Comic lays panels out in a grid with gutters, inked borders and captions set
+// below each panel in the bundled Go font, with an optional title across the top
+func Comic(panels []Panel, opts ComicOptions) (*image.NRGBA, error) {
+	if len(panels) == 0 {
+		return nil, fmt.Errorf("no panels to lay out")
+	}
+	if opts.Width == 0 {
+		opts.Width = 2400
+	}
+	if opts.Columns == 0 {
+		opts.Columns = 2
+		if len(panels) > 4 {
+			opts.Columns = 3
+		}
+	}
+	cols := min(opts.Columns, len(panels))
+
+	gutter := opts.Width / 60
+	panelW := (opts.Width - gutter*(cols+1)) / cols
+
+	captionFace, err := newFace(goregular.TTF, float64(panelW)/24)
+	if err != nil {
+		return nil, err
+	}
+	titleFace, err := newFace(gobold.TTF, float64(opts.Width)/30)
+	if err != nil {
+		return nil, err
+	}
+	lineHeight := captionFace.Metrics().Height.Ceil()
+
+	// Measure rows: the tallest panel plus the most caption lines in each row
+	type cell struct {
+		img   image.Image
+		h     int
+		lines []string
+	}
+	cells := make([]cell, len(panels))
+	for i, p := range panels {
+		b := p.Image.Bounds()
+		h := int(math.Round(float64(panelW) * float64(b.Dy()) / float64(b.Dx())))
+		cells[i] = cell{
+			img:   filehandler.Resize(p.Image, filehandler.ResizeOptions{Width: panelW, Height: h, Fit: filehandler.FitFill}),
+			h:     h,
+			lines: wrapText(captionFace, p.Caption, panelW),
+		}
+	}
+
+	titleHeight := 0
+	if opts.Title != "" {
+		titleHeight = titleFace.Metrics().Height.Ceil() + gutter
+	}
+	var rowHeights []int
+	height := gutter + titleHeight
+	for start := 0; start < len(cells); start += cols {
+		rowH := 0
+		for _, c := range cells[start:min(start+cols, len(cells))] {
+			captionH := 0
+			if len(c.lines) > 0 {
+				captionH = gutter/2 + len(c.lines)*lineHeight
+			}
+			rowH = max(rowH, c.h+captionH)
+		}
+		rowHeights = append(rowHeights, rowH)
+		height += rowH + gutter
+	}
+
+	page := image.NewNRGBA(image.Rect(0, 0, opts.Width, height))
+	draw.Draw(page, page.Rect, &image.Uniform{C: comicPaper}, image.Point{}, draw.Src)
+
+	if opts.Title != "" {
+		w := font.MeasureString(titleFace, opts.Title).Ceil()
+		drawText(page, titleFace, (opts.Width-w)/2, gutter+titleFace.Metrics().Ascent.Ceil(), opts.Title)
+	}
+
+	y := gutter + titleHeight
+	for row, rowH := range rowHeights {
+		for col := 0; col < cols; col++ {
+			i := row*cols + col
+			if i >= len(cells) {
+				break
+			}
+			c := cells[i]
+			x := gutter + col*(panelW+gutter)
+
+			frame := image.Rect(x, y, x+panelW, y+c.h)
+			draw.Draw(page, frame.Inset(-comicBorder), &image.Uniform{C: comicInk}, image.Point{}, draw.Src)
+			draw.Draw(page, frame, c.img, c.img.Bounds().Min, draw.Src)
+
+			baseline := y + c.h + gutter/2 + captionFace.Metrics().Ascent.Ceil()
+			for _, line := range c.lines {
+				drawText(page, captionFace, x, baseline, line)
+				baseline += lineHeight
+			}
+		}
+		y += rowH + gutter
+	}
+
+	return page, nil
+}
+
+// newFace loads a bundled TrueType font at the given pixel size
+func newFace(ttf []byte, size float64) (font.Face, error) {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: max(size, 8), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+	return face, nil
+}
+
+// drawText draws text in ink with its baseline at (x, y)
+func drawText(dst draw.Image, face font.Face, x, y int, text string) {
+	d := font.Drawer{Dst: dst, Src: &image.Uniform{C: comicInk}, Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(text)
+}
+
+// wrapText breaks text into lines no wider than width pixels
+func wrapText(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+			lines = append(lines, line)
+			line = word
+		} else {
+			line = candidate
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,35 @@
+package storyboard
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Supported story packages
+const (
+	PackageGIF   = "gif"   // animated GIF
+	PackageAPNG  = "apng"  // animated PNG
+	PackageComic = "comic" // comic page PNG
+	PackagePDF   = "pdf"   // one frame per page
+)
+
+// Packages lists the supported story packages in output order
+var Packages = []string{PackageGIF, PackageAPNG, PackageComic, PackagePDF}
+
+// ParsePackages parses a comma-separated package list
+func ParsePackages(s string) ([]string, error) {
+	var packages []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch p {
+		case PackageGIF, PackageAPNG, PackageComic, PackagePDF:
+			if !slices.Contains(packages, p) {
+				packages = append(packages, p)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported package: %s (use gif, apng, comic or pdf)", p)
+		}
+	}
+	return packages, nil
+}
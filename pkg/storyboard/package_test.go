@@ -0,0 +1,146 @@
+package storyboard
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// solid returns a w×h image filled with c
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b, a := c.RGBA()
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+	}
+	return img
+}
+
+func testFrames() ([]image.Image, []time.Duration) {
+	frames := []image.Image{
+		solid(64, 48, color.NRGBA{R: 200, A: 255}),
+		solid(64, 48, color.NRGBA{G: 200, A: 255}),
+		solid(64, 48, color.NRGBA{B: 200, A: 255}),
+	}
+	delays := []time.Duration{time.Second, 500 * time.Millisecond, 2 * time.Second}
+	return frames, delays
+}
+
+func TestParsePackages(t *testing.T) {
+	got, err := ParsePackages("GIF, pdf,gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{PackageGIF, PackagePDF}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePackages = %v, want %v", got, want)
+	}
+	if _, err := ParsePackages("gif,mp4"); err == nil {
+		t.Error("expected an error for an unsupported package")
+	}
+}
+
+func TestEncodeGIF(t *testing.T) {
+	frames, delays := testFrames()
+	var buf bytes.Buffer
+	if err := EncodeGIF(&buf, frames, delays); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 || !reflect.DeepEqual(anim.Delay, []int{100, 50, 200}) {
+		t.Errorf("got %d frames with delays %v", len(anim.Image), anim.Delay)
+	}
+	if r, g, _, _ := anim.Image[1].At(10, 10).RGBA(); r>>8 > 10 || g>>8 < 190 {
+		t.Errorf("frame 2 lost its color: r=%d g=%d", r>>8, g>>8)
+	}
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	frames, delays := testFrames()
+	var buf bytes.Buffer
+	if err := EncodeAPNG(&buf, frames, delays); err != nil {
+		t.Fatal(err)
+	}
+
+	// Viewers without APNG support show the first frame as a plain PNG
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(10, 10).RGBA(); r>>8 != 200 {
+		t.Errorf("default image is not frame 1: r=%d", r>>8)
+	}
+
+	chunks, err := pngChunks(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := map[string]int{}
+	for _, c := range chunks {
+		count[c.typ]++
+	}
+	if count["acTL"] != 1 || count["fcTL"] != 3 || count["IDAT"] < 1 || count["fdAT"] < 2 {
+		t.Errorf("unexpected chunks: %v", count)
+	}
+
+	if err := EncodeAPNG(&buf, []image.Image{frames[0], solid(32, 32, color.White)}, delays); err == nil {
+		t.Error("expected an error for frames of different sizes")
+	}
+}
+
+func TestFitFrames(t *testing.T) {
+	fitted := FitFrames([]image.Image{solid(400, 200, color.Black), solid(300, 300, color.White)}, 100)
+	for i, f := range fitted {
+		if b := f.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+			t.Errorf("frame %d is %dx%d, want 100x50", i+1, b.Dx(), b.Dy())
+		}
+	}
+}
+
+func TestComic(t *testing.T) {
+	frames, _ := testFrames()
+	panels := make([]Panel, len(frames))
+	for i, f := range frames {
+		panels[i] = Panel{Image: f, Caption: "A caption long enough that it has to wrap onto a second line"}
+	}
+	page, err := Comic(panels, ComicOptions{Title: "Test", Width: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Rect.Dx() != 600 {
+		t.Errorf("page width = %d, want 600", page.Rect.Dx())
+	}
+	// Two columns, two rows: the page is taller than one row of panels
+	if page.Rect.Dy() < 2*(600/2)*48/64 {
+		t.Errorf("page height %d is too short for two rows", page.Rect.Dy())
+	}
+}
+
+func TestEncodePDF(t *testing.T) {
+	frames, _ := testFrames()
+	pages := make([]PDFPage, len(frames))
+	for i, f := range frames {
+		pages[i] = PDFPage{Image: f, Heading: "Story — Frame", Caption: "Fen (the fox) wakes"}
+	}
+	var buf bytes.Buffer
+	if err := EncodePDF(&buf, "Story", pages); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Error("output is not a complete PDF")
+	}
+	if !bytes.Contains(out, []byte("/Count 3")) {
+		t.Error("page tree does not list three pages")
+	}
+	if !bytes.Contains(out, []byte(`(Fen \(the fox\) wakes)`)) || !bytes.Contains(out, []byte(`(Story \227 Frame)`)) {
+		t.Error("text is not escaped as PDF strings")
+	}
+}
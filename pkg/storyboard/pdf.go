@@ -0,0 +1,187 @@
+package storyboard
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// PDF page geometry in points: landscape A4
+const (
+	pdfPageWidth  = 842
+	pdfPageHeight = 595
+	pdfMargin     = 40
+	pdfTitleSize  = 14
+	pdfTextSize   = 12
+	pdfLeading    = 16
+)
+
+// pdfJPEGQuality balances storyboard file size against image quality
+const pdfJPEGQuality = 85
+
+// PDFPage is one storyboard page: a frame with its heading and caption
+type PDFPage struct {
+	Image   image.Image
+	Heading string
+	Caption string
+}
+
+// EncodePDF writes a storyboard PDF with one landscape page per frame. Images are
+// embedded as JPEG and text is set in the standard Helvetica fonts, so no font data
+// needs embedding.
+func EncodePDF(w io.Writer, title string, pages []PDFPage) error {
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages to write")
+	}
+
+	p := &pdfWriter{}
+	p.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Fixed objects: 1 catalog, 2 page tree, 3-4 fonts, 5 info; pages follow
+	const firstPage = 6
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+3*i)
+	}
+	p.object("<< /Type /Catalog /Pages 2 0 R >>")
+	p.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	p.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	p.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	p.object(fmt.Sprintf("<< /Title %s /Producer (imagemage) >>", pdfString(title)))
+
+	for i, page := range pages {
+		pageObj := firstPage + 3*i
+
+		var jpg bytes.Buffer
+		if err := jpeg.Encode(&jpg, flattenWhite(page.Image), &jpeg.Options{Quality: pdfJPEGQuality}); err != nil {
+			return fmt.Errorf("failed to encode page %d image: %w", i+1, err)
+		}
+		b := page.Image.Bounds()
+
+		content := pageContent(page, float64(b.Dx()), float64(b.Dy()))
+		p.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> /XObject << /Im1 %d 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, pageObj+2, pageObj+1))
+		p.stream(fmt.Sprintf("<< /Length %d >>", len(content)), content)
+		p.stream(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+			b.Dx(), b.Dy(), jpg.Len()), jpg.Bytes())
+	}
+
+	// Cross-reference table and trailer
+	xref := p.buf.Len()
+	fmt.Fprintf(&p.buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, off := range p.offsets {
+		fmt.Fprintf(&p.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&p.buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// pageContent draws the heading at the top, the image fitted in the middle and the
+// caption below it
+func pageContent(page PDFPage, imgW, imgH float64) []byte {
+	var c bytes.Buffer
+	textWidth := float64(pdfPageWidth - 2*pdfMargin)
+
+	top := float64(pdfPageHeight - pdfMargin)
+	if page.Heading != "" {
+		fmt.Fprintf(&c, "BT /F2 %d Tf %d %.2f Td %s Tj ET\n", pdfTitleSize, pdfMargin, top-pdfTitleSize, pdfString(page.Heading))
+		top -= pdfTitleSize + 12
+	}
+
+	lines := wrapPDFText(page.Caption, textWidth, pdfTextSize)
+	bottom := float64(pdfMargin)
+	if len(lines) > 0 {
+		bottom += float64(len(lines)*pdfLeading) + 8
+	}
+
+	// Fit the image between heading and caption, centered
+	scale := min(textWidth/imgW, (top-bottom)/imgH)
+	w, h := imgW*scale, imgH*scale
+	x := (pdfPageWidth - w) / 2
+	y := bottom + (top-bottom-h)/2
+	fmt.Fprintf(&c, "q %.2f 0 0 %.2f %.2f %.2f cm /Im1 Do Q\n", w, h, x, y)
+
+	baseline := bottom - 8 - pdfTextSize
+	for _, line := range lines {
+		fmt.Fprintf(&c, "BT /F1 %d Tf %d %.2f Td %s Tj ET\n", pdfTextSize, pdfMargin, baseline, pdfString(line))
+		baseline -= pdfLeading
+	}
+	return c.Bytes()
+}
+
+// wrapPDFText breaks text into lines that fit width points. Helvetica averages about
+// half an em per character, which is close enough for captions.
+func wrapPDFText(text string, width float64, size int) []string {
+	maxChars := int(width / (0.5 * float64(size)))
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > maxChars {
+			lines = append(lines, line)
+			line = word
+		} else if line == "" {
+			line = word
+		} else {
+			line += " " + word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// winAnsi maps common typographic characters outside Latin-1 to WinAnsiEncoding
+var winAnsi = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// pdfString encodes s as a PDF literal string in WinAnsiEncoding, replacing
+// characters the standard fonts can't show with '?'
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case winAnsi[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsi[r])
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfWriter tracks object offsets for the cross-reference table
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object writes the next numbered object
+func (p *pdfWriter) object(body string) {
+	p.offsets = append(p.offsets, p.buf.Len())
+	fmt.Fprintf(&p.buf, "%d 0 obj\n%s\nendobj\n", len(p.offsets), body)
+}
+
+// stream writes the next numbered object as a stream
+func (p *pdfWriter) stream(dict string, data []byte) {
+	p.offsets = append(p.offsets, p.buf.Len())
+	fmt.Fprintf(&p.buf, "%d 0 obj\n%s\nstream\n", len(p.offsets), dict)
+	p.buf.Write(data)
+	p.buf.WriteString("\nendstream\nendobj\n")
+}
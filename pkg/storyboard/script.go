@@ -0,0 +1,279 @@
+package storyboard
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script is a storyboard: shared style and characters declared once, then one entry per frame
+type Script struct {
+	Title      string      `yaml:"title"`
+	Style      string      `yaml:"style"`
+	Setting    string      `yaml:"setting"`
+	Characters []Character `yaml:"characters"`
+	Frames     []Frame     `yaml:"frames"`
+}
+
+// Character is a recurring character described once for every frame
+type Character struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// Frame is a single storyboard panel
+type Frame struct {
+	Description string `yaml:"description"`
+	Shot        string `yaml:"shot"`     // camera and framing notes, e.g. "low-angle close-up"
+	Caption     string `yaml:"caption"`  // text shown with the frame, never drawn by the model
+	Input       string `yaml:"input"`    // optional input image, relative to the script
+	Duration    string `yaml:"duration"` // how long the frame shows in animations, e.g. 2s
+}
+
+// Delay returns the frame's animation delay, or 0 when it has none. Plain numbers are seconds.
+func (f Frame) Delay() (time.Duration, error) {
+	if f.Duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(f.Duration)
+	if seconds, numErr := strconv.ParseFloat(f.Duration, 64); numErr == nil {
+		d, err = time.Duration(seconds*float64(time.Second)), nil
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q (use e.g. 2s or 1500ms)", f.Duration)
+	}
+	return d, nil
+}
+
+// Load reads a storyboard script from a .md or .yaml/.yml file. Frame input paths are
+// resolved relative to the script's directory.
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+
+	format := ""
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		format = "markdown"
+	case ".yaml", ".yml":
+		format = "yaml"
+	}
+
+	script, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	for i := range script.Frames {
+		if input := script.Frames[i].Input; input != "" && input != "-" && !filepath.IsAbs(input) {
+			script.Frames[i].Input = filepath.Join(filepath.Dir(path), input)
+		}
+	}
+	return script, nil
+}
+
+// Parse parses a script as "markdown" or "yaml". An empty format is detected from the
+// content: YAML scripts have a top-level frames key.
+func Parse(data []byte, format string) (*Script, error) {
+	if format == "" {
+		format = "markdown"
+		if regexp.MustCompile(`(?m)^frames:\s*$`).Match(data) {
+			format = "yaml"
+		}
+	}
+
+	var script *Script
+	var err error
+	switch format {
+	case "yaml":
+		script = &Script{}
+		if err = yaml.Unmarshal(data, script); err != nil {
+			err = fmt.Errorf("failed to parse YAML script: %w", err)
+		}
+	case "markdown":
+		script, err = parseMarkdown(data)
+	default:
+		return nil, fmt.Errorf("unsupported script format: %s (use markdown or yaml)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(script.Frames) == 0 {
+		return nil, fmt.Errorf("script has no frames")
+	}
+	for i, f := range script.Frames {
+		if strings.TrimSpace(f.Description) == "" {
+			return nil, fmt.Errorf("frame %d has no description", i+1)
+		}
+		if _, err := f.Delay(); err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+	}
+	return script, nil
+}
+
+var (
+	markdownField     = regexp.MustCompile(`^(?i)(style|setting|shot|camera|caption|input|image|duration)\s*:\s*(.*)$`)
+	markdownCharacter = regexp.MustCompile(`^[-*]\s+(?:\*\*(.+?)\*\*\s*(?::|-|–)|([^:]+):)\s*(.+)$`)
+)
+
+// parseMarkdown parses the markdown script layout:
+//
+//	# Title
+//	Style: ...
+//	Setting: ...
+//
+//	## Characters
+//	- **Name**: description
+//
+//	## Frame 1
+//	Description, as one or more lines of text.
+//	Shot: ...
+//	Caption: ...
+//	Input: sketch.png
+//	Duration: 2s
+//
+// Every level-two heading other than Characters starts a new frame.
+func parseMarkdown(data []byte) (*Script, error) {
+	script := &Script{}
+	section := "" // "", "characters" or "frame"
+	var description []string
+
+	endFrame := func() {
+		if section == "frame" {
+			f := &script.Frames[len(script.Frames)-1]
+			f.Description = strings.Join(description, " ")
+		}
+		description = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "## "):
+			endFrame()
+			if strings.EqualFold(strings.TrimSpace(line[3:]), "characters") {
+				section = "characters"
+			} else {
+				section = "frame"
+				script.Frames = append(script.Frames, Frame{})
+			}
+			continue
+		case strings.HasPrefix(line, "# ") && section == "":
+			script.Title = strings.TrimSpace(line[2:])
+			continue
+		}
+
+		if m := markdownField.FindStringSubmatch(line); m != nil {
+			key, value := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+			if section == "frame" {
+				f := &script.Frames[len(script.Frames)-1]
+				switch key {
+				case "shot", "camera":
+					f.Shot = value
+					continue
+				case "caption":
+					f.Caption = strings.Trim(value, `"`)
+					continue
+				case "input", "image":
+					f.Input = value
+					continue
+				case "duration":
+					f.Duration = value
+					continue
+				}
+			} else if section == "" {
+				switch key {
+				case "style":
+					script.Style = value
+					continue
+				case "setting":
+					script.Setting = value
+					continue
+				}
+			}
+		}
+
+		switch section {
+		case "characters":
+			m := markdownCharacter.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid character line %q (use \"- Name: description\")", line)
+			}
+			name := m[1]
+			if name == "" {
+				name = m[2]
+			}
+			script.Characters = append(script.Characters, Character{Name: strings.TrimSpace(name), Description: strings.TrimSpace(m[3])})
+		case "frame":
+			description = append(description, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	endFrame()
+
+	return script, nil
+}
+
+// Prompt builds the generation prompt for frame i (zero-based), repeating the shared
+// style, setting and characters so each request stands on its own
+func (s *Script) Prompt(i int) string {
+	f := s.Frames[i]
+	var b strings.Builder
+	fmt.Fprintf(&b, "Frame %d of %d in a visual story", i+1, len(s.Frames))
+	if s.Title != "" {
+		fmt.Fprintf(&b, " titled %q", s.Title)
+	}
+	fmt.Fprintf(&b, ": %s", strings.TrimSuffix(strings.TrimSpace(f.Description), "."))
+	if f.Shot != "" {
+		fmt.Fprintf(&b, ". Camera: %s", f.Shot)
+	}
+	if len(s.Characters) > 0 {
+		characters := make([]string, 0, len(s.Characters))
+		for _, c := range s.Characters {
+			characters = append(characters, fmt.Sprintf("%s (%s)", c.Name, c.Description))
+		}
+		fmt.Fprintf(&b, ". Characters: %s", strings.Join(characters, "; "))
+	}
+	if s.Setting != "" {
+		fmt.Fprintf(&b, ". Setting: %s", s.Setting)
+	}
+	if s.Style != "" {
+		fmt.Fprintf(&b, ". Style: %s", s.Style)
+	}
+	b.WriteString(". Do not add captions or speech bubbles.")
+	return b.String()
+}
+
+// Summary describes the story's title, characters and setting, e.g. for a reference sheet
+func (s *Script) Summary() string {
+	var parts []string
+	if s.Title != "" {
+		parts = append(parts, s.Title)
+	}
+	for _, c := range s.Characters {
+		parts = append(parts, fmt.Sprintf("%s, %s", c.Name, c.Description))
+	}
+	if s.Setting != "" {
+		parts = append(parts, "set in "+s.Setting)
+	}
+	if len(parts) == 0 {
+		return s.Frames[0].Description
+	}
+	return strings.Join(parts, "; ")
+}
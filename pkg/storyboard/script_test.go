@@ -0,0 +1,107 @@
+package storyboard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const markdownScript = `# The Fox's Year
+Style: watercolor, soft morning light
+Setting: a birch forest
+
+## Characters
+- **Fen**: a small red fox with a green scarf
+- Owl: an old barn owl
+
+## Frame 1
+Fen wakes up in a snowy den.
+Snow drifts over the entrance.
+Shot: wide establishing shot
+Caption: "Winter"
+Input: sketches/den.png
+Duration: 2s
+
+## Spring
+Fen chases a butterfly.
+`
+
+const yamlScript = `title: The Fox's Year
+style: watercolor, soft morning light
+setting: a birch forest
+characters:
+  - name: Fen
+    description: a small red fox with a green scarf
+  - name: Owl
+    description: an old barn owl
+frames:
+  - description: Fen wakes up in a snowy den. Snow drifts over the entrance.
+    shot: wide establishing shot
+    caption: Winter
+    input: sketches/den.png
+    duration: 2s
+  - description: Fen chases a butterfly.
+`
+
+func TestParseFormatsAgree(t *testing.T) {
+	md, err := Parse([]byte(markdownScript), "")
+	if err != nil {
+		t.Fatalf("markdown: %v", err)
+	}
+	yml, err := Parse([]byte(yamlScript), "")
+	if err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	if !reflect.DeepEqual(md, yml) {
+		t.Errorf("markdown and YAML scripts differ:\n%+v\n%+v", md, yml)
+	}
+	if len(md.Frames) != 2 || md.Frames[0].Caption != "Winter" || md.Characters[1].Name != "Owl" {
+		t.Errorf("unexpected script: %+v", md)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	script, err := Parse([]byte(yamlScript), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt := script.Prompt(0)
+	for _, want := range []string{"Frame 1 of 2", "snowy den", "Camera: wide establishing shot", "Fen (a small red fox", "Style: watercolor"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q: %s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "Winter") {
+		t.Errorf("captions must not reach the prompt: %s", prompt)
+	}
+}
+
+func TestParseRejectsEmptyScripts(t *testing.T) {
+	if _, err := Parse([]byte("# Title\nStyle: noir\n"), "markdown"); err == nil {
+		t.Error("expected an error for a script without frames")
+	}
+	if _, err := Parse([]byte("frames:\n  - shot: close-up\n"), ""); err == nil {
+		t.Error("expected an error for a frame without a description")
+	}
+}
+
+func TestFrameDelay(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      0,
+		"2s":    2 * time.Second,
+		"750ms": 750 * time.Millisecond,
+		"1.5":   1500 * time.Millisecond,
+	}
+	for in, want := range cases {
+		got, err := Frame{Duration: in}.Delay()
+		if err != nil || got != want {
+			t.Errorf("Delay(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"soon", "-1s"} {
+		if _, err := (Frame{Duration: in}).Delay(); err == nil {
+			t.Errorf("Delay(%q): expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package textcheck
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// minSimilarity is how close found text must be to an expected label to count as a
+// misspelling of it rather than unrelated text
+const minSimilarity = 0.6
+
+// Mismatch is an expected label that doesn't appear in an image
+type Mismatch struct {
+	Expected string
+	Found    string // the closest text in the image, e.g. a misspelling; "" if nothing is close
+}
+
+// Check compares expected labels with the text read from an image. Matching ignores
+// case, punctuation and line breaks, so a label the model wrapped over two lines still
+// counts. For each label that isn't there it suggests the most similar text that is,
+// so "Ordres DB" is reported as a misspelling of "Orders DB".
+func Check(expected, found []string) []Mismatch {
+	text := " " + normalize(strings.Join(found, " ")) + " "
+	words := strings.Fields(strings.Join(found, " "))
+
+	var mismatches []Mismatch
+	for _, label := range expected {
+		want := normalize(label)
+		if want == "" || strings.Contains(text, " "+want+" ") {
+			continue
+		}
+		mismatches = append(mismatches, Mismatch{Expected: label, Found: closest(want, found, words)})
+	}
+	return mismatches
+}
+
+// closest returns the found line or run of words most similar to want, or "" if none
+// is similar enough
+func closest(want string, lines, words []string) string {
+	candidates := append([]string(nil), lines...)
+	n := len(strings.Fields(want))
+	for size := max(1, n-1); size <= n+1; size++ {
+		for i := 0; i+size <= len(words); i++ {
+			candidates = append(candidates, strings.Join(words[i:i+size], " "))
+		}
+	}
+
+	best, bestScore := "", minSimilarity
+	for _, c := range candidates {
+		if score := Similarity(want, c); score >= bestScore && score > 0 {
+			best, bestScore = trimPunct(c), score
+		}
+	}
+	return best
+}
+
+// Similarity scores how alike two strings are from 0 to 1, ignoring case and
+// punctuation, using edit distance
+func Similarity(a, b string) float64 {
+	ra, rb := []rune(normalize(a)), []rune(normalize(b))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(distance(ra, rb))/float64(longest)
+}
+
+// distance is the Levenshtein distance between two rune slices
+func distance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// FixPrompt asks an image model to correct the mismatched text in an image and leave
+// everything else alone
+func FixPrompt(mismatches []Mismatch) string {
+	var b strings.Builder
+	b.WriteString("Correct the text in this image. ")
+	for _, m := range mismatches {
+		if m.Found != "" {
+			fmt.Fprintf(&b, "Fix the spelling of %q to %q. ", m.Found, m.Expected)
+		} else {
+			fmt.Fprintf(&b, "The label %q is missing or unreadable; write it clearly where it belongs. ", m.Expected)
+		}
+	}
+	b.WriteString("Use exactly this spelling and capitalization. Keep everything else - layout, shapes, colors, style and all other text - exactly the same.")
+	return b.String()
+}
+
+// ParseLabels splits a comma- or newline-separated label list
+func ParseLabels(s string) []string {
+	var labels []string
+	for _, label := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// normalize lowercases s and reduces it to words separated by single spaces
+func normalize(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// trimPunct removes punctuation around s
+func trimPunct(s string) string {
+	return strings.TrimFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
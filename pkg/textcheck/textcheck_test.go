@@ -0,0 +1,54 @@
+package textcheck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	expected := []string{"Auth Service", "Orders DB", "Payment Gateway", "Cache"}
+	found := []string{"Auth Service", "Ordres DB", "Payment", "Gatewey", "Logs"}
+
+	want := []Mismatch{
+		{Expected: "Orders DB", Found: "Ordres DB"},
+		{Expected: "Payment Gateway", Found: "Payment Gatewey"},
+		{Expected: "Cache", Found: ""},
+	}
+	if got := Check(expected, found); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check = %+v, want %+v", got, want)
+	}
+
+	// A label wrapped over two lines is still there
+	if got := Check([]string{"Auth Service"}, []string{"AUTH", "SERVICE"}); len(got) != 0 {
+		t.Errorf("wrapped label reported missing: %+v", got)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if s := Similarity("Orders DB", "orders, db"); s != 1 {
+		t.Errorf("case and punctuation should not count, got %v", s)
+	}
+	if s := Similarity("kitten", "sitting"); s < 0.5 || s > 0.6 {
+		t.Errorf("kitten/sitting = %v, want 1-3/7", s)
+	}
+	if s := Similarity("Cache", "Logs"); s >= minSimilarity {
+		t.Errorf("unrelated words scored %v", s)
+	}
+}
+
+func TestFixPrompt(t *testing.T) {
+	p := FixPrompt([]Mismatch{{Expected: "Orders DB", Found: "Ordres DB"}, {Expected: "Cache"}})
+	for _, want := range []string{`Fix the spelling of "Ordres DB" to "Orders DB"`, `"Cache" is missing`, "Keep everything else"} {
+		if !strings.Contains(p, want) {
+			t.Errorf("prompt missing %q: %s", want, p)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	got := ParseLabels(" Q3 Revenue, Growth ,\nHeadcount,, ")
+	if want := []string{"Q3 Revenue", "Growth", "Headcount"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLabels = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package texture
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// PBROptions controls how material maps are derived from an albedo texture
+type PBROptions struct {
+	BlurRadius     int     // smoothing applied to the height map, in pixels
+	NormalStrength float64 // steepness of the normal map; higher exaggerates relief
+}
+
+// DefaultPBROptions work for typical 1-4K textures
+var DefaultPBROptions = PBROptions{BlurRadius: 2, NormalStrength: 4}
+
+// Maps holds the material maps derived from an albedo texture. All maps wrap
+// around the edges, so they tile as seamlessly as the albedo does.
+type Maps struct {
+	Height    *image.Gray16 // brighter is higher
+	Normal    *image.NRGBA  // tangent-space, OpenGL convention (green points up)
+	Roughness *image.Gray   // white is fully rough
+	AO        *image.Gray   // ambient occlusion; dark in crevices
+}
+
+// Material describes a texture set for engines and artists. Map paths are relative
+// to the descriptor.
+type Material struct {
+	Name         string            `json:"name"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	Maps         map[string]string `json:"maps"`
+	NormalFormat string            `json:"normalFormat"`
+	Wrap         string            `json:"wrap"`
+	Generator    string            `json:"generator"`
+}
+
+// Material map names, used as suffixes in file names and keys in Material.Maps
+const (
+	MapAlbedo    = "albedo"
+	MapHeight    = "height"
+	MapNormal    = "normal"
+	MapRoughness = "roughness"
+	MapAO        = "ao"
+)
+
+// DeriveMaps approximates height, normal, roughness and AO maps from an albedo
+// texture. Height comes from luminance (light areas read as raised), normals from a
+// Sobel filter over the height, AO from how far each point sits below its
+// surroundings, and roughness from darkness and fine detail.
+func DeriveMaps(albedo image.Image, opts PBROptions) Maps {
+	src := toNRGBA(albedo)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	// Luminance, 0-1
+	lum := make([]float64, w*h)
+	for i := range lum {
+		p := src.Pix[4*i : 4*i+3]
+		lum[i] = (0.2126*float64(p[0]) + 0.7152*float64(p[1]) + 0.0722*float64(p[2])) / 255
+	}
+
+	height := normalize(boxBlur(lum, w, h, opts.BlurRadius))
+
+	maps := Maps{
+		Height:    image.NewGray16(image.Rect(0, 0, w, h)),
+		Normal:    image.NewNRGBA(image.Rect(0, 0, w, h)),
+		Roughness: image.NewGray(image.Rect(0, 0, w, h)),
+		AO:        image.NewGray(image.Rect(0, 0, w, h)),
+	}
+
+	// Wide surroundings for AO, and local detail for roughness
+	surroundings := boxBlur(height, w, h, max(4, min(w, h)/64))
+	detail := make([]float64, w*h)
+	local := boxBlur(height, w, h, 2)
+	for i := range detail {
+		detail[i] = math.Abs(height[i] - local[i])
+	}
+	detail = normalize(detail)
+
+	at := func(x, y int) float64 {
+		return height[((y+h)%h)*w+(x+w)%w]
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			maps.Height.SetGray16(x, y, color.Gray16{Y: uint16(math.Round(height[i] * 0xffff))})
+
+			// Sobel gradients, wrapping at the edges
+			dx := (at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1)) - (at(x-1, y-1) + 2*at(x-1, y) + at(x-1, y+1))
+			dy := (at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1)) - (at(x-1, y-1) + 2*at(x, y-1) + at(x+1, y-1))
+			nx, ny, nz := -dx*opts.NormalStrength, dy*opts.NormalStrength, 1.0
+			length := math.Sqrt(nx*nx + ny*ny + nz*nz)
+			maps.Normal.SetNRGBA(x, y, color.NRGBA{
+				R: unit(nx / length),
+				G: unit(ny / length),
+				B: unit(nz / length),
+				A: 255,
+			})
+
+			occlusion := math.Max(0, surroundings[i]-height[i])
+			maps.AO.SetGray(x, y, color.Gray{Y: level(1 - 2*occlusion)})
+
+			roughness := 0.4 + 0.4*(1-lum[i]) + 0.2*detail[i]
+			maps.Roughness.SetGray(x, y, color.Gray{Y: level(roughness)})
+		}
+	}
+	return maps
+}
+
+// boxBlur blurs v with a separable box filter that wraps around the edges
+func boxBlur(v []float64, w, h, radius int) []float64 {
+	if radius <= 0 {
+		return append([]float64(nil), v...)
+	}
+	n := float64(2*radius + 1)
+	tmp := make([]float64, w*h)
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		row := v[y*w : (y+1)*w]
+		sum := 0.0
+		for k := -radius; k <= radius; k++ {
+			sum += row[((k%w)+w)%w]
+		}
+		for x := 0; x < w; x++ {
+			tmp[y*w+x] = sum / n
+			sum += row[(x+radius+1)%w] - row[((x-radius)%w+w)%w]
+		}
+	}
+	for x := 0; x < w; x++ {
+		sum := 0.0
+		for k := -radius; k <= radius; k++ {
+			sum += tmp[(((k%h)+h)%h)*w+x]
+		}
+		for y := 0; y < h; y++ {
+			out[y*w+x] = sum / n
+			sum += tmp[((y+radius+1)%h)*w+x] - tmp[(((y-radius)%h+h)%h)*w+x]
+		}
+	}
+	return out
+}
+
+// normalize stretches v to the full 0-1 range
+func normalize(v []float64) []float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, x := range v {
+		lo = math.Min(lo, x)
+		hi = math.Max(hi, x)
+	}
+	out := make([]float64, len(v))
+	if hi-lo < 1e-9 {
+		for i := range out {
+			out[i] = 0.5
+		}
+		return out
+	}
+	for i, x := range v {
+		out[i] = (x - lo) / (hi - lo)
+	}
+	return out
+}
+
+// unit maps a -1..1 vector component to a color channel
+func unit(v float64) uint8 {
+	return level((v + 1) / 2)
+}
+
+// level maps 0..1 to a color channel
+func level(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
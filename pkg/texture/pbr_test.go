@@ -0,0 +1,36 @@
+package texture
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDeriveMapsFlat(t *testing.T) {
+	flat := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := range flat.Pix {
+		flat.Pix[i] = 200
+	}
+
+	maps := DeriveMaps(flat, DefaultPBROptions)
+	if got := maps.Normal.NRGBAAt(5, 5); got != (color.NRGBA{R: 128, G: 128, B: 255, A: 255}) {
+		t.Errorf("flat texture should face straight up, got normal %v", got)
+	}
+	if got := maps.AO.GrayAt(5, 5).Y; got != 255 {
+		t.Errorf("flat texture should have no occlusion, got %d", got)
+	}
+}
+
+func TestDeriveMapsTile(t *testing.T) {
+	maps := DeriveMaps(wave(64, 64, true), DefaultPBROptions)
+	for name, img := range map[string]image.Image{
+		MapHeight:    maps.Height,
+		MapNormal:    maps.Normal,
+		MapRoughness: maps.Roughness,
+		MapAO:        maps.AO,
+	} {
+		if score := SeamScore(img); score > DefaultSeamThreshold {
+			t.Errorf("%s map of a seamless tile scored %.2f", name, score)
+		}
+	}
+}
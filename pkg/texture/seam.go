@@ -0,0 +1,108 @@
+package texture
+
+import (
+	"image"
+	"image/draw"
+)
+
+// DefaultSeamThreshold is the seam score above which a tile visibly fails to wrap
+const DefaultSeamThreshold = 1.5
+
+// SeamScore measures how well img tiles. It compares the color change across the
+// wrap-around seams (right edge to left edge, bottom edge to top edge) with the average
+// change between neighboring pixels inside the image. A score near 1 means the seams are
+// as smooth as the rest of the texture; higher scores mean visible edges.
+func SeamScore(img image.Image) float64 {
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if w < 2 || h < 2 {
+		return 0
+	}
+
+	var interior, seam float64
+	var interiorN, seamN int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			right := pixelDistance(src, x, y, (x+1)%w, y)
+			down := pixelDistance(src, x, y, x, (y+1)%h)
+			if x == w-1 {
+				seam += right
+				seamN++
+			} else {
+				interior += right
+				interiorN++
+			}
+			if y == h-1 {
+				seam += down
+				seamN++
+			} else {
+				interior += down
+				interiorN++
+			}
+		}
+	}
+
+	// Flat textures barely change between pixels; don't let tiny differences blow up
+	const minInterior = 1.0
+	return (seam / float64(seamN)) / max(interior/float64(interiorN), minInterior)
+}
+
+// Offset shifts img by dx, dy pixels, wrapping around the edges. Shifting a tile by
+// half its size moves the wrap-around seams to the center where they can be repaired.
+func Offset(img image.Image, dx, dy int) *image.NRGBA {
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(src.Rect)
+	dx = ((dx % w) + w) % w
+	dy = ((dy % h) + h) % h
+	for y := 0; y < h; y++ {
+		ny := (y + dy) % h
+		for x := 0; x < w; x++ {
+			nx := (x + dx) % w
+			copy(dst.Pix[dst.PixOffset(nx, ny):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
+
+// Tile repeats img in an n x n grid
+func Tile(img image.Image, n int) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx()*n, b.Dy()*n))
+	for ty := 0; ty < n; ty++ {
+		for tx := 0; tx < n; tx++ {
+			at := image.Pt(tx*b.Dx(), ty*b.Dy())
+			draw.Draw(dst, image.Rectangle{Min: at, Max: at.Add(b.Size())}, img, b.Min, draw.Src)
+		}
+	}
+	return dst
+}
+
+// pixelDistance returns the mean absolute RGB difference between two pixels
+func pixelDistance(img *image.NRGBA, x0, y0, x1, y1 int) float64 {
+	a := img.Pix[img.PixOffset(x0, y0):][:3]
+	b := img.Pix[img.PixOffset(x1, y1):][:3]
+	d := 0
+	for c := 0; c < 3; c++ {
+		d += absDiff(a[c], b[c])
+	}
+	return float64(d) / 3
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// toNRGBA returns img as an NRGBA with its origin at 0,0
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) {
+		return n
+	}
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Rect, img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,59 @@
+package texture
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// wave returns a tile that wraps perfectly when periodic is true, and a ramp with hard
+// seams otherwise
+func wave(w, h int, periodic bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := float64(x+y) / float64(w+h) * 255
+			if periodic {
+				v = 128 + 100*math.Sin(2*math.Pi*float64(x)/float64(w))*math.Cos(2*math.Pi*float64(y)/float64(h))
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(v), G: uint8(v), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestSeamScore(t *testing.T) {
+	if score := SeamScore(wave(64, 64, true)); score > DefaultSeamThreshold {
+		t.Errorf("periodic tile scored %.2f, want <= %.2f", score, DefaultSeamThreshold)
+	}
+	if score := SeamScore(wave(64, 64, false)); score <= DefaultSeamThreshold {
+		t.Errorf("ramp scored %.2f, want > %.2f", score, DefaultSeamThreshold)
+	}
+}
+
+func TestOffsetRoundTrip(t *testing.T) {
+	src := wave(33, 20, false)
+	back := Offset(Offset(src, 33/2, 20/2), -(33 / 2), -(20 / 2))
+	for i := range src.Pix {
+		if src.Pix[i] != back.Pix[i] {
+			t.Fatalf("offset round trip changed pixel data at byte %d", i)
+		}
+	}
+
+	// Shifting by half moves the ramp's seams to the center, so the edges now wrap
+	if score := SeamScore(Offset(src, 33/2, 20/2)); score > DefaultSeamThreshold {
+		t.Errorf("offset ramp scored %.2f at its edges, want <= %.2f", score, DefaultSeamThreshold)
+	}
+}
+
+func TestTile(t *testing.T) {
+	src := wave(10, 8, true)
+	tiled := Tile(src, 3)
+	if tiled.Rect.Dx() != 30 || tiled.Rect.Dy() != 24 {
+		t.Fatalf("expected 30x24, got %v", tiled.Rect)
+	}
+	if tiled.NRGBAAt(23, 17) != src.NRGBAAt(3, 1) {
+		t.Error("tile contents don't repeat")
+	}
+}
@@ -0,0 +1,198 @@
+package upscale
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// Factors lists the supported scale factors
+var Factors = []int{2, 4}
+
+// Tile is one overlapping piece of the source image
+type Tile struct {
+	Row, Col int
+	Source   image.Rectangle // in source pixels
+	// How far the tile overlaps its left and top neighbours, in source pixels; 0 on
+	// the image's edges
+	OverlapLeft, OverlapTop int
+}
+
+// Key identifies the tile within a run (see Fingerprint), for resume journals
+func (t Tile) Key(run string) string {
+	r := t.Source
+	return fmt.Sprintf("r%dc%d %d,%d,%d,%d %s", t.Row, t.Col, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), run)
+}
+
+// Fingerprint identifies the source pixels and the settings tiles are made with, so a
+// resumed run never reuses tiles of an edited image or of another model or resolution
+func Fingerprint(src image.Image, factor int, model, resolution string) string {
+	b := src.Bounds()
+	pixels := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(pixels, pixels.Rect, src, b.Min, draw.Src)
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%dx%d x%d %s %s\n", b.Dx(), b.Dy(), factor, model, resolution)
+	_, _ = h.Write(pixels.Pix)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Plan splits a width x height image into tiles of at most size x size pixels,
+// overlapping their neighbours by at least overlap pixels, in row-major order
+func Plan(width, height, size, overlap int) []Tile {
+	xs := positions(width, size, overlap)
+	ys := positions(height, size, overlap)
+	tw, th := min(size, width), min(size, height)
+
+	var tiles []Tile
+	for row, y := range ys {
+		for col, x := range xs {
+			t := Tile{Row: row, Col: col, Source: image.Rect(x, y, x+tw, y+th)}
+			if col > 0 {
+				t.OverlapLeft = xs[col-1] + tw - x
+			}
+			if row > 0 {
+				t.OverlapTop = ys[row-1] + th - y
+			}
+			tiles = append(tiles, t)
+		}
+	}
+	return tiles
+}
+
+// positions spreads tiles of size evenly along length so neighbours overlap by at
+// least overlap
+func positions(length, size, overlap int) []int {
+	if length <= size {
+		return []int{0}
+	}
+	step := max(1, size-overlap)
+	n := 1 + int(math.Ceil(float64(length-size)/float64(step)))
+	pos := make([]int, n)
+	for i := range pos {
+		pos[i] = int(math.Round(float64(i) * float64(length-size) / float64(n-1)))
+	}
+	return pos
+}
+
+// outline is the color marking the tile on the context image
+var outline = color.NRGBA{R: 255, G: 0, B: 255, A: 255}
+
+// Context returns the whole image scaled to fit maxDim with the tile outlined, so the
+// model knows what it is looking at
+func Context(src image.Image, t Tile, maxDim int) *image.NRGBA {
+	b := src.Bounds()
+	scale := min(1, float64(maxDim)/float64(max(b.Dx(), b.Dy())))
+	w := max(1, int(math.Round(float64(b.Dx())*scale)))
+	h := max(1, int(math.Round(float64(b.Dy())*scale)))
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Rect, src, b, draw.Src, nil)
+
+	r := image.Rect(
+		int(float64(t.Source.Min.X)*scale), int(float64(t.Source.Min.Y)*scale),
+		int(math.Ceil(float64(t.Source.Max.X)*scale)), int(math.Ceil(float64(t.Source.Max.Y)*scale)),
+	).Intersect(dst.Rect)
+	thickness := max(2, max(w, h)/200)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if x < r.Min.X+thickness || x >= r.Max.X-thickness || y < r.Min.Y+thickness || y >= r.Max.Y-thickness {
+				dst.SetNRGBA(x, y, outline)
+			}
+		}
+	}
+	return dst
+}
+
+// Prompt asks the model to redraw one tile at high resolution
+const Prompt = "The first image is a crop of a larger image; the second image shows the whole image with the crop outlined in magenta, for context only. " +
+	"Redraw the crop at much higher resolution: sharp edges and faithful fine detail and texture, as if captured with a far better camera. " +
+	"Do not add, remove or move anything and do not change colors, lighting or framing. Output only the crop, edge to edge, with no outline."
+
+// Canvas assembles upscaled tiles into the final image
+type Canvas struct {
+	Image  *image.NRGBA
+	src    image.Image
+	factor int
+}
+
+// NewCanvas starts the output for src at factor times its size
+func NewCanvas(src image.Image, factor int) *Canvas {
+	b := src.Bounds()
+	return &Canvas{
+		Image:  image.NewNRGBA(image.Rect(0, 0, b.Dx()*factor, b.Dy()*factor)),
+		src:    src,
+		factor: factor,
+	}
+}
+
+// Place scales an upscaled tile to its spot on the canvas, matches its overall color
+// to the source, and blends it in. Tiles must be placed in Plan order: each one fades
+// in across its overlap with the tiles above and to the left of it.
+func (c *Canvas) Place(t Tile, img image.Image) {
+	f := c.factor
+	dst := image.Rect(t.Source.Min.X*f, t.Source.Min.Y*f, t.Source.Max.X*f, t.Source.Max.Y*f)
+	tile := image.NewNRGBA(image.Rect(0, 0, dst.Dx(), dst.Dy()))
+	draw.CatmullRom.Scale(tile, tile.Rect, img, img.Bounds(), draw.Src, nil)
+	matchMean(tile, c.src, t.Source.Add(c.src.Bounds().Min))
+
+	left, top := float64(t.OverlapLeft*f), float64(t.OverlapTop*f)
+	for y := 0; y < dst.Dy(); y++ {
+		ay := 1.0
+		if top > 0 {
+			ay = min(1, (float64(y)+0.5)/top)
+		}
+		for x := 0; x < dst.Dx(); x++ {
+			a := ay
+			if left > 0 {
+				a *= min(1, (float64(x)+0.5)/left)
+			}
+			s := tile.Pix[tile.PixOffset(x, y):]
+			d := c.Image.Pix[c.Image.PixOffset(dst.Min.X+x, dst.Min.Y+y):]
+			for i := range 4 {
+				d[i] = uint8(math.Round(float64(d[i])*(1-a) + float64(s[i])*a))
+			}
+		}
+	}
+}
+
+// matchMean shifts each color channel of tile so its average matches the source
+// region it was made from, so tiles the model colored slightly differently don't
+// show as patches
+func matchMean(tile *image.NRGBA, src image.Image, region image.Rectangle) {
+	var want, got [3]float64
+	n := 0
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			want[0] += float64(c.R)
+			want[1] += float64(c.G)
+			want[2] += float64(c.B)
+			n++
+		}
+	}
+	m := 0
+	for i := 0; i < len(tile.Pix); i += 4 {
+		got[0] += float64(tile.Pix[i])
+		got[1] += float64(tile.Pix[i+1])
+		got[2] += float64(tile.Pix[i+2])
+		m++
+	}
+	if n == 0 || m == 0 {
+		return
+	}
+
+	var shift [3]float64
+	for i := range shift {
+		shift[i] = want[i]/float64(n) - got[i]/float64(m)
+	}
+	for i := 0; i < len(tile.Pix); i += 4 {
+		for ch := range 3 {
+			tile.Pix[i+ch] = uint8(max(0, min(255, math.Round(float64(tile.Pix[i+ch])+shift[ch]))))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package upscale
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	tiles := Plan(1000, 600, 400, 50)
+	if len(tiles) != 3*2 {
+		t.Fatalf("got %d tiles, want 3x2", len(tiles))
+	}
+
+	// Tiles cover the image, stay inside it and overlap their neighbours enough
+	covered := image.NewGray(image.Rect(0, 0, 1000, 600))
+	for _, tile := range tiles {
+		if !tile.Source.In(covered.Rect) || tile.Source.Dx() != 400 || tile.Source.Dy() != 400 {
+			t.Errorf("tile %d,%d = %v", tile.Row, tile.Col, tile.Source)
+		}
+		if tile.Col > 0 && tile.OverlapLeft < 50 || tile.Col == 0 && tile.OverlapLeft != 0 {
+			t.Errorf("tile %d,%d overlaps left by %d", tile.Row, tile.Col, tile.OverlapLeft)
+		}
+		if tile.Row > 0 && tile.OverlapTop < 50 || tile.Row == 0 && tile.OverlapTop != 0 {
+			t.Errorf("tile %d,%d overlaps top by %d", tile.Row, tile.Col, tile.OverlapTop)
+		}
+		for y := tile.Source.Min.Y; y < tile.Source.Max.Y; y++ {
+			for x := tile.Source.Min.X; x < tile.Source.Max.X; x++ {
+				covered.SetGray(x, y, color.Gray{Y: 1})
+			}
+		}
+	}
+	for _, v := range covered.Pix {
+		if v == 0 {
+			t.Fatal("tiles leave part of the image uncovered")
+		}
+	}
+
+	if tiles := Plan(300, 200, 400, 50); len(tiles) != 1 || tiles[0].Source != image.Rect(0, 0, 300, 200) {
+		t.Errorf("small image = %v, want one tile of the whole image", tiles)
+	}
+	if a, b := tiles[0].Key("run1"), tiles[0].Key("run2"); a == b {
+		t.Error("keys should change with the run")
+	}
+}
+
+func TestCanvasReassemblesImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 90, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 90; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 2), G: uint8(y * 3), B: 100, A: 255})
+		}
+	}
+
+	// A perfect "model" returns each tile unchanged at twice the size
+	canvas := NewCanvas(src, 2)
+	for _, tile := range Plan(90, 60, 40, 10) {
+		up := image.NewNRGBA(image.Rect(0, 0, tile.Source.Dx()*2, tile.Source.Dy()*2))
+		for y := range up.Rect.Dy() {
+			for x := range up.Rect.Dx() {
+				up.Set(x, y, src.At(tile.Source.Min.X+x/2, tile.Source.Min.Y+y/2))
+			}
+		}
+		canvas.Place(tile, up)
+	}
+
+	if got := canvas.Image.Bounds().Size(); got != image.Pt(180, 120) {
+		t.Fatalf("canvas size = %v", got)
+	}
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 180; x++ {
+			got, want := canvas.Image.NRGBAAt(x, y), src.NRGBAAt(x/2, y/2)
+			if diff(got.R, want.R) > 2 || diff(got.G, want.G) > 2 || got.A != 255 {
+				t.Fatalf("pixel %d,%d = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestMatchMean(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	tile := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range src.Pix {
+		src.Pix[i] = 100
+		tile.Pix[i] = 120
+		tile.Pix[i+len(src.Pix)] = 120
+		tile.Pix[i+2*len(src.Pix)] = 120
+		tile.Pix[i+3*len(src.Pix)] = 120
+	}
+	matchMean(tile, src, src.Rect)
+	if got := tile.NRGBAAt(3, 3); got.R != 100 || got.G != 100 || got.B != 100 {
+		t.Errorf("matched pixel = %v, want the source's average", got)
+	}
+}
+
+func diff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func TestFingerprint(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	base := Fingerprint(src, 2, "pro", "4K")
+	if Fingerprint(src, 2, "pro", "4K") != base {
+		t.Error("fingerprint should be stable")
+	}
+
+	edited := image.NewNRGBA(src.Rect)
+	copy(edited.Pix, src.Pix)
+	edited.Pix[0] = 1
+	for name, fp := range map[string]string{
+		"pixels":     Fingerprint(edited, 2, "pro", "4K"),
+		"factor":     Fingerprint(src, 4, "pro", "4K"),
+		"model":      Fingerprint(src, 2, "flash", "4K"),
+		"resolution": Fingerprint(src, 2, "pro", "2K"),
+	} {
+		if fp == base {
+			t.Errorf("fingerprint should change with the %s", name)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package variation
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Strengths lists how far a variation may stray from its parent
+var Strengths = []string{"low", "high"}
+
+// strengthInstructions says what may change at each strength
+var strengthInstructions = map[string]string{
+	"low": "Create a close variation of this image: keep the same subject, composition, framing, style and color palette, " +
+		"and change only small details such as pose, expression, textures, lighting nuances and minor background elements, " +
+		"so it reads as an alternate take of the same shot.",
+	"high": "Create a fresh variation of this image: keep the same subject, theme and overall style, " +
+		"but reimagine the composition, viewpoint, pose, arrangement and details so it feels like a different take on the same idea.",
+}
+
+// Instruction asks for one variation of the reference image at the given strength. The
+// prompt the parent was made from, when known, keeps the variation on brief.
+func Instruction(strength, prompt string) (string, error) {
+	p, ok := strengthInstructions[strength]
+	if !ok {
+		return "", fmt.Errorf("unsupported strength: %s (use %s)", strength, strings.Join(Strengths, ", "))
+	}
+	if prompt = strings.TrimSpace(prompt); prompt != "" {
+		p += " The image was made from this prompt, which the variation should still match: " + prompt
+		if !strings.HasSuffix(prompt, ".") {
+			p += "."
+		}
+	}
+	return p + " Do not copy the image exactly, and add no text, borders or watermarks.", nil
+}
+
+// Name returns the file name of a parent's nth variation, a PNG so it can carry its
+// lineage: photo.jpg → photo_var3.png
+func Name(parent string, n int) string {
+	base := filepath.Base(parent)
+	return fmt.Sprintf("%s_var%d.png", strings.TrimSuffix(base, filepath.Ext(base)), n)
+}
@@ -0,0 +1,49 @@
+package variation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstruction(t *testing.T) {
+	low, err := Instruction("low", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := Instruction("high", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low == high {
+		t.Error("strengths should ask for different changes")
+	}
+	if strings.Contains(low, "prompt") {
+		t.Errorf("instruction without a prompt mentions one: %q", low)
+	}
+
+	withPrompt, err := Instruction("low", "a red fox in the snow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(withPrompt, "a red fox in the snow.") || strings.Contains(withPrompt, "..") {
+		t.Errorf("instruction doesn't carry the prompt cleanly: %q", withPrompt)
+	}
+
+	if _, err := Instruction("medium", ""); err == nil {
+		t.Error("expected an error for an unknown strength")
+	}
+}
+
+func TestName(t *testing.T) {
+	cases := map[string]string{
+		"photo.png":          "photo_var1.png",
+		"shots/photo.jpg":    "photo_var1.png",
+		"a.b/photo_var2.png": "photo_var2_var1.png",
+		"no-extension":       "no-extension_var1.png",
+	}
+	for parent, want := range cases {
+		if got := Name(parent, 1); got != want {
+			t.Errorf("Name(%q, 1) = %q, want %q", parent, got, want)
+		}
+	}
+}